@@ -10,21 +10,37 @@ import (
 
 	"github.com/gorilla/mux"
 	"humanitec.io/deploymentset-svc/pkg/depset"
+	"humanitec.io/deploymentset-svc/pkg/policy"
 )
 
 // DeltaWrapper represents the "over-the-wire" structure of a Deployment Delta
+//
+// A locked Delta cannot be changed until it is unlocked.
 type DeltaWrapper struct {
 	ID       string        `json:"id"`
+	Locked   bool          `json:"locked"`
 	Metadata DeltaMetadata `json:"metadata"`
 	depset.Delta
 }
 
-// DeltaMetadata contains things like first creation date and who created it
+// DeltaMetadata contains things like first creation date and who created it. LockedBy and LockedAt are only set
+// while the delta is locked.
 type DeltaMetadata struct {
-	CreatedBy      string    `json:"created_by"`
-	CreatedAt      time.Time `json:"created_at"`
-	LastModifiedAt time.Time `json:"last_modified_at"`
-	Contributers   []string  `json:"contributers,omitempty"`
+	CreatedBy      string             `json:"created_by"`
+	CreatedAt      time.Time          `json:"created_at"`
+	LastModifiedAt time.Time          `json:"last_modified_at"`
+	Contributers   []string           `json:"contributers,omitempty"`
+	LockedBy       string             `json:"locked_by,omitempty"`
+	LockedAt       *time.Time         `json:"locked_at,omitempty"`
+	Applications   []DeltaApplication `json:"applications,omitempty"`
+}
+
+// DeltaApplication records that a delta was applied to a set, producing a new set.
+type DeltaApplication struct {
+	SetID       string    `json:"set_id"`
+	ResultSetID string    `json:"result_set_id"`
+	AppliedBy   string    `json:"applied_by"`
+	AppliedAt   time.Time `json:"applied_at"`
 }
 
 func isInSlice(slice []string, str string) bool {
@@ -36,6 +52,28 @@ func isInSlice(slice []string, str string) bool {
 	return false
 }
 
+// recordEdit returns a copy of metadata updated to show that the delta was modified by user just now.
+func recordEdit(metadata DeltaMetadata, user string) DeltaMetadata {
+	metadata.LastModifiedAt = time.Now().UTC()
+
+	if user != metadata.CreatedBy && !isInSlice(metadata.Contributers, user) {
+		newContributers := make([]string, len(metadata.Contributers), len(metadata.Contributers)+1)
+		copy(newContributers, metadata.Contributers)
+		metadata.Contributers = append(newContributers, user)
+	}
+	return metadata
+}
+
+// writeLocked writes the response for an attempt to change a locked delta. The user who locked it is not known if the
+// delta was locked after it was read.
+func writeLocked(w http.ResponseWriter, dw DeltaWrapper) {
+	if dw.Metadata.LockedBy == "" {
+		writeAsJSON(w, http.StatusConflict, fmt.Sprintf(`Delta with ID "%s" is locked.`, dw.ID))
+		return
+	}
+	writeAsJSON(w, http.StatusConflict, fmt.Sprintf(`Delta with ID "%s" is locked by "%s".`, dw.ID, dw.Metadata.LockedBy))
+}
+
 // listDeltas returns a handler which returns a list of all the deltas in the specified app.
 //
 // The handler expects the organization to be defined by a parameter "orgId" and app by "appId"
@@ -88,7 +126,11 @@ func (s *server) getDelta() http.HandlerFunc {
 //
 // 201 Delta created; body of response is new set ID
 //
-// 422 Delta was malformed
+// 403 The user is not allowed to make some of the changes in the Delta; body of response is the list of policy
+// violations
+//
+// 422 Delta was malformed or adds invalid modules; body of response is the list of validation errors if the delta
+// or the modules are invalid
 func (s *server) createDelta() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		params := mux.Vars(r)
@@ -102,6 +144,19 @@ func (s *server) createDelta() http.HandlerFunc {
 			w.WriteHeader(http.StatusUnprocessableEntity)
 			return
 		}
+		changedModules := delta.ChangedModules()
+		errs := delta.Validate()
+		errs = append(errs, delta.ValidateModules(s.strictModules, changedModules...)...)
+		errs = append(errs, delta.ValidateValues(s.schemas, changedModules...)...)
+		if len(errs) > 0 {
+			writeAsJSON(w, http.StatusUnprocessableEntity, errs)
+			return
+		}
+		// The Set the Delta will be applied to is not known yet, so removed modules are checked when it is applied
+		if violations := s.policy.Check(getUser(r), depset.Set{}, delta); len(violations) > 0 {
+			writeAsJSON(w, http.StatusForbidden, violations)
+			return
+		}
 
 		createdTime := time.Now().UTC()
 		metadata := DeltaMetadata{
@@ -129,9 +184,15 @@ func (s *server) createDelta() http.HandlerFunc {
 //
 // 200 Delta sucessfully replaced.
 //
+// 403 The user is not allowed to make some of the changes in the Delta; body of response is the list of policy
+// violations
+//
 // 404 The deltaId was not found.
 //
-// 422 Delta was malformed
+// 409 The delta is locked.
+//
+// 422 Delta was malformed or adds invalid modules; body of response is the list of validation errors if the delta
+// or the modules are invalid
 func (s *server) replaceDelta() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		params := mux.Vars(r)
@@ -145,25 +206,40 @@ func (s *server) replaceDelta() http.HandlerFunc {
 			w.WriteHeader(http.StatusUnprocessableEntity)
 			return
 		}
+		changedModules := delta.ChangedModules()
+		errs := delta.Validate()
+		errs = append(errs, delta.ValidateModules(s.strictModules, changedModules...)...)
+		errs = append(errs, delta.ValidateValues(s.schemas, changedModules...)...)
+		if len(errs) > 0 {
+			writeAsJSON(w, http.StatusUnprocessableEntity, errs)
+			return
+		}
+		// The Set the Delta will be applied to is not known yet, so removed modules are checked when it is applied
+		if violations := s.policy.Check(getUser(r), depset.Set{}, delta); len(violations) > 0 {
+			writeAsJSON(w, http.StatusForbidden, violations)
+			return
+		}
 
 		currentDeltaWrapper, err := s.model.selectDelta(params["orgId"], params["appId"], params["deltaId"])
 		if errors.Is(err, ErrNotFound) {
 			writeAsJSON(w, http.StatusNotFound, fmt.Sprintf(`Delta with ID "%s" not available in Application "%s/%s".`, params["deltaId"], params["orgId"], params["appId"]))
 			return
+		} else if err != nil {
+			w.WriteHeader(500)
+			return
 		}
-
-		metadata := currentDeltaWrapper.Metadata
-		metadata.LastModifiedAt = time.Now().UTC()
-		currentUser := getUser(r)
-
-		if currentUser != metadata.CreatedBy && !isInSlice(metadata.Contributers, currentUser) {
-			newContributers := make([]string, len(metadata.Contributers), len(metadata.Contributers)+1)
-			copy(newContributers, metadata.Contributers)
-			metadata.Contributers = append(newContributers, currentUser)
+		if currentDeltaWrapper.Locked {
+			writeLocked(w, currentDeltaWrapper)
+			return
 		}
 
-		err = s.model.updateDelta(params["orgId"], params["appId"], params["deltaId"], false, metadata, delta)
-		if err != nil {
+		metadata := recordEdit(currentDeltaWrapper.Metadata, getUser(r))
+
+		err = s.model.updateDelta(params["orgId"], params["appId"], params["deltaId"], metadata, delta)
+		if errors.Is(err, ErrLocked) {
+			writeLocked(w, DeltaWrapper{ID: params["deltaId"]})
+			return
+		} else if err != nil {
 			w.WriteHeader(500)
 			return
 		}
@@ -183,9 +259,15 @@ func (s *server) replaceDelta() http.HandlerFunc {
 //
 // 400 The deltas cannot be merged as they are not compatible.
 //
+// 403 The user is not allowed to make some of the changes in the deltas; body of response is the list of policy
+// violations
+//
 // 404 The deltaId was not found.
 //
-// 422 Delta was malformed
+// 409 The delta is locked.
+//
+// 422 Delta was malformed or the updated delta adds invalid modules; body of response is the list of validation
+// errors if the deltas or the modules are invalid
 func (s *server) updateDelta() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		params := mux.Vars(r)
@@ -199,11 +281,35 @@ func (s *server) updateDelta() http.HandlerFunc {
 			w.WriteHeader(http.StatusUnprocessableEntity)
 			return
 		}
+		var deltaErrs depset.ValidationErrors
+		for _, delta := range deltas {
+			deltaErrs = append(deltaErrs, delta.Validate()...)
+		}
+		if len(deltaErrs) > 0 {
+			writeAsJSON(w, http.StatusUnprocessableEntity, deltaErrs)
+			return
+		}
+		// The Set the Delta will be applied to is not known yet, so removed modules are checked when it is applied
+		var violations []policy.Violation
+		for _, delta := range deltas {
+			violations = append(violations, s.policy.Check(getUser(r), depset.Set{}, delta)...)
+		}
+		if len(violations) > 0 {
+			writeAsJSON(w, http.StatusForbidden, violations)
+			return
+		}
 
 		currentDeltaWrapper, err := s.model.selectDelta(params["orgId"], params["appId"], params["deltaId"])
 		if errors.Is(err, ErrNotFound) {
 			writeAsJSON(w, http.StatusNotFound, fmt.Sprintf(`Delta with ID "%s" not available in Application "%s/%s".`, params["deltaId"], params["orgId"], params["appId"]))
 			return
+		} else if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		if currentDeltaWrapper.Locked {
+			writeLocked(w, currentDeltaWrapper)
+			return
 		}
 
 		if len(deltas) == 0 {
@@ -217,15 +323,7 @@ func (s *server) updateDelta() http.HandlerFunc {
 			return
 		}
 
-		metadata := currentDeltaWrapper.Metadata
-		metadata.LastModifiedAt = time.Now().UTC()
-		currentUser := getUser(r)
-
-		if currentUser != metadata.CreatedBy && !isInSlice(metadata.Contributers, currentUser) {
-			newContributers := make([]string, len(metadata.Contributers), len(metadata.Contributers)+1)
-			copy(newContributers, metadata.Contributers)
-			metadata.Contributers = append(newContributers, currentUser)
-		}
+		metadata := recordEdit(currentDeltaWrapper.Metadata, getUser(r))
 
 		newDelta, err := depset.MergeDeltas(currentDeltaWrapper.Delta, deltas...)
 		if err != nil {
@@ -234,8 +332,117 @@ func (s *server) updateDelta() http.HandlerFunc {
 			return
 		}
 
-		err = s.model.updateDelta(params["orgId"], params["appId"], params["deltaId"], false, metadata, newDelta)
+		// Only modules changed by the new deltas are validated so that existing invalid modules do not block changes
+		// to other modules.
+		var changedModules []string
+		for _, delta := range deltas {
+			changedModules = append(changedModules, delta.ChangedModules()...)
+		}
+		errs := newDelta.ValidateModules(s.strictModules, changedModules...)
+		errs = append(errs, newDelta.ValidateValues(s.schemas, changedModules...)...)
+		if len(errs) > 0 {
+			writeAsJSON(w, http.StatusUnprocessableEntity, errs)
+			return
+		}
+
+		err = s.model.updateDelta(params["orgId"], params["appId"], params["deltaId"], metadata, newDelta)
+		if errors.Is(err, ErrLocked) {
+			writeLocked(w, DeltaWrapper{ID: params["deltaId"]})
+			return
+		} else if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+
+		writeAsJSON(w, http.StatusOK, DeltaWrapper{
+			ID:       params["deltaId"],
+			Metadata: metadata,
+			Delta:    newDelta,
+		})
+	}
+}
+
+// rebaseDelta returns a handler which rebases a delta from one set onto another set in place.
+//
+// The handler expects the organization to be defined by a parameter "orgId", the app by "appId", deltaId by "deltaId",
+// the set the delta was written for by "fromSetId" and the set to rebase onto by "ontoSetId".
+//
+// The handler returns the following status codes:
+//
+// 200 Delta sucessfully rebased; body of response is the updated delta.
+//
+// 400 The delta is not compatible with the set it was written for.
+//
+// 403 The user is not allowed to make some of the changes in the rebased delta; body of response is the list of
+// policy violations.
+//
+// 404 The deltaId or one of the sets was not found.
+//
+// 409 The delta is locked, or it conflicts with changes made in the set to rebase onto; body of response is the list
+// of conflicts if there are any.
+//
+// 422 The rebased delta is not valid; body of response is the list of validation errors.
+func (s *server) rebaseDelta() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		currentDeltaWrapper, err := s.model.selectDelta(params["orgId"], params["appId"], params["deltaId"])
+		if errors.Is(err, ErrNotFound) {
+			writeAsJSON(w, http.StatusNotFound, fmt.Sprintf(`Delta with ID "%s" not available in Application "%s/%s".`, params["deltaId"], params["orgId"], params["appId"]))
+			return
+		} else if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		if currentDeltaWrapper.Locked {
+			writeLocked(w, currentDeltaWrapper)
+			return
+		}
+
+		sets := make(map[string]depset.Set)
+		for _, setParam := range []string{"fromSetId", "ontoSetId"} {
+			set, err := s.fetchRawSet(params["orgId"], params["appId"], params[setParam])
+			if err == ErrNotFound {
+				writeAsJSON(w, http.StatusNotFound, fmt.Sprintf(`Set with ID "%s" not available in Application "%s/%s".`, params[setParam], params["orgId"], params["appId"]))
+				return
+			} else if err != nil {
+				w.WriteHeader(500)
+				return
+			}
+			sets[setParam] = set
+		}
+
+		newDelta, err := depset.Rebase(sets["fromSetId"], sets["ontoSetId"], currentDeltaWrapper.Delta)
 		if err != nil {
+			var conflictErr *depset.ConflictError
+			if errors.As(err, &conflictErr) {
+				writeAsJSON(w, http.StatusConflict, conflictErr.Conflicts)
+				return
+			}
+			writeAsJSON(w, http.StatusBadRequest, "Delta is not compatible with Set")
+			return
+		}
+
+		changedModules := newDelta.ChangedModules()
+		errs := newDelta.Validate()
+		errs = append(errs, newDelta.ValidateModules(s.strictModules, changedModules...)...)
+		errs = append(errs, newDelta.ValidateValues(s.schemas, changedModules...)...)
+		if len(errs) > 0 {
+			writeAsJSON(w, http.StatusUnprocessableEntity, errs)
+			return
+		}
+		if violations := s.policy.Check(getUser(r), sets["ontoSetId"], newDelta); len(violations) > 0 {
+			writeAsJSON(w, http.StatusForbidden, violations)
+			return
+		}
+
+		metadata := recordEdit(currentDeltaWrapper.Metadata, getUser(r))
+
+		err = s.model.updateDelta(params["orgId"], params["appId"], params["deltaId"], metadata, newDelta)
+		if errors.Is(err, ErrLocked) {
+			writeLocked(w, DeltaWrapper{ID: params["deltaId"]})
+			return
+		} else if err != nil {
 			w.WriteHeader(500)
 			return
 		}
@@ -247,3 +454,54 @@ func (s *server) updateDelta() http.HandlerFunc {
 		})
 	}
 }
+
+// lockDelta returns a handler which locks a delta so that it cannot be changed until it is unlocked. The user and
+// time are recorded in the metadata. Locking a delta that is already locked does nothing.
+//
+// The handler expects the organization to be defined by a parameter "orgId", the app by "appId" and deltaId by "deltaId".
+//
+// The handler returns the following status codes:
+//
+// 200 Delta locked; body of response is the updated delta.
+//
+// 404 The deltaId was not found.
+func (s *server) lockDelta() http.HandlerFunc {
+	return s.setDeltaLocked(true)
+}
+
+// unlockDelta returns a handler which unlocks a delta so that it can be changed again. Unlocking a delta that is not
+// locked does nothing.
+//
+// The handler expects the organization to be defined by a parameter "orgId", the app by "appId" and deltaId by "deltaId".
+//
+// The handler returns the following status codes:
+//
+// 200 Delta unlocked; body of response is the updated delta.
+//
+// 404 The deltaId was not found.
+func (s *server) unlockDelta() http.HandlerFunc {
+	return s.setDeltaLocked(false)
+}
+
+func (s *server) setDeltaLocked(locked bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		err := s.model.updateDeltaLock(params["orgId"], params["appId"], params["deltaId"], locked, getUser(r), time.Now().UTC())
+		if errors.Is(err, ErrNotFound) {
+			writeAsJSON(w, http.StatusNotFound, fmt.Sprintf(`Delta with ID "%s" not available in Application "%s/%s".`, params["deltaId"], params["orgId"], params["appId"]))
+			return
+		} else if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+
+		deltaWrapper, err := s.model.selectDelta(params["orgId"], params["appId"], params["deltaId"])
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+
+		writeAsJSON(w, http.StatusOK, deltaWrapper)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/matryer/is"
 	"humanitec.io/deploymentset-svc/pkg/depset"
+	"humanitec.io/deploymentset-svc/pkg/policy"
 )
 
 func orderInvarientEqual(a, b []string) bool {
@@ -225,7 +226,8 @@ func TestCreateDelta(t *testing.T) {
 		Modules: depset.ModuleDeltas{
 			Add: map[string]map[string]interface{}{
 				"test-module": map[string]interface{}{
-					"version": "TEST_VERSION",
+					"helmchart": "humanitec/base-module",
+					"version":   "TEST_VERSION",
 				},
 			},
 		},
@@ -273,7 +275,8 @@ func TestReplaceDelta(t *testing.T) {
 		Modules: depset.ModuleDeltas{
 			Add: map[string]map[string]interface{}{
 				"test-module": map[string]interface{}{
-					"version": "TEST_VERSION",
+					"helmchart": "humanitec/base-module",
+					"version":   "TEST_VERSION",
 				},
 			},
 		},
@@ -288,7 +291,8 @@ func TestReplaceDelta(t *testing.T) {
 		Modules: depset.ModuleDeltas{
 			Add: map[string]map[string]interface{}{
 				"test-module": map[string]interface{}{
-					"version": "TEST_VERSION02",
+					"helmchart": "humanitec/base-module",
+					"version":   "TEST_VERSION02",
 				},
 			},
 		},
@@ -312,7 +316,7 @@ func TestReplaceDelta(t *testing.T) {
 
 	m.
 		EXPECT().
-		updateDelta(orgID, appID, deltaID, false, IgnoreDateMetadata(expecetdMetadata), userProvidedDelta).
+		updateDelta(orgID, appID, deltaID, IgnoreDateMetadata(expecetdMetadata), userProvidedDelta).
 		Return(nil).
 		Times(1)
 
@@ -342,7 +346,8 @@ func TestReplaceDelta_SameUser(t *testing.T) {
 		Modules: depset.ModuleDeltas{
 			Add: map[string]map[string]interface{}{
 				"test-module": map[string]interface{}{
-					"version": "TEST_VERSION",
+					"helmchart": "humanitec/base-module",
+					"version":   "TEST_VERSION",
 				},
 			},
 		},
@@ -357,7 +362,8 @@ func TestReplaceDelta_SameUser(t *testing.T) {
 		Modules: depset.ModuleDeltas{
 			Add: map[string]map[string]interface{}{
 				"test-module": map[string]interface{}{
-					"version": "TEST_VERSION02",
+					"helmchart": "humanitec/base-module",
+					"version":   "TEST_VERSION02",
 				},
 			},
 		},
@@ -381,7 +387,7 @@ func TestReplaceDelta_SameUser(t *testing.T) {
 
 	m.
 		EXPECT().
-		updateDelta(orgID, appID, deltaID, false, IgnoreDateMetadata(expecetdMetadata), userProvidedDelta).
+		updateDelta(orgID, appID, deltaID, IgnoreDateMetadata(expecetdMetadata), userProvidedDelta).
 		Return(nil).
 		Times(1)
 
@@ -410,7 +416,8 @@ func TestReplaceDelta_DeltaDoesNotExist(t *testing.T) {
 		Modules: depset.ModuleDeltas{
 			Add: map[string]map[string]interface{}{
 				"test-module": map[string]interface{}{
-					"version": "TEST_VERSION",
+					"helmchart": "humanitec/base-module",
+					"version":   "TEST_VERSION",
 				},
 			},
 		},
@@ -493,7 +500,8 @@ func TestUpdateDelta(t *testing.T) {
 			Modules: depset.ModuleDeltas{
 				Add: map[string]map[string]interface{}{
 					"test-module": map[string]interface{}{
-						"version": "TEST_VERSION",
+						"helmchart": "humanitec/base-module",
+						"version":   "TEST_VERSION",
 					},
 				},
 			},
@@ -504,7 +512,8 @@ func TestUpdateDelta(t *testing.T) {
 			Modules: depset.ModuleDeltas{
 				Add: map[string]map[string]interface{}{
 					"second-module": map[string]interface{}{
-						"version": "SECOND_VERSION",
+						"helmchart": "humanitec/base-module",
+						"version":   "SECOND_VERSION",
 					},
 				},
 			},
@@ -522,10 +531,12 @@ func TestUpdateDelta(t *testing.T) {
 			Modules: depset.ModuleDeltas{
 				Add: map[string]map[string]interface{}{
 					"test-module": map[string]interface{}{
-						"version": "TEST_VERSION",
+						"helmchart": "humanitec/base-module",
+						"version":   "TEST_VERSION",
 					},
 					"second-module": map[string]interface{}{
-						"version": "SECOND_VERSION",
+						"helmchart": "humanitec/base-module",
+						"version":   "SECOND_VERSION",
 					},
 				},
 				Remove: []string{},
@@ -542,7 +553,7 @@ func TestUpdateDelta(t *testing.T) {
 
 	m.
 		EXPECT().
-		updateDelta(orgID, appID, deltaID, false, IgnoreDateMetadata(expectedDeltaWrapper.Metadata), expectedDeltaWrapper.Delta).
+		updateDelta(orgID, appID, deltaID, IgnoreDateMetadata(expectedDeltaWrapper.Metadata), expectedDeltaWrapper.Delta).
 		Return(nil).
 		Times(1)
 
@@ -614,3 +625,660 @@ func TestUpdateDelta_EmptyPayload(t *testing.T) {
 	is.Equal(returnedDeltaWrapper.Delta, expectedDeltaWrapper.Delta)                                  // Returned Delta should match expected delta
 
 }
+
+func TestRebaseDelta(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	deltaID := "0123456789ABCDEFDEADBEEFDEADBEEFDEADBEEF"
+	fromSetID := "from-set"
+	ontoSetID := "onto-set"
+	currentUser := "UNKNOWN"
+
+	baseDeltaWrapper := DeltaWrapper{
+		ID: deltaID,
+		Metadata: DeltaMetadata{
+			CreatedBy:      "first-user",
+			CreatedAt:      time.Date(2020, time.January, 1, 1, 0, 0, 0, time.UTC),
+			LastModifiedAt: time.Date(2020, time.January, 1, 1, 0, 0, 0, time.UTC),
+			Contributers:   []string{},
+		},
+		Delta: depset.Delta{
+			Modules: depset.ModuleDeltas{
+				Update: map[string][]depset.UpdateAction{
+					"test-module": []depset.UpdateAction{
+						depset.UpdateAction{Operation: "replace", Path: "/version", Value: "TEST_VERSION02"},
+					},
+				},
+			},
+		},
+	}
+	fromSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION01",
+			},
+		},
+	}
+	ontoSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION01",
+			},
+			"other-module": map[string]interface{}{
+				"version": "OTHER_VERSION",
+			},
+		},
+	}
+	expectedDeltaWrapper := DeltaWrapper{
+		ID: deltaID,
+		Metadata: DeltaMetadata{
+			CreatedBy:      "first-user",
+			CreatedAt:      time.Date(2020, time.January, 1, 1, 0, 0, 0, time.UTC),
+			LastModifiedAt: time.Date(2020, time.January, 1, 1, 0, 0, 0, time.UTC),
+			Contributers:   []string{currentUser},
+		},
+		Delta: depset.Delta{
+			Modules: depset.ModuleDeltas{
+				Add:    map[string]map[string]interface{}{},
+				Remove: []string{},
+				Update: map[string][]depset.UpdateAction{
+					"test-module": []depset.UpdateAction{
+						depset.UpdateAction{Operation: "replace", Path: "/version", Value: "TEST_VERSION02"},
+					},
+				},
+			},
+		},
+	}
+
+	m.EXPECT().selectDelta(orgID, appID, deltaID).Return(baseDeltaWrapper, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, fromSetID).Return(fromSet, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, ontoSetID).Return(ontoSet, nil).Times(1)
+
+	m.
+		EXPECT().
+		updateDelta(orgID, appID, deltaID, IgnoreDateMetadata(expectedDeltaWrapper.Metadata), expectedDeltaWrapper.Delta).
+		Return(nil).
+		Times(1)
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/deltas/%s/rebase?from=%s&onto=%s", orgID, appID, deltaID, fromSetID, ontoSetID), nil, t)
+
+	is.Equal(res.Code, http.StatusOK) // Should return 200
+
+	var returnedDeltaWrapper DeltaWrapper
+	json.Unmarshal(res.Body.Bytes(), &returnedDeltaWrapper)
+
+	is.Equal(returnedDeltaWrapper.Delta, expectedDeltaWrapper.Delta) // Returned Delta should match expected delta
+}
+
+func TestRebaseDelta_Conflict(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	deltaID := "0123456789ABCDEFDEADBEEFDEADBEEFDEADBEEF"
+	fromSetID := "from-set"
+	ontoSetID := "onto-set"
+
+	baseDeltaWrapper := DeltaWrapper{
+		ID: deltaID,
+		Delta: depset.Delta{
+			Modules: depset.ModuleDeltas{
+				Update: map[string][]depset.UpdateAction{
+					"test-module": []depset.UpdateAction{
+						depset.UpdateAction{Operation: "replace", Path: "/version", Value: "TEST_VERSION02"},
+					},
+				},
+			},
+		},
+	}
+	fromSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION01",
+			},
+		},
+	}
+	ontoSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION03",
+			},
+		},
+	}
+
+	m.EXPECT().selectDelta(orgID, appID, deltaID).Return(baseDeltaWrapper, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, fromSetID).Return(fromSet, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, ontoSetID).Return(ontoSet, nil).Times(1)
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/deltas/%s/rebase?from=%s&onto=%s", orgID, appID, deltaID, fromSetID, ontoSetID), nil, t)
+
+	is.Equal(res.Code, http.StatusConflict) // Should return 409
+
+	var conflicts []depset.Conflict
+	json.Unmarshal(res.Body.Bytes(), &conflicts)
+
+	is.Equal(len(conflicts), 1)             // There should be one conflict
+	is.Equal(conflicts[0].Path, "/version") // The conflict should be on the version
+}
+
+func TestRebaseDelta_InvalidModule(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	deltaID := "0123456789ABCDEFDEADBEEFDEADBEEFDEADBEEF"
+	fromSetID := "from-set"
+	ontoSetID := "onto-set"
+
+	baseDeltaWrapper := DeltaWrapper{
+		ID: deltaID,
+		Delta: depset.Delta{
+			Modules: depset.ModuleDeltas{
+				Add: map[string]map[string]interface{}{
+					"test-module": map[string]interface{}{
+						"values": "TEST_VERSION",
+					},
+				},
+			},
+		},
+	}
+
+	m.EXPECT().selectDelta(orgID, appID, deltaID).Return(baseDeltaWrapper, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, fromSetID).Return(depset.Set{}, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, ontoSetID).Return(depset.Set{}, nil).Times(1)
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/deltas/%s/rebase?from=%s&onto=%s", orgID, appID, deltaID, fromSetID, ontoSetID), nil, t)
+
+	is.Equal(res.Code, http.StatusUnprocessableEntity) // Should return 422
+}
+
+func TestRebaseDelta_PolicyViolation(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	deltaID := "0123456789ABCDEFDEADBEEFDEADBEEFDEADBEEF"
+	fromSetID := "from-set"
+	ontoSetID := "onto-set"
+
+	baseDeltaWrapper := DeltaWrapper{
+		ID: deltaID,
+		Delta: depset.Delta{
+			Modules: depset.ModuleDeltas{
+				Update: map[string][]depset.UpdateAction{
+					"test-module": []depset.UpdateAction{
+						depset.UpdateAction{Operation: "replace", Path: "/helmchart", Value: "humanitec/other-module"},
+					},
+				},
+			},
+		},
+	}
+	set := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+			},
+		},
+	}
+
+	m.EXPECT().selectDelta(orgID, appID, deltaID).Return(baseDeltaWrapper, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, fromSetID).Return(set, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, ontoSetID).Return(set, nil).Times(1)
+
+	s := &server{model: m, policy: testPolicy(t)}
+	res := ExecuteServerRequest(s, "test-user", "POST", fmt.Sprintf("/orgs/%s/apps/%s/deltas/%s/rebase?from=%s&onto=%s", orgID, appID, deltaID, fromSetID, ontoSetID), nil, t)
+
+	is.Equal(res.Code, http.StatusForbidden) // Should return 403
+
+	var violations []policy.Violation
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &violations))
+	is.Equal(len(violations), 1)               // There should be one violation
+	is.Equal(violations[0].Path, "/helmchart") // The violation should be on the helmchart
+}
+
+func TestCreateDelta_InvalidModule(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	userProvidedDelta := depset.Delta{
+		Modules: depset.ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"test-module": map[string]interface{}{
+					"values": "TEST_VERSION",
+				},
+			},
+		},
+	}
+
+	buf, err := json.Marshal(userProvidedDelta)
+	is.NoErr(err)
+	body := bytes.NewBuffer(buf)
+
+	res := ExecuteServerRequest(&server{model: m, strictModules: true}, "", "POST", fmt.Sprintf("/orgs/%s/apps/%s/deltas", orgID, appID), body, t)
+
+	is.Equal(res.Code, http.StatusUnprocessableEntity) // Should return 422
+
+	var errs depset.ValidationErrors
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &errs))
+	is.Equal(errs, depset.ValidationErrors{
+		{Module: "test-module", Path: "/helmchart", Message: "helmchart is required"},
+		{Module: "test-module", Path: "/values", Message: "values must be an object"},
+	})
+}
+
+func TestCreateDelta_AddedModuleWithoutHelmchart(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	body := bytes.NewBuffer([]byte(`{"modules": {"add": {"test-module": {"foo": 1}}}}`))
+
+	res := ExecuteRequest(m, "POST", "/orgs/test-org/apps/test-app/deltas", body, t)
+
+	is.Equal(res.Code, http.StatusUnprocessableEntity) // Should return 422 even if modules are not strictly validated
+
+	var errs depset.ValidationErrors
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &errs))
+	is.Equal(errs, depset.ValidationErrors{
+		{Module: "test-module", Path: "/helmchart", Message: "helmchart is required"},
+	})
+}
+
+func TestCreateDelta_InvalidValues(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	userProvidedDelta := depset.Delta{
+		Modules: depset.ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"test-module": map[string]interface{}{
+					"helmchart": "humanitec/base-module",
+					"values": map[string]interface{}{
+						"image": 5,
+					},
+				},
+			},
+		},
+	}
+
+	buf, err := json.Marshal(userProvidedDelta)
+	is.NoErr(err)
+	body := bytes.NewBuffer(buf)
+
+	res := ExecuteRequestWithSchemas(m, testSchemas(t), "POST", fmt.Sprintf("/orgs/%s/apps/%s/deltas", orgID, appID), body, t)
+
+	is.Equal(res.Code, http.StatusUnprocessableEntity) // Should return 422
+
+	var errs depset.ValidationErrors
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &errs))
+	is.Equal(errs, depset.ValidationErrors{
+		{Module: "test-module", Path: "/values/image", Message: "expected string, got integer"},
+	})
+}
+
+func TestCreateDelta_MalformedDelta(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	body := bytes.NewBuffer([]byte(`{
+		"modules": {
+			"remove": ["test-module"],
+			"update": {
+				"test-module": [
+					{"op": "replace", "path": "values/image"},
+					{"op": "merge", "path": "/values"}
+				]
+			}
+		}
+	}`))
+
+	res := ExecuteRequest(m, "POST", "/orgs/test-org/apps/test-app/deltas", body, t)
+
+	is.Equal(res.Code, http.StatusUnprocessableEntity) // Should return 422
+
+	var errs depset.ValidationErrors
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &errs))
+	is.Equal(errs, depset.ValidationErrors{
+		{Module: "test-module", Message: "module is both updated and removed"},
+		{Module: "test-module", Message: "update 0: path `values/image` is not a valid json-pointer"},
+		{Module: "test-module", Message: "update 0: op `replace` requires a value"},
+		{Module: "test-module", Path: "/values", Message: "update 1: op `merge` is not supported"},
+	})
+}
+
+func TestUpdateDelta_MalformedDelta(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	body := bytes.NewBuffer([]byte(`[
+		{"modules": {"add": {"test-module": {"helmchart": "humanitec/base-module"}}, "remove": ["test-module"]}}
+	]`))
+
+	res := ExecuteRequest(m, "PATCH", "/orgs/test-org/apps/test-app/deltas/0123456789ABCDEFDEADBEEFDEADBEEFDEADBEEF", body, t)
+
+	is.Equal(res.Code, http.StatusUnprocessableEntity) // Should return 422
+
+	var errs depset.ValidationErrors
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &errs))
+	is.Equal(errs, depset.ValidationErrors{
+		{Module: "test-module", Message: "module is both added and removed"},
+	})
+}
+
+func TestCreateDelta_PolicyViolation(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+
+	body := bytes.NewBuffer([]byte(`{
+		"modules": {
+			"update": {
+				"test-module": [
+					{"op": "replace", "path": "/values/resources/cpu", "value": "500m"},
+					{"op": "replace", "path": "/values/image", "value": "nginx"}
+				]
+			}
+		}
+	}`))
+
+	s := &server{model: m, policy: testPolicy(t)}
+	res := ExecuteServerRequest(s, "test-user", "POST", fmt.Sprintf("/orgs/%s/apps/%s/deltas", orgID, appID), body, t)
+
+	is.Equal(res.Code, http.StatusForbidden) // Should return 403
+
+	var violations []policy.Violation
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &violations))
+	is.Equal(len(violations), 1)
+	is.Equal(violations[0].Module, "test-module")
+	is.Equal(violations[0].Path, "/values/resources/cpu")
+}
+
+func TestCreateDelta_PolicyAllowed(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+
+	m.
+		EXPECT().
+		insertDelta(gomock.Eq(orgID), gomock.Eq(appID), false, gomock.Any(), gomock.Any()).
+		Return("new-delta-id", nil).
+		Times(1)
+
+	body := bytes.NewBuffer([]byte(`{
+		"modules": {
+			"update": {
+				"test-module": [
+					{"op": "replace", "path": "/values/resources/cpu", "value": "500m"}
+				]
+			}
+		}
+	}`))
+
+	s := &server{model: m, policy: testPolicy(t)}
+	res := ExecuteServerRequest(s, "platform-user", "POST", fmt.Sprintf("/orgs/%s/apps/%s/deltas", orgID, appID), body, t)
+
+	is.Equal(res.Code, http.StatusOK) // Should return 200
+}
+
+func TestLockDelta(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	lockedAt := time.Date(2020, 3, 5, 12, 0, 0, 0, time.UTC)
+	dw := DeltaWrapper{
+		ID:     "0123456789ABCDEFDEADBEEFDEADBEEFDEADBEEF",
+		Locked: true,
+		Metadata: DeltaMetadata{
+			CreatedBy: "test-user",
+			LockedBy:  "release-bot",
+			LockedAt:  &lockedAt,
+		},
+		Delta: depset.Delta{
+			Modules: depset.ModuleDeltas{
+				Remove: []string{"test-module"},
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		updateDeltaLock(orgID, appID, dw.ID, true, "release-bot", gomock.Any()).
+		Return(nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		selectDelta(orgID, appID, dw.ID).
+		Return(dw, nil).
+		Times(1)
+
+	res := ExecuteServerRequest(&server{model: m}, "release-bot", "POST", fmt.Sprintf("/orgs/%s/apps/%s/deltas/%s/lock", orgID, appID, dw.ID), nil, t)
+
+	is.Equal(res.Code, http.StatusOK) // Should return 200
+
+	var returned DeltaWrapper
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &returned))
+	is.True(returned.Locked)
+	is.Equal(returned.Metadata.LockedBy, "release-bot")
+	is.True(returned.Metadata.LockedAt != nil) // Lock time should be recorded
+}
+
+func TestUnlockDelta(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	dw := DeltaWrapper{
+		ID: "0123456789ABCDEFDEADBEEFDEADBEEFDEADBEEF",
+		Metadata: DeltaMetadata{
+			CreatedBy: "test-user",
+		},
+		Delta: depset.Delta{
+			Modules: depset.ModuleDeltas{
+				Remove: []string{"test-module"},
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		updateDeltaLock(orgID, appID, dw.ID, false, "UNKNOWN", gomock.Any()).
+		Return(nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		selectDelta(orgID, appID, dw.ID).
+		Return(dw, nil).
+		Times(1)
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/deltas/%s/unlock", orgID, appID, dw.ID), nil, t)
+
+	is.Equal(res.Code, http.StatusOK) // Should return 200
+
+	var returned DeltaWrapper
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &returned))
+	is.Equal(returned, dw)
+}
+
+func TestLockDelta_AlreadyLocked(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	lockedAt := time.Date(2020, 3, 5, 12, 0, 0, 0, time.UTC)
+	dw := DeltaWrapper{
+		ID:     "0123456789ABCDEFDEADBEEFDEADBEEFDEADBEEF",
+		Locked: true,
+		Metadata: DeltaMetadata{
+			CreatedBy: "test-user",
+			LockedBy:  "release-bot",
+			LockedAt:  &lockedAt,
+		},
+		Delta: depset.Delta{
+			Modules: depset.ModuleDeltas{
+				Remove: []string{"test-module"},
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		updateDeltaLock(orgID, appID, dw.ID, true, "someone-else", gomock.Any()).
+		Return(nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		selectDelta(orgID, appID, dw.ID).
+		Return(dw, nil).
+		Times(1)
+
+	res := ExecuteServerRequest(&server{model: m}, "someone-else", "POST", fmt.Sprintf("/orgs/%s/apps/%s/deltas/%s/lock", orgID, appID, dw.ID), nil, t)
+
+	is.Equal(res.Code, http.StatusOK) // Should return 200
+
+	var returned DeltaWrapper
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &returned))
+	is.Equal(returned.Metadata.LockedBy, "release-bot") // Original lock should be kept
+}
+
+func TestLockedDeltaCannotBeChanged(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	lockedAt := time.Date(2020, 3, 5, 12, 0, 0, 0, time.UTC)
+	dw := DeltaWrapper{
+		ID:     "0123456789ABCDEFDEADBEEFDEADBEEFDEADBEEF",
+		Locked: true,
+		Metadata: DeltaMetadata{
+			CreatedBy: "test-user",
+			LockedBy:  "release-bot",
+			LockedAt:  &lockedAt,
+		},
+		Delta: depset.Delta{
+			Modules: depset.ModuleDeltas{
+				Remove: []string{"test-module"},
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		selectDelta(orgID, appID, dw.ID).
+		Return(dw, nil).
+		Times(3)
+
+	url := fmt.Sprintf("/orgs/%s/apps/%s/deltas/%s", orgID, appID, dw.ID)
+
+	res := ExecuteRequest(m, "PUT", url, bytes.NewBuffer([]byte(`{"modules": {"remove": ["other-module"]}}`)), t)
+	is.Equal(res.Code, http.StatusConflict) // PUT should return 409
+
+	res = ExecuteRequest(m, "PATCH", url, bytes.NewBuffer([]byte(`[{"modules": {"remove": ["other-module"]}}]`)), t)
+	is.Equal(res.Code, http.StatusConflict) // PATCH should return 409
+
+	res = ExecuteRequest(m, "POST", url+"/rebase?from=0&onto=0", nil, t)
+	is.Equal(res.Code, http.StatusConflict) // rebase should return 409
+}
+
+func TestReplaceDelta_LockedAfterRead(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	dw := DeltaWrapper{
+		ID: "0123456789ABCDEFDEADBEEFDEADBEEFDEADBEEF",
+		Metadata: DeltaMetadata{
+			CreatedBy: "test-user",
+		},
+		Delta: depset.Delta{
+			Modules: depset.ModuleDeltas{
+				Remove: []string{"test-module"},
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		selectDelta(orgID, appID, dw.ID).
+		Return(dw, nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		updateDelta(orgID, appID, dw.ID, gomock.Any(), gomock.Any()).
+		Return(ErrLocked).
+		Times(1)
+
+	res := ExecuteRequest(m, "PUT", fmt.Sprintf("/orgs/%s/apps/%s/deltas/%s", orgID, appID, dw.ID), bytes.NewBuffer([]byte(`{"modules": {"remove": ["other-module"]}}`)), t)
+
+	is.Equal(res.Code, http.StatusConflict) // Should return 409
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"humanitec.io/deploymentset-svc/pkg/invariant"
+)
+
+// getInvariants returns a handler which returns the invariants attached to the specified app.
+//
+// The handler expects the organization to be defined by a parameter "orgId" and app by "appId"
+func (s *server) getInvariants() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		invariants, err := s.model.selectInvariants(params["orgId"], params["appId"])
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+
+		// Always return a list, even if the app has no invariants.
+		if invariants == nil {
+			invariants = invariant.Invariants{}
+		}
+		writeAsJSON(w, http.StatusOK, invariants)
+	}
+}
+
+// replaceInvariants returns a handler which replaces the invariants attached to the specified app.
+//
+// The handler expects the organization to be defined by a parameter "orgId" and app by "appId".
+//
+// The new list of invariants should be provided in the body.
+//
+// The handler returns the following status codes:
+//
+// 204 Invariants sucessfully replaced.
+//
+// 422 The invariants are malformed; body of response describes the problem
+func (s *server) replaceInvariants() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		var invariants invariant.Invariants
+		if r.Body == nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		err := json.NewDecoder(r.Body).Decode(&invariants)
+		if nil != err {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		if err := invariants.Validate(); err != nil {
+			writeAsJSON(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+
+		err = s.model.updateInvariants(params["orgId"], params["appId"], invariants)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
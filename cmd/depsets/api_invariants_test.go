@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/matryer/is"
+	"humanitec.io/deploymentset-svc/pkg/invariant"
+)
+
+func TestGetInvariants(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	invariants := invariant.Invariants{
+		{Name: "no-latest", Expression: `!endsWith(value('/values/image'), ':latest')`},
+	}
+
+	m.
+		EXPECT().
+		selectInvariants(gomock.Eq(orgID), gomock.Eq(appID)).
+		Return(invariants, nil).
+		Times(1)
+
+	res := ExecuteRequest(m, "GET", fmt.Sprintf("/orgs/%s/apps/%s/invariants", orgID, appID), nil, t)
+
+	is.Equal(res.Code, http.StatusOK) // Should return 200
+
+	var returned invariant.Invariants
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &returned))
+	is.Equal(returned, invariants)
+}
+
+func TestGetInvariants_None(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+
+	m.
+		EXPECT().
+		selectInvariants(gomock.Eq(orgID), gomock.Eq(appID)).
+		Return(nil, nil).
+		Times(1)
+
+	res := ExecuteRequest(m, "GET", fmt.Sprintf("/orgs/%s/apps/%s/invariants", orgID, appID), nil, t)
+
+	is.Equal(res.Code, http.StatusOK) // Should return 200
+	is.Equal(res.Body.String(), `[]`) // Should return an empty list
+}
+
+func TestReplaceInvariants(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	invariants := invariant.Invariants{
+		{Name: "replicas", Modules: "web-*", Expression: `value('/values/replicas') >= 1`},
+	}
+
+	m.
+		EXPECT().
+		updateInvariants(gomock.Eq(orgID), gomock.Eq(appID), gomock.Eq(invariants)).
+		Return(nil).
+		Times(1)
+
+	buf, err := json.Marshal(invariants)
+	is.NoErr(err)
+	res := ExecuteRequest(m, "PUT", fmt.Sprintf("/orgs/%s/apps/%s/invariants", orgID, appID), bytes.NewBuffer(buf), t)
+
+	is.Equal(res.Code, http.StatusNoContent) // Should return 204
+}
+
+func TestReplaceInvariants_Invalid(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+
+	body := bytes.NewBuffer([]byte(`[{"name": "replicas", "expression": "value('/values/replicas') >="}]`))
+	res := ExecuteRequest(m, "PUT", fmt.Sprintf("/orgs/%s/apps/%s/invariants", orgID, appID), body, t)
+
+	is.Equal(res.Code, http.StatusUnprocessableEntity) // Should return 422
+}
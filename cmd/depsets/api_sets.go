@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"humanitec.io/deploymentset-svc/pkg/depset"
@@ -30,6 +32,16 @@ func isZeroHash(h string) bool {
 	return true
 }
 
+// fetchRawSet returns the set with the specified ID in an app. The null set is returned for zero hashes without
+// hitting the model.
+// The ErrNotFound sentinal error is returned if the set could not be found.
+func (s *server) fetchRawSet(orgID, appID, setID string) (depset.Set, error) {
+	if isZeroHash(setID) {
+		return depset.Set{}, nil
+	}
+	return s.model.selectRawSet(orgID, appID, setID)
+}
+
 // listSets returns a handler which returns a list of all the sets in the specified app.
 //
 // The handler expects the organization to be defined by a parameter "orgId" and app by "appId"
@@ -114,6 +126,8 @@ func (s *server) getSet() http.HandlerFunc {
 //
 // The handler expects the organization to be defined by a parameter "orgId", the app by "appId", the left set by "leftSetId" and right set by "rightSetId"
 //
+// If the query parameter "format" is "text" or the Accept header asks for "text/plain", the Delta is rendered as a
+// unified diff with depset.Delta.Render. The query parameter "color" set to "true" adds ANSI colors to the text.
 //
 // The handler returns the following status codes:
 //
@@ -150,6 +164,19 @@ func (s *server) diffSets() http.HandlerFunc {
 
 		delta := leftSet.Diff(rightSet)
 
+		if wantsText(r) {
+			text, err := delta.Render(rightSet, r.URL.Query().Get("color") == "true")
+			if err != nil {
+				log.Println(err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(text))
+			return
+		}
+
 		writeAsJSON(w, http.StatusOK, delta)
 	}
 }
@@ -158,7 +185,8 @@ func (s *server) diffSets() http.HandlerFunc {
 //
 // The handler expects the organization to be defined by a parameter "orgId", the app by "appId" and the set by "setId"
 //
-// The Delta should be provided in the body.
+// The Delta should be provided in the body. If the query parameter "strict" is "true", the delta is applied with
+// depset.Set.ApplyStrict.
 //
 // The handler returns the following status codes:
 //
@@ -166,12 +194,18 @@ func (s *server) diffSets() http.HandlerFunc {
 //
 // 400 Delta is not compatible with set
 //
+// 403 The user is not allowed to make some of the changes in the Delta; body of response is the list of policy
+// violations
+//
 // 404 Set was not found
 //
-// 422 Delta was malformed
+// 409 The preconditions of the Delta do not hold for the set, or the Delta conflicts with the set in strict mode; body
+// of response is the list of conflicts
+//
+// 422 Delta was malformed, generates invalid modules or breaks the invariants of the app; body of response is the list
+// of validation errors if the delta or the modules are invalid, or the list of failed invariants
 func (s *server) applyDelta() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params := mux.Vars(r)
 		if r.Body == nil {
 			w.WriteHeader(http.StatusUnprocessableEntity)
 			return
@@ -183,37 +217,222 @@ func (s *server) applyDelta() http.HandlerFunc {
 			return
 		}
 
-		var set depset.Set
-		if !isZeroHash(params["setId"]) {
-			set, err = s.model.selectRawSet(params["orgId"], params["appId"], params["setId"])
+		newSetID, ok := s.applyDeltaToSet(w, r, delta)
+		if !ok {
+			return
+		}
+		writeAsJSON(w, http.StatusOK, newSetID)
+	}
+}
+
+// applyStoredDelta returns a handler which applies a stored delta to a specified set. Unlike fetching the delta and
+// passing it to applyDelta, the delta is read and applied in a single request. The resulting set is recorded in the
+// metadata of the delta without writing the rest of it.
+//
+// The handler expects the organization to be defined by a parameter "orgId", the app by "appId", the set by "setId"
+// and the delta by "deltaId".
+//
+// If the query parameter "lock" is "true", the delta is also locked so that it cannot be changed after it has been
+// applied. The query parameter "strict" is handled as for applyDelta.
+//
+// The handler returns the same status codes as applyDelta, except that 404 is also returned if the delta was not
+// found and 409 if the delta was changed while it was being applied. The delta is not updated or locked if it could
+// not be applied.
+func (s *server) applyStoredDelta() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		deltaWrapper, err := s.model.selectDelta(params["orgId"], params["appId"], params["deltaId"])
+		if errors.Is(err, ErrNotFound) {
+			writeAsJSON(w, http.StatusNotFound, fmt.Sprintf(`Delta with ID "%s" not available in Application "%s/%s".`, params["deltaId"], params["orgId"], params["appId"]))
+			return
+		} else if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+
+		newSetID, ok := s.applyDeltaToSet(w, r, deltaWrapper.Delta)
+		if !ok {
+			return
+		}
+
+		application := DeltaApplication{
+			SetID:       params["setId"],
+			ResultSetID: newSetID,
+			AppliedBy:   getUser(r),
+			AppliedAt:   time.Now().UTC(),
+		}
+		err = s.model.recordDeltaApplication(params["orgId"], params["appId"], params["deltaId"], deltaWrapper.Metadata.LastModifiedAt, application, r.URL.Query().Get("lock") == "true")
+		if errors.Is(err, ErrModified) {
+			writeAsJSON(w, http.StatusConflict, fmt.Sprintf(`Delta with ID "%s" was changed while it was being applied.`, params["deltaId"]))
+			return
+		} else if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+
+		writeAsJSON(w, http.StatusOK, newSetID)
+	}
+}
+
+// applyDeltaToSet applies delta to the set identified by the "setId" parameter of r and stores the resulting set. The
+// checks and status codes are those described for applyDelta.
+//
+// The ID of the resulting set is returned. If ok is false, a response describing the problem has already been written
+// to w.
+func (s *server) applyDeltaToSet(w http.ResponseWriter, r *http.Request, delta depset.Delta) (newSetID string, ok bool) {
+	params := mux.Vars(r)
+
+	if errs := delta.Validate(); len(errs) > 0 {
+		writeAsJSON(w, http.StatusUnprocessableEntity, errs)
+		return "", false
+	}
+
+	var set depset.Set
+	var err error
+	if !isZeroHash(params["setId"]) {
+		set, err = s.model.selectRawSet(params["orgId"], params["appId"], params["setId"])
+		if err == ErrNotFound {
+			writeAsJSON(w, http.StatusNotFound, fmt.Sprintf(`"Set with ID \"%s\" does not exist."`, params["setId"]))
+			return "", false
+		} else if err != nil {
+			w.WriteHeader(500)
+			return "", false
+		}
+	}
+	if violations := s.policy.Check(getUser(r), set, delta); len(violations) > 0 {
+		writeAsJSON(w, http.StatusForbidden, violations)
+		return "", false
+	}
+
+	if len(delta.Modules.Add) == 0 && len(delta.Modules.Remove) == 0 && len(delta.Modules.Update) == 0 {
+		// Short circuit for the empty delta, which still has to meet its preconditions
+		if err := set.CheckPreconditions(delta); err != nil {
+			var conflictErr *depset.ConflictError
+			if errors.As(err, &conflictErr) {
+				writeAsJSON(w, http.StatusConflict, conflictErr.Conflicts)
+				return "", false
+			}
+			writeAsJSON(w, http.StatusBadRequest, "Delta is not compatible with Set")
+			return "", false
+		}
+		if isZeroHash(params["setId"]) {
+			return "0000000000000000000000000000000000000000", true
+		}
+		return params["setId"], true
+	}
+
+	newSw := SetWrapper{}
+	if r.URL.Query().Get("strict") == "true" {
+		newSw.Set, err = set.ApplyStrict(delta)
+	} else {
+		newSw.Set, err = set.Apply(delta)
+	}
+	if err != nil {
+		var conflictErr *depset.ConflictError
+		if errors.As(err, &conflictErr) {
+			writeAsJSON(w, http.StatusConflict, conflictErr.Conflicts)
+			return "", false
+		}
+		writeAsJSON(w, http.StatusBadRequest, "Delta is not compatible with Set")
+		return "", false
+	}
+
+	// Only modules changed by the delta are validated so that existing invalid modules do not block changes to
+	// other modules.
+	errs := newSw.Set.ValidateChangedModules(s.strictModules, delta)
+	errs = append(errs, newSw.Set.ValidateValues(s.schemas, delta.ChangedModules()...)...)
+	if len(errs) > 0 {
+		writeAsJSON(w, http.StatusUnprocessableEntity, errs)
+		return "", false
+	}
+
+	invariants, err := s.model.selectInvariants(params["orgId"], params["appId"])
+	if err != nil {
+		w.WriteHeader(500)
+		return "", false
+	}
+	if violations := invariants.Check(newSw.Set); len(violations) > 0 {
+		writeAsJSON(w, http.StatusUnprocessableEntity, violations)
+		return "", false
+	}
+	newSw.ID = newSw.Set.Hash()
+
+	err = s.model.insertSet(params["orgId"], params["appId"], newSw)
+	if err != nil && err != ErrAlreadyExists {
+		w.WriteHeader(500)
+		return "", false
+	}
+
+	return newSw.ID, true
+}
+
+// mergeSets returns a handler which performs a three-way merge of two sets which were derrived from a common base set.
+//
+// The handler expects the organization to be defined by a parameter "orgId", the app by "appId", the base set by
+// "baseSetId" and the two derrived sets by "oursSetId" and "theirsSetId".
+//
+// The handler returns the following status codes:
+//
+// 200 Sets merged; body of response is new set ID
+//
+// 404 One of the sets was not found
+//
+// 409 The sets have conflicting changes; body of response is the list of conflicts
+//
+// 422 The merged set is not valid or does not meet the invariants of the app; body of response is the list of
+// validation errors or invariant violations
+func (s *server) mergeSets() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		sets := make(map[string]depset.Set)
+		for _, setParam := range []string{"baseSetId", "oursSetId", "theirsSetId"} {
+			set, err := s.fetchRawSet(params["orgId"], params["appId"], params[setParam])
 			if err == ErrNotFound {
-				writeAsJSON(w, http.StatusNotFound, fmt.Sprintf(`"Set with ID \"%s\" does not exist."`, params["setId"]))
+				writeAsJSON(w, http.StatusNotFound, fmt.Sprintf(`Set with ID "%s" not available in Application "%s/%s".`, params[setParam], params["orgId"], params["appId"]))
 				return
 			} else if err != nil {
 				w.WriteHeader(500)
 				return
 			}
+			sets[setParam] = set
 		}
 
-		if len(delta.Modules.Add) == 0 && len(delta.Modules.Remove) == 0 && len(delta.Modules.Update) == 0 {
-			// Short circuit for the empty delta
-			w.WriteHeader(200)
-			if isZeroHash(params["setId"]) {
-				fmt.Fprintf(w, `"0000000000000000000000000000000000000000"`)
-			} else {
-				fmt.Fprintf(w, `"%s"`, params["setId"])
+		merged, err := depset.Merge(sets["baseSetId"], sets["oursSetId"], sets["theirsSetId"])
+		if err != nil {
+			var conflictErr *depset.ConflictError
+			if errors.As(err, &conflictErr) {
+				writeAsJSON(w, http.StatusConflict, conflictErr.Conflicts)
+				return
 			}
+			w.WriteHeader(500)
 			return
 		}
 
-		newSw := SetWrapper{}
-		newSw.Set, err = set.Apply(delta)
+		// As when applying a delta, only the modules changed by the merge are validated.
+		changes := merged.Diff(sets["baseSetId"])
+		errs := merged.ValidateChangedModules(s.strictModules, changes)
+		errs = append(errs, merged.ValidateValues(s.schemas, changes.ChangedModules()...)...)
+		if len(errs) > 0 {
+			writeAsJSON(w, http.StatusUnprocessableEntity, errs)
+			return
+		}
+
+		invariants, err := s.model.selectInvariants(params["orgId"], params["appId"])
 		if err != nil {
-			writeAsJSON(w, http.StatusBadRequest, "Delta is not compatible with Set")
+			w.WriteHeader(500)
+			return
+		}
+		if violations := invariants.Check(merged); len(violations) > 0 {
+			writeAsJSON(w, http.StatusUnprocessableEntity, violations)
 			return
 		}
-		newSw.ID = newSw.Set.Hash()
 
+		newSw := SetWrapper{
+			ID:  merged.Hash(),
+			Set: merged,
+		}
 		err = s.model.insertSet(params["orgId"], params["appId"], newSw)
 		if err != nil && err != ErrAlreadyExists {
 			w.WriteHeader(500)
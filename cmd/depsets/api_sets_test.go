@@ -8,10 +8,14 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/matryer/is"
 	"humanitec.io/deploymentset-svc/pkg/depset"
+	"humanitec.io/deploymentset-svc/pkg/invariant"
+	"humanitec.io/deploymentset-svc/pkg/jsonschema"
+	"humanitec.io/deploymentset-svc/pkg/policy"
 )
 
 // NOTE: *_mock.go files are generated via the following commands:
@@ -37,9 +41,16 @@ func (s *justSet) String() string {
 }
 
 func ExecuteRequest(m modeler, method, url string, body *bytes.Buffer, t *testing.T) *httptest.ResponseRecorder {
-	server := server{
-		model: m,
-	}
+	return ExecuteRequestWithSchemas(m, nil, method, url, body, t)
+}
+
+func ExecuteRequestWithSchemas(m modeler, schemas depset.Schemas, method, url string, body *bytes.Buffer, t *testing.T) *httptest.ResponseRecorder {
+	return ExecuteServerRequest(&server{model: m, schemas: schemas}, "", method, url, body, t)
+}
+
+// ExecuteServerRequest executes a request against the supplied server. If user is not empty, the request is made on
+// behalf of that user.
+func ExecuteServerRequest(server *server, user, method, url string, body *bytes.Buffer, t *testing.T) *httptest.ResponseRecorder {
 	server.setupRoutes()
 
 	var req *http.Request
@@ -52,6 +63,9 @@ func ExecuteRequest(m modeler, method, url string, body *bytes.Buffer, t *testin
 	if err != nil {
 		t.Errorf("creating request: %v", err)
 	}
+	if user != "" {
+		req.Header.Set("From", user)
+	}
 
 	w := httptest.NewRecorder()
 
@@ -248,7 +262,8 @@ func TestApplyDelta(t *testing.T) {
 		Modules: depset.ModuleDeltas{
 			Add: map[string]map[string]interface{}{
 				"test-module02": map[string]interface{}{
-					"version": "TEST_VERSION02",
+					"helmchart": "humanitec/base-module",
+					"version":   "TEST_VERSION02",
 				},
 			},
 		},
@@ -268,9 +283,11 @@ func TestApplyDelta(t *testing.T) {
 				"version": "TEST_VERSION01",
 			},
 			"test-module02": map[string]interface{}{
-				"version": "TEST_VERSION02",
+				"helmchart": "humanitec/base-module",
+				"version":   "TEST_VERSION02",
 			},
 		},
+		Version: depset.CurrentVersion,
 	}
 
 	m.
@@ -279,6 +296,12 @@ func TestApplyDelta(t *testing.T) {
 		Return(inputSet, nil).
 		Times(1)
 
+	m.
+		EXPECT().
+		selectInvariants(gomock.Eq(orgID), gomock.Eq(appID)).
+		Return(invariant.Invariants{}, nil).
+		Times(1)
+
 	m.
 		EXPECT().
 		insertSet(gomock.Eq(orgID), gomock.Eq(appID), JustSetEq(expectedSet)).
@@ -296,7 +319,7 @@ func TestApplyDelta(t *testing.T) {
 	var outputID string
 	json.Unmarshal(res.Body.Bytes(), &outputID)
 
-	is.Equal(outputID, "mgwhntlRovaKCM30yBlQrLOnzWz9w6nZ-b82hSeIrfQ") // Returned Sets should match initilal sets
+	is.Equal(outputID, "jcs-sha256.3C65ombZkw7mVsW2hah9ogi8nBhyIzaG9jzaOTqiEE4") // Returned Sets should match initilal sets
 
 }
 
@@ -313,7 +336,8 @@ func TestApplyDelta_ToZeroSet(t *testing.T) {
 		Modules: depset.ModuleDeltas{
 			Add: map[string]map[string]interface{}{
 				"test-module01": map[string]interface{}{
-					"version": "TEST_VERSION01",
+					"helmchart": "humanitec/base-module",
+					"version":   "TEST_VERSION01",
 				},
 			},
 		},
@@ -323,11 +347,19 @@ func TestApplyDelta_ToZeroSet(t *testing.T) {
 	expectedSet := depset.Set{
 		Modules: map[string]map[string]interface{}{
 			"test-module01": map[string]interface{}{
-				"version": "TEST_VERSION01",
+				"helmchart": "humanitec/base-module",
+				"version":   "TEST_VERSION01",
 			},
 		},
+		Version: depset.CurrentVersion,
 	}
 
+	m.
+		EXPECT().
+		selectInvariants(gomock.Eq(orgID), gomock.Eq(appID)).
+		Return(invariant.Invariants{}, nil).
+		Times(1)
+
 	m.
 		EXPECT().
 		insertSet(gomock.Eq(orgID), gomock.Eq(appID), JustSetEq(expectedSet)).
@@ -345,7 +377,7 @@ func TestApplyDelta_ToZeroSet(t *testing.T) {
 	var outputID string
 	json.Unmarshal(res.Body.Bytes(), &outputID)
 
-	is.Equal(outputID, "CxtOgS619lvcCDnMqRDMAf5b7-huv5qkc74b8W4laOY")
+	is.Equal(outputID, "jcs-sha256.RugtoTi47YZkbW0ggFImxdqG8hPNrzHxrJpQeX9OnCg")
 
 }
 
@@ -362,7 +394,8 @@ func TestApplyDelta_SetAlreadyExists(t *testing.T) {
 		Modules: depset.ModuleDeltas{
 			Add: map[string]map[string]interface{}{
 				"test-module01": map[string]interface{}{
-					"version": "TEST_VERSION01",
+					"helmchart": "humanitec/base-module",
+					"version":   "TEST_VERSION01",
 				},
 			},
 		},
@@ -372,11 +405,19 @@ func TestApplyDelta_SetAlreadyExists(t *testing.T) {
 	expectedSet := depset.Set{
 		Modules: map[string]map[string]interface{}{
 			"test-module01": map[string]interface{}{
-				"version": "TEST_VERSION01",
+				"helmchart": "humanitec/base-module",
+				"version":   "TEST_VERSION01",
 			},
 		},
+		Version: depset.CurrentVersion,
 	}
 
+	m.
+		EXPECT().
+		selectInvariants(gomock.Eq(orgID), gomock.Eq(appID)).
+		Return(invariant.Invariants{}, nil).
+		Times(1)
+
 	m.
 		EXPECT().
 		insertSet(gomock.Eq(orgID), gomock.Eq(appID), JustSetEq(expectedSet)).
@@ -394,7 +435,7 @@ func TestApplyDelta_SetAlreadyExists(t *testing.T) {
 	var outputID string
 	json.Unmarshal(res.Body.Bytes(), &outputID)
 
-	is.Equal(outputID, "CxtOgS619lvcCDnMqRDMAf5b7-huv5qkc74b8W4laOY")
+	is.Equal(outputID, "jcs-sha256.RugtoTi47YZkbW0ggFImxdqG8hPNrzHxrJpQeX9OnCg")
 
 }
 
@@ -479,6 +520,159 @@ func TestApplyDelta_DeltaNotCompatibleToInputSet(t *testing.T) {
 	is.Equal(res.Code, http.StatusBadRequest) // Should return 400
 }
 
+func TestApplyDelta_MoveCopyTest(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	inputSetID := "27036a0c4ce1cda91addbd67ca65d499dfbeb9d0"
+	inputSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"version": "TEST_VERSION01",
+				"old-key": "VALUE",
+			},
+		},
+	}
+
+	expectedSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"version":     "TEST_VERSION01",
+				"new-key":     "VALUE",
+				"version-tag": "TEST_VERSION01",
+			},
+		},
+		Version: depset.CurrentVersion,
+	}
+
+	m.
+		EXPECT().
+		selectRawSet(gomock.Eq(orgID), gomock.Eq(appID), inputSetID).
+		Return(inputSet, nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		selectInvariants(gomock.Eq(orgID), gomock.Eq(appID)).
+		Return(invariant.Invariants{}, nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		insertSet(gomock.Eq(orgID), gomock.Eq(appID), JustSetEq(expectedSet)).
+		Return(nil).
+		Times(1)
+
+	body := bytes.NewBuffer([]byte(`{
+		"modules": {
+			"update": {
+				"test-module01": [
+					{"op": "test", "path": "/version", "value": "TEST_VERSION01"},
+					{"op": "move", "from": "/old-key", "path": "/new-key"},
+					{"op": "copy", "from": "/version", "path": "/version-tag"}
+				]
+			}
+		}
+	}`))
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s", orgID, appID, inputSetID), body, t)
+
+	is.Equal(res.Code, http.StatusOK) // Should return 200
+}
+
+func TestApplyDelta_TestFailed(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	inputSetID := "27036a0c4ce1cda91addbd67ca65d499dfbeb9d0"
+	inputSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"version": "TEST_VERSION01",
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		selectRawSet(gomock.Eq(orgID), gomock.Eq(appID), inputSetID).
+		Return(inputSet, nil).
+		Times(1)
+
+	body := bytes.NewBuffer([]byte(`{
+		"modules": {
+			"update": {
+				"test-module01": [
+					{"op": "test", "path": "/version", "value": "OTHER_VERSION"},
+					{"op": "replace", "path": "/version", "value": "NEW_VERSION"}
+				]
+			}
+		}
+	}`))
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s", orgID, appID, inputSetID), body, t)
+
+	is.Equal(res.Code, http.StatusBadRequest) // Should return 400
+}
+
+func TestApplyDelta_StrictConflicts(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	delta := depset.Delta{
+		Modules: depset.ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"test-module01": map[string]interface{}{
+					"version": "TEST_VERSION02",
+				},
+			},
+			Remove: []string{"missing-module"},
+		},
+	}
+	inputSetID := "27036a0c4ce1cda91addbd67ca65d499dfbeb9d0"
+	inputSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"version": "TEST_VERSION01",
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		selectRawSet(gomock.Eq(orgID), gomock.Eq(appID), inputSetID).
+		Return(inputSet, nil).
+		Times(1)
+
+	buf, err := json.Marshal(delta)
+	is.NoErr(err)
+	body := bytes.NewBuffer(buf)
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s?strict=true", orgID, appID, inputSetID), body, t)
+
+	is.Equal(res.Code, http.StatusConflict) // Should return 409
+
+	var conflicts []depset.Conflict
+	json.Unmarshal(res.Body.Bytes(), &conflicts)
+
+	is.Equal(len(conflicts), 2) // Both conflicts should be reported
+}
+
 func TestApplyDelta_EmptyDelta(t *testing.T) {
 	is := is.New(t)
 	ctrl := gomock.NewController(t)
@@ -588,3 +782,927 @@ func TestDiff_FromEmptySet(t *testing.T) {
 
 	is.Equal(actualDelta, expected)
 }
+
+func TestDiff_Text(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	leftSetID := "4efb2d1ae4f101a1ef4e0a08705910191868c5cc"
+	leftSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"version": "TEST_VERSION_2",
+				},
+			},
+		},
+	}
+	rightSetID := "27036a0c4ce1cda91addbd67ca65d499dfbeb9d0"
+	rightSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"version": "TEST_VERSION_1",
+				},
+			},
+		},
+	}
+	expected := `--- a/test-module
++++ b/test-module
+@@ /values/version @@
+-"TEST_VERSION_1"
++"TEST_VERSION_2"
+`
+
+	m.
+		EXPECT().
+		selectRawSet(orgID, appID, leftSetID).
+		Return(leftSet, nil).
+		Times(1)
+	m.
+		EXPECT().
+		selectRawSet(orgID, appID, rightSetID).
+		Return(rightSet, nil).
+		Times(1)
+
+	res := ExecuteRequest(m, "GET", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s?diff=%s&format=text", orgID, appID, leftSetID, rightSetID), nil, t)
+
+	is.Equal(res.Code, http.StatusOK)                                       // Should return 200
+	is.Equal(res.Header().Get("Content-Type"), "text/plain; charset=utf-8") // Should be plain text
+	is.Equal(res.Body.String(), expected)
+}
+
+func TestMergeSets(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	baseSetID := "base-set"
+	oursSetID := "ours-set"
+	theirsSetID := "theirs-set"
+	baseSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"version": "TEST_VERSION01",
+			},
+		},
+	}
+	oursSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"version": "TEST_VERSION02",
+			},
+		},
+	}
+	theirsSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"version": "TEST_VERSION01",
+			},
+			"test-module02": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"version":   "TEST_VERSION01",
+			},
+		},
+	}
+	expectedSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"version": "TEST_VERSION02",
+			},
+			"test-module02": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"version":   "TEST_VERSION01",
+			},
+		},
+		Version: depset.CurrentVersion,
+	}
+
+	m.EXPECT().selectRawSet(orgID, appID, baseSetID).Return(baseSet, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, oursSetID).Return(oursSet, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, theirsSetID).Return(theirsSet, nil).Times(1)
+
+	m.
+		EXPECT().
+		selectInvariants(gomock.Eq(orgID), gomock.Eq(appID)).
+		Return(invariant.Invariants{}, nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		insertSet(gomock.Eq(orgID), gomock.Eq(appID), JustSetEq(expectedSet)).
+		Return(nil).
+		Times(1)
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s/merge?ours=%s&theirs=%s", orgID, appID, baseSetID, oursSetID, theirsSetID), nil, t)
+
+	is.Equal(res.Code, http.StatusOK) // Should return 200
+
+	var outputID string
+	json.Unmarshal(res.Body.Bytes(), &outputID)
+
+	is.Equal(outputID, expectedSet.Hash()) // Returned ID should be the ID of the merged set
+}
+
+func TestMergeSets_Conflict(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	oursSetID := "ours-set"
+	theirsSetID := "theirs-set"
+	oursSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"version": "TEST_VERSION01",
+			},
+		},
+	}
+	theirsSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"version": "TEST_VERSION02",
+			},
+		},
+	}
+
+	m.EXPECT().selectRawSet(orgID, appID, oursSetID).Return(oursSet, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, theirsSetID).Return(theirsSet, nil).Times(1)
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/0/merge?ours=%s&theirs=%s", orgID, appID, oursSetID, theirsSetID), nil, t)
+
+	is.Equal(res.Code, http.StatusConflict) // Should return 409
+
+	var conflicts []depset.Conflict
+	json.Unmarshal(res.Body.Bytes(), &conflicts)
+
+	is.Equal(conflicts, []depset.Conflict{
+		depset.Conflict{
+			Module: "test-module01",
+			Path:   "/version",
+			Reason: "changed differently in ours and theirs",
+			Ours:   "TEST_VERSION01",
+			Theirs: "TEST_VERSION02",
+		},
+	})
+}
+
+func TestMergeSets_InvalidModule(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	baseSetID := "base-set"
+	oursSetID := "ours-set"
+	theirsSetID := "theirs-set"
+	baseSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"version": "TEST_VERSION01",
+			},
+		},
+	}
+	oursSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"version": "TEST_VERSION02",
+			},
+		},
+	}
+	theirsSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"version": "TEST_VERSION01",
+			},
+			"Bad_Name!": map[string]interface{}{
+				"helmchart": 5,
+			},
+		},
+	}
+
+	m.EXPECT().selectRawSet(orgID, appID, baseSetID).Return(baseSet, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, oursSetID).Return(oursSet, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, theirsSetID).Return(theirsSet, nil).Times(1)
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s/merge?ours=%s&theirs=%s", orgID, appID, baseSetID, oursSetID, theirsSetID), nil, t)
+
+	is.Equal(res.Code, http.StatusUnprocessableEntity) // Should return 422
+
+	var errs depset.ValidationErrors
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &errs))
+	is.True(len(errs) > 0)                // There should be validation errors
+	is.Equal(errs[0].Module, "Bad_Name!") // The added module should be invalid
+}
+
+func TestMergeSets_InvariantViolated(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	baseSetID := "base-set"
+	oursSetID := "ours-set"
+	theirsSetID := "theirs-set"
+	baseSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"values": map[string]interface{}{
+					"replicas": 2.0,
+					"min":      1.0,
+				},
+			},
+		},
+	}
+	oursSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"values": map[string]interface{}{
+					"replicas": 1.0,
+					"min":      1.0,
+				},
+			},
+		},
+	}
+	theirsSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"values": map[string]interface{}{
+					"replicas": 2.0,
+					"min":      2.0,
+				},
+			},
+		},
+	}
+
+	m.EXPECT().selectRawSet(orgID, appID, baseSetID).Return(baseSet, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, oursSetID).Return(oursSet, nil).Times(1)
+	m.EXPECT().selectRawSet(orgID, appID, theirsSetID).Return(theirsSet, nil).Times(1)
+
+	m.
+		EXPECT().
+		selectInvariants(gomock.Eq(orgID), gomock.Eq(appID)).
+		Return(invariant.Invariants{
+			{Name: "replicas", Description: "replicas must be at least min", Expression: `value('/values/replicas') >= value('/values/min')`},
+		}, nil).
+		Times(1)
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s/merge?ours=%s&theirs=%s", orgID, appID, baseSetID, oursSetID, theirsSetID), nil, t)
+
+	is.Equal(res.Code, http.StatusUnprocessableEntity) // Should return 422
+
+	var violations []invariant.Violation
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &violations))
+	is.Equal(violations, []invariant.Violation{
+		{Invariant: "replicas", Module: "test-module01", Message: "replicas must be at least min"},
+	})
+}
+
+func TestMergeSets_NotFound(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+
+	m.EXPECT().selectRawSet(orgID, appID, "base-set").Return(depset.Set{}, ErrNotFound).Times(1)
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/base-set/merge?ours=ours-set&theirs=theirs-set", orgID, appID), nil, t)
+
+	is.Equal(res.Code, http.StatusNotFound) // Should return 404
+}
+
+func TestApplyDelta_InvalidModule(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	inputSetID := "27036a0c4ce1cda91addbd67ca65d499dfbeb9d0"
+	inputSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+			},
+			"Legacy_Module": map[string]interface{}{
+				"version": "TEST_VERSION",
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		selectRawSet(gomock.Eq(orgID), gomock.Eq(appID), inputSetID).
+		Return(inputSet, nil).
+		Times(1)
+
+	body := bytes.NewBuffer([]byte(`{
+		"modules": {
+			"add": {
+				"Test_Module02": {
+					"helmchart": "humanitec/base-module"
+				}
+			},
+			"update": {
+				"test-module01": [
+					{"op": "replace", "path": "/helmchart", "value": 5}
+				]
+			}
+		}
+	}`))
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s", orgID, appID, inputSetID), body, t)
+
+	is.Equal(res.Code, http.StatusUnprocessableEntity) // Should return 422
+
+	var errs depset.ValidationErrors
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &errs))
+	is.Equal(len(errs), 2)                    // Only the modules changed by the delta should be validated
+	is.Equal(errs[0].Module, "Test_Module02") // The name of the added module is invalid
+	is.Equal(errs[1].Module, "test-module01") // The helmchart of the updated module is invalid
+	is.Equal(errs[1].Path, "/helmchart")
+}
+
+func TestApplyDelta_AddedModuleWithoutHelmchart(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	inputSetID := "27036a0c4ce1cda91addbd67ca65d499dfbeb9d0"
+	inputSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"legacy-module": map[string]interface{}{
+				"version": "TEST_VERSION01",
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		selectRawSet(gomock.Eq(orgID), gomock.Eq(appID), inputSetID).
+		Return(inputSet, nil).
+		Times(1)
+
+	body := bytes.NewBuffer([]byte(`{
+		"modules": {
+			"add": {
+				"test-module02": {}
+			},
+			"update": {
+				"legacy-module": [
+					{"op": "replace", "path": "/version", "value": "TEST_VERSION02"}
+				]
+			}
+		}
+	}`))
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s", orgID, appID, inputSetID), body, t)
+
+	is.Equal(res.Code, http.StatusUnprocessableEntity) // Should return 422 even if modules are not strictly validated
+
+	var errs depset.ValidationErrors
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &errs))
+	is.Equal(errs, depset.ValidationErrors{ // Only the added module needs a helmchart
+		{Module: "test-module02", Path: "/helmchart", Message: "helmchart is required"},
+	})
+}
+
+func testSchemas(t *testing.T) depset.Schemas {
+	schema, err := jsonschema.Parse([]byte(`{
+		"type": "object",
+		"required": ["image"],
+		"properties": {
+			"image": {"type": "string"},
+			"replicas": {"type": "integer", "minimum": 1}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("parsing schema: %v", err)
+	}
+	return depset.Schemas{"humanitec/base-module": schema}
+}
+
+func testPolicy(t *testing.T) *policy.Policy {
+	p, err := policy.Parse([]byte(`{
+		"teams": {"platform": ["platform-user"]},
+		"rules": [
+			{"path": "/*/values/resources/**", "teams": ["platform"]},
+			{"path": "/*/helmchart"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("parsing policy: %v", err)
+	}
+	return p
+}
+
+func TestApplyDelta_InvalidValues(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	inputSetID := "27036a0c4ce1cda91addbd67ca65d499dfbeb9d0"
+	inputSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image":    "registry.humanitec.io/my-org/test-module01:1.0.0",
+					"replicas": 1,
+				},
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		selectRawSet(gomock.Eq(orgID), gomock.Eq(appID), inputSetID).
+		Return(inputSet, nil).
+		Times(1)
+
+	body := bytes.NewBuffer([]byte(`{
+		"modules": {
+			"update": {
+				"test-module01": [
+					{"op": "replace", "path": "/values/replicas", "value": 0}
+				]
+			}
+		}
+	}`))
+
+	res := ExecuteRequestWithSchemas(m, testSchemas(t), "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s", orgID, appID, inputSetID), body, t)
+
+	is.Equal(res.Code, http.StatusUnprocessableEntity) // Should return 422
+
+	var errs depset.ValidationErrors
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &errs))
+	is.Equal(errs, depset.ValidationErrors{
+		{Module: "test-module01", Path: "/values/replicas", Message: "must be at least 1"},
+	})
+}
+
+func TestApplyDelta_IndexOutOfRange(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	inputSetID := "27036a0c4ce1cda91addbd67ca65d499dfbeb9d0"
+	inputSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"list": []interface{}{"value-one"},
+				},
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		selectRawSet(gomock.Eq(orgID), gomock.Eq(appID), inputSetID).
+		Return(inputSet, nil).
+		Times(1)
+
+	body := bytes.NewBuffer([]byte(`{
+		"modules": {
+			"update": {
+				"test-module01": [
+					{"op": "replace", "path": "/values/list/99", "value": "value-100"}
+				]
+			}
+		}
+	}`))
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s", orgID, appID, inputSetID), body, t)
+
+	is.Equal(res.Code, http.StatusBadRequest) // Should return 400
+}
+
+func TestApplyDelta_PolicyViolation(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	inputSetID := "27036a0c4ce1cda91addbd67ca65d499dfbeb9d0"
+	inputSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+			},
+			"test-module02": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		selectRawSet(gomock.Eq(orgID), gomock.Eq(appID), inputSetID).
+		Return(inputSet, nil).
+		Times(1)
+
+	body := bytes.NewBuffer([]byte(`{
+		"modules": {
+			"add": {
+				"test-module03": {"values": {"image": "nginx"}}
+			},
+			"remove": ["test-module02"],
+			"update": {
+				"test-module01": [
+					{"op": "replace", "path": "/helmchart", "value": "humanitec/other-module"}
+				]
+			}
+		}
+	}`))
+
+	s := &server{model: m, policy: testPolicy(t)}
+	res := ExecuteServerRequest(s, "test-user", "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s", orgID, appID, inputSetID), body, t)
+
+	is.Equal(res.Code, http.StatusForbidden) // Should return 403
+
+	var violations []policy.Violation
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &violations))
+	is.Equal(violations, []policy.Violation{
+		{Module: "test-module01", Path: "/helmchart", Message: "user `test-user` may not change values matching `/*/helmchart`"},
+		{Module: "test-module02", Path: "/helmchart", Message: "user `test-user` may not change values matching `/*/helmchart`"},
+	})
+}
+
+func TestApplyDelta_InvariantViolated(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	inputSetID := "27036a0c4ce1cda91addbd67ca65d499dfbeb9d0"
+	inputSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"replicas": 1,
+				},
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		selectRawSet(gomock.Eq(orgID), gomock.Eq(appID), inputSetID).
+		Return(inputSet, nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		selectInvariants(gomock.Eq(orgID), gomock.Eq(appID)).
+		Return(invariant.Invariants{
+			{Name: "replicas", Description: "replicas must be at least 1", Expression: `value('/values/replicas') >= 1`},
+		}, nil).
+		Times(1)
+
+	body := bytes.NewBuffer([]byte(`{
+		"modules": {
+			"update": {
+				"test-module01": [
+					{"op": "replace", "path": "/values/replicas", "value": 0}
+				]
+			}
+		}
+	}`))
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s", orgID, appID, inputSetID), body, t)
+
+	is.Equal(res.Code, http.StatusUnprocessableEntity) // Should return 422
+
+	var violations []invariant.Violation
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &violations))
+	is.Equal(violations, []invariant.Violation{
+		{Invariant: "replicas", Module: "test-module01", Message: "replicas must be at least 1"},
+	})
+}
+
+func TestApplyDelta_PreconditionFailed(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	inputSetID := "27036a0c4ce1cda91addbd67ca65d499dfbeb9d0"
+	inputSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"api": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image": map[string]interface{}{"tag": "1.2.4"},
+				},
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		selectRawSet(gomock.Eq(orgID), gomock.Eq(appID), inputSetID).
+		Return(inputSet, nil).
+		Times(2)
+
+	for _, body := range []string{
+		`{
+			"modules": {
+				"update": {
+					"api": [{"op": "replace", "path": "/values/image/tag", "value": "1.3.0"}]
+				}
+			},
+			"preconditions": {
+				"values": [{"module": "api", "path": "/values/image/tag", "value": "1.2.3"}]
+			}
+		}`,
+		// Empty deltas are short circuited but must still meet their preconditions.
+		`{
+			"modules": {},
+			"preconditions": {
+				"values": [{"module": "api", "path": "/values/image/tag", "value": "1.2.3"}]
+			}
+		}`,
+	} {
+		res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s", orgID, appID, inputSetID), bytes.NewBuffer([]byte(body)), t)
+
+		is.Equal(res.Code, http.StatusConflict) // Should return 409
+
+		var conflicts []depset.Conflict
+		is.NoErr(json.Unmarshal(res.Body.Bytes(), &conflicts))
+		is.Equal(conflicts, []depset.Conflict{
+			{Module: "api", Path: "/values/image/tag", Reason: "value does not match precondition", Ours: "1.2.3", Theirs: "1.2.4"},
+		})
+	}
+}
+
+func TestApplyStoredDelta(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	inputSetID := "27036a0c4ce1cda91addbd67ca65d499dfbeb9d0"
+	inputSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module01": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"version": "TEST_VERSION01",
+				},
+			},
+		},
+	}
+	dw := DeltaWrapper{
+		ID: "0123456789abcdef0123456789abcdef",
+		Metadata: DeltaMetadata{
+			CreatedBy:      "test-user",
+			LastModifiedAt: time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC),
+			Contributers:   []string{"test-user"},
+		},
+		Delta: depset.Delta{
+			Modules: depset.ModuleDeltas{
+				Add: map[string]map[string]interface{}{
+					"test-module02": map[string]interface{}{
+						"helmchart": "humanitec/base-module",
+						"values": map[string]interface{}{
+							"version": "TEST_VERSION02",
+						},
+					},
+				},
+			},
+		},
+	}
+	expectedSetID := "jcs-sha256.3dGKNm4qnTEU-uQ-NYIpnCX7Rema7USeotX1zfVme9I"
+
+	m.
+		EXPECT().
+		selectDelta(gomock.Eq(orgID), gomock.Eq(appID), dw.ID).
+		Return(dw, nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		selectRawSet(gomock.Eq(orgID), gomock.Eq(appID), inputSetID).
+		Return(inputSet, nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		selectInvariants(gomock.Eq(orgID), gomock.Eq(appID)).
+		Return(invariant.Invariants{}, nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		insertSet(gomock.Eq(orgID), gomock.Eq(appID), gomock.Any()).
+		Return(nil).
+		Times(1)
+
+	var application DeltaApplication
+	m.
+		EXPECT().
+		recordDeltaApplication(gomock.Eq(orgID), gomock.Eq(appID), dw.ID, dw.Metadata.LastModifiedAt, gomock.Any(), true).
+		Do(func(orgID, appID, deltaID string, lastModifiedAt time.Time, a DeltaApplication, lock bool) {
+			application = a
+		}).
+		Return(nil).
+		Times(1)
+
+	res := ExecuteServerRequest(&server{model: m}, "release-bot", "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s?delta=%s&lock=true", orgID, appID, inputSetID, dw.ID), nil, t)
+
+	is.Equal(res.Code, http.StatusOK) // Should return 200
+
+	var outputID string
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &outputID))
+	is.Equal(outputID, expectedSetID)
+
+	is.Equal(application.SetID, inputSetID) // The application should be recorded
+	is.Equal(application.ResultSetID, expectedSetID)
+	is.Equal(application.AppliedBy, "release-bot")
+}
+
+func TestApplyStoredDelta_DeltaModified(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	inputSetID := "27036a0c4ce1cda91addbd67ca65d499dfbeb9d0"
+	dw := DeltaWrapper{
+		ID: "0123456789abcdef0123456789abcdef",
+		Metadata: DeltaMetadata{
+			CreatedBy:      "test-user",
+			LastModifiedAt: time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC),
+		},
+		Delta: depset.Delta{
+			Modules: depset.ModuleDeltas{
+				Add: map[string]map[string]interface{}{
+					"test-module02": map[string]interface{}{
+						"helmchart": "humanitec/base-module",
+					},
+				},
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		selectDelta(gomock.Eq(orgID), gomock.Eq(appID), dw.ID).
+		Return(dw, nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		selectRawSet(gomock.Eq(orgID), gomock.Eq(appID), inputSetID).
+		Return(depset.Set{}, nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		selectInvariants(gomock.Eq(orgID), gomock.Eq(appID)).
+		Return(invariant.Invariants{}, nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		insertSet(gomock.Eq(orgID), gomock.Eq(appID), gomock.Any()).
+		Return(nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		recordDeltaApplication(gomock.Eq(orgID), gomock.Eq(appID), dw.ID, dw.Metadata.LastModifiedAt, gomock.Any(), true).
+		Return(ErrModified).
+		Times(1)
+
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s?delta=%s&lock=true", orgID, appID, inputSetID, dw.ID), nil, t)
+
+	is.Equal(res.Code, http.StatusConflict) // Should return 409
+}
+
+func TestApplyStoredDelta_DeltaNotFound(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	m.
+		EXPECT().
+		selectDelta(gomock.Eq("test-org"), gomock.Eq("test-app"), "0123456789abcdef0123456789abcdef").
+		Return(DeltaWrapper{}, ErrNotFound).
+		Times(1)
+
+	res := ExecuteRequest(m, "POST", "/orgs/test-org/apps/test-app/sets/27036a0c4ce1cda91addbd67ca65d499dfbeb9d0?delta=0123456789abcdef0123456789abcdef", nil, t)
+
+	is.Equal(res.Code, http.StatusNotFound) // Should return 404
+}
+
+func TestApplyStoredDelta_PreconditionFailed(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockmodeler(ctrl)
+
+	orgID := "test-org"
+	appID := "test-app"
+	inputSetID := "27036a0c4ce1cda91addbd67ca65d499dfbeb9d0"
+	inputSet := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"api": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image": map[string]interface{}{"tag": "1.2.4"},
+				},
+			},
+		},
+	}
+	dw := DeltaWrapper{
+		ID: "0123456789abcdef0123456789abcdef",
+		Delta: depset.Delta{
+			Modules: depset.ModuleDeltas{
+				Update: map[string][]depset.UpdateAction{
+					"api": {{Operation: "replace", Path: "/values/image/tag", Value: "1.3.0"}},
+				},
+			},
+			Preconditions: &depset.Preconditions{
+				Values: []depset.ValuePrecondition{{Module: "api", Path: "/values/image/tag", Value: "1.2.3"}},
+			},
+		},
+	}
+
+	m.
+		EXPECT().
+		selectDelta(gomock.Eq(orgID), gomock.Eq(appID), dw.ID).
+		Return(dw, nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		selectRawSet(gomock.Eq(orgID), gomock.Eq(appID), inputSetID).
+		Return(inputSet, nil).
+		Times(1)
+
+	// No call to updateDelta is expected: the delta must not be locked or changed if it could not be applied.
+	res := ExecuteRequest(m, "POST", fmt.Sprintf("/orgs/%s/apps/%s/sets/%s?delta=%s&lock=true", orgID, appID, inputSetID, dw.ID), nil, t)
+
+	is.Equal(res.Code, http.StatusConflict) // Should return 409
+}
@@ -60,6 +60,21 @@ func writeAsJSON(w http.ResponseWriter, statusCode int, obj interface{}) {
 	w.Write(jsonObj)
 }
 
+// wantsText returns true if the client asked for a plain text response, either via the query parameter "format" set to
+// "text" or via an Accept header including "text/plain".
+func wantsText(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "text" {
+		return true
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.Split(accept, ";")[0])
+		if mediaType == "text/plain" {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *server) isAlive() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
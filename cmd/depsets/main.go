@@ -4,10 +4,13 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gorilla/handlers"
 	_ "github.com/lib/pq"
 	"humanitec.io/deploymentset-svc/pkg/depset"
+	"humanitec.io/deploymentset-svc/pkg/invariant"
+	"humanitec.io/deploymentset-svc/pkg/policy"
 )
 
 type modeler interface {
@@ -18,13 +21,22 @@ type modeler interface {
 	selectUnscopedRawSet(setID string) (depset.Set, error)
 	selectAllDeltas(orgID string, appID string) ([]DeltaWrapper, error)
 	insertDelta(orgID string, appID string, locked bool, metadata DeltaMetadata, content depset.Delta) (string, error)
-	updateDelta(orgID, appID, deltaID string, locked bool, metadata DeltaMetadata, content depset.Delta) error
+	updateDelta(orgID, appID, deltaID string, metadata DeltaMetadata, content depset.Delta) error
+	updateDeltaLock(orgID, appID, deltaID string, locked bool, lockedBy string, lockedAt time.Time) error
+	recordDeltaApplication(orgID, appID, deltaID string, lastModifiedAt time.Time, application DeltaApplication, lock bool) error
 	selectDelta(orgID string, appID string, deltaID string) (DeltaWrapper, error)
+	selectInvariants(orgID string, appID string) (invariant.Invariants, error)
+	updateInvariants(orgID string, appID string, invariants invariant.Invariants) error
 }
 
 type server struct {
-	model  modeler
-	router http.Handler
+	model   modeler
+	router  http.Handler
+	schemas depset.Schemas
+	policy  *policy.Policy
+
+	// strictModules requires changed modules to have exactly the shape described in doc/data-format.md.
+	strictModules bool
 }
 
 func main() {
@@ -33,6 +45,26 @@ func main() {
 	log.Println("Setting up Model")
 	s.setupModel()
 
+	if schemaDir := os.Getenv("SCHEMA_DIR"); schemaDir != "" {
+		log.Printf("Loading Schemas from %s", schemaDir)
+		schemas, err := loadSchemas(schemaDir)
+		if err != nil {
+			log.Fatalf("Unable to load schemas: %v", err)
+		}
+		s.schemas = schemas
+	}
+
+	if policyFile := os.Getenv("POLICY_FILE"); policyFile != "" {
+		log.Printf("Loading Policy from %s", policyFile)
+		p, err := loadPolicy(policyFile)
+		if err != nil {
+			log.Fatalf("Unable to load policy: %v", err)
+		}
+		s.policy = p
+	}
+
+	s.strictModules = os.Getenv("STRICT_MODULES") == "true"
+
 	log.Println("Setting up Routes")
 	s.setupRoutes()
 
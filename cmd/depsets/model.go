@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"humanitec.io/deploymentset-svc/pkg/depset"
+	"humanitec.io/deploymentset-svc/pkg/invariant"
 )
 
 // model is the underlying type for the entire model.
@@ -25,6 +26,12 @@ var ErrNotFound = errors.New("not found")
 // ErrAlreadyExists indicates that this resource already exists
 var ErrAlreadyExists = errors.New("already exists")
 
+// ErrLocked indicates that the resource is locked and cannot be changed
+var ErrLocked = errors.New("locked")
+
+// ErrModified indicates that the resource was changed since it was read
+var ErrModified = errors.New("modified")
+
 // A persistable version of a depset.Set
 type persistableSet depset.Set
 
@@ -34,13 +41,24 @@ func (s persistableSet) Value() (driver.Value, error) {
 }
 
 // Provide a way for depset.Set implement the sql.Scanner interface.
+// Sets stored at an older version are upgraded to depset.CurrentVersion.
 func (s *persistableSet) Scan(value interface{}) error {
 	b, ok := value.([]byte)
 	if !ok {
 		return errors.New("type assertion to []byte failed")
 	}
 
-	return json.Unmarshal(b, &s)
+	var set depset.Set
+	err := json.Unmarshal(b, &set)
+	if err != nil {
+		return err
+	}
+	set, err = depset.Upgrade(set)
+	if err != nil {
+		return fmt.Errorf("upgrade set: %w", err)
+	}
+	*s = persistableSet(set)
+	return nil
 }
 
 // persistableSetMetadata is a persistable version of SetMetadata
@@ -97,6 +115,24 @@ func (d *persistableDeltaMetadata) Scan(value interface{}) error {
 	return json.Unmarshal(b, &d)
 }
 
+// persistableInvariants is a persistable version of invariant.Invariants
+type persistableInvariants invariant.Invariants
+
+// Provide a way for invariant.Invariants to implement the driver.Valuer interface.
+func (i persistableInvariants) Value() (driver.Value, error) {
+	return json.Marshal(i)
+}
+
+// Provide a way for invariant.Invariants implement the sql.Scanner interface.
+func (i *persistableInvariants) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, &i)
+}
+
 // selectAllSets fetches a list of all the sets created in a particular app
 func (db model) selectAllSets(orgID string, appID string) ([]SetWrapper, error) {
 	rows, err := db.Query(`
@@ -121,13 +157,14 @@ func (db model) selectAllSets(orgID string, appID string) ([]SetWrapper, error)
 }
 
 // selecteSet fetches a particular set from an app.
+// Legacy set IDs are resolved to the current ID via set_id_aliases. The returned ID is always the current ID.
 // The ErrNotFound sential error is returned if the specific set could not be found.
 func (db model) selectSet(orgID string, appID string, setID string) (SetWrapper, error) {
 	row := db.QueryRow(`SELECT sets.id, sets.set
 		FROM sets
 		LEFT JOIN set_owners
 		ON sets.id = set_id
-		WHERE org_id = $1 AND app_id = $2 AND sets.id = $3`, orgID, appID, setID)
+		WHERE org_id = $1 AND app_id = $2 AND sets.id = COALESCE((SELECT id FROM set_id_aliases WHERE alias = $3), $3)`, orgID, appID, setID)
 	var sw SetWrapper
 	err := row.Scan(&sw.ID, (*persistableSet)(&sw.Set))
 	if err == sql.ErrNoRows {
@@ -139,10 +176,10 @@ func (db model) selectSet(orgID string, appID string, setID string) (SetWrapper,
 	return sw, nil
 }
 
-// selectUnscopedRawSet fetches a particular set.
+// selectUnscopedRawSet fetches a particular set. Legacy set IDs are resolved via set_id_aliases.
 // The ErrNotFound sential error is returned if the specific set could not be found.
 func (db model) selectUnscopedRawSet(setID string) (depset.Set, error) {
-	row := db.QueryRow(`SELECT set FROM sets WHERE id = $1`, setID)
+	row := db.QueryRow(`SELECT set FROM sets WHERE id = COALESCE((SELECT id FROM set_id_aliases WHERE alias = $1), $1)`, setID)
 	var set depset.Set
 	err := row.Scan((*persistableSet)(&set))
 	if err == sql.ErrNoRows {
@@ -154,13 +191,14 @@ func (db model) selectUnscopedRawSet(setID string) (depset.Set, error) {
 	return set, nil
 }
 
-// selectRawSet returns a depset.Set rather than SetWrapper version of a set.
+// selectRawSet returns a depset.Set rather than SetWrapper version of a set. Legacy set IDs are resolved via
+// set_id_aliases.
 func (db model) selectRawSet(orgID string, appID string, setID string) (depset.Set, error) {
 	row := db.QueryRow(`SELECT sets.set
 		FROM set_owners
 		LEFT JOIN sets
 		ON id = set_id
-		WHERE org_id = $1 AND app_id = $2 AND set_id = $3`,
+		WHERE org_id = $1 AND app_id = $2 AND set_id = COALESCE((SELECT id FROM set_id_aliases WHERE alias = $3), $3)`,
 		orgID, appID, setID)
 	var set depset.Set
 	err := row.Scan((*persistableSet)(&set))
@@ -201,7 +239,7 @@ func (db model) insertSet(orgID string, appID string, sw SetWrapper) error {
 
 // selectAllDeltas fetches a list of all the deltas created in a particular app
 func (db model) selectAllDeltas(orgID string, appID string) ([]DeltaWrapper, error) {
-	rows, err := db.Query(`SELECT id, metadata, delta FROM deltas WHERE org_id = $1 AND app_id = $2`, orgID, appID)
+	rows, err := db.Query(`SELECT id, locked, metadata, delta FROM deltas WHERE org_id = $1 AND app_id = $2`, orgID, appID)
 	defer rows.Close()
 	if err != nil {
 		log.Printf("Database error fetching deltas in org `%s` and app `%s`. (%v)", orgID, appID, err)
@@ -211,7 +249,7 @@ func (db model) selectAllDeltas(orgID string, appID string) ([]DeltaWrapper, err
 	var deltas []DeltaWrapper
 	for rows.Next() {
 		var dw DeltaWrapper
-		rows.Scan(&dw.ID, (*persistableDeltaMetadata)(&dw.Metadata), (*persistableDelta)(&dw.Delta))
+		rows.Scan(&dw.ID, &dw.Locked, (*persistableDeltaMetadata)(&dw.Metadata), (*persistableDelta)(&dw.Delta))
 		deltas = append(deltas, dw)
 	}
 	return deltas, nil
@@ -244,9 +282,20 @@ func (db model) insertDelta(orgID, appID string, locked bool, metadata DeltaMeta
 	return id, nil
 }
 
-// updateDelta stores a delta for a particular app.
-func (db model) updateDelta(orgID, appID, deltaID string, locked bool, metadata DeltaMetadata, delta depset.Delta) error {
-	result, err := db.Exec(`UPDATE deltas SET metadata = $4, delta = $5 WHERE org_id = $1 AND app_id = $2 AND id = $3`, orgID, appID, deltaID, (*persistableDeltaMetadata)(&metadata), (*persistableDelta)(&delta))
+// updateDelta stores a delta for a particular app. Only the LastModifiedAt and Contributers fields of metadata are
+// written, so that metadata recorded in the meantime, e.g. applications, is kept. The lock is not changed and locked
+// deltas are not updated.
+// The ErrNotFound sential error is returned if the delta could not be found and ErrLocked if it is locked.
+func (db model) updateDelta(orgID, appID, deltaID string, metadata DeltaMetadata, delta depset.Delta) error {
+	edit, err := json.Marshal(map[string]interface{}{
+		"last_modified_at": metadata.LastModifiedAt,
+		"contributers":     metadata.Contributers,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal delta edit: %w", err)
+	}
+
+	result, err := db.Exec(`UPDATE deltas SET metadata = metadata || $4::jsonb, delta = $5 WHERE org_id = $1 AND app_id = $2 AND id = $3 AND NOT locked`, orgID, appID, deltaID, string(edit), (*persistableDelta)(&delta))
 	if err != nil {
 		log.Printf("Database error updating delta `%s`. (%v)", deltaID, err)
 		return fmt.Errorf("update delta (%s): %w", deltaID, err)
@@ -257,17 +306,126 @@ func (db model) updateDelta(orgID, appID, deltaID string, locked bool, metadata
 		return fmt.Errorf("rows affected, update delta: %w", err)
 	}
 	if numRows == 0 {
+		locked, err := db.selectDeltaLocked(orgID, appID, deltaID)
+		if err != nil {
+			return err
+		}
+		if locked {
+			return ErrLocked
+		}
 		return ErrNotFound
 	}
 	return nil
 }
 
+// updateDeltaLock locks or unlocks a delta. Locking records lockedBy and lockedAt in the metadata of the delta and
+// unlocking removes them. Only the lock and those fields are written, so concurrent edits are not lost. Locking a
+// locked delta or unlocking an unlocked one does nothing.
+// The ErrNotFound sential error is returned if the delta could not be found.
+func (db model) updateDeltaLock(orgID, appID, deltaID string, locked bool, lockedBy string, lockedAt time.Time) error {
+	var result sql.Result
+	if locked {
+		lockMetadata, err := json.Marshal(map[string]interface{}{
+			"locked_by": lockedBy,
+			"locked_at": lockedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("marshal lock metadata: %w", err)
+		}
+		result, err = db.Exec(`UPDATE deltas SET locked = TRUE, metadata = metadata || $4::jsonb WHERE org_id = $1 AND app_id = $2 AND id = $3 AND NOT locked`, orgID, appID, deltaID, string(lockMetadata))
+		if err != nil {
+			log.Printf("Database error locking delta `%s`. (%v)", deltaID, err)
+			return fmt.Errorf("lock delta (%s): %w", deltaID, err)
+		}
+	} else {
+		var err error
+		result, err = db.Exec(`UPDATE deltas SET locked = FALSE, metadata = metadata - 'locked_by' - 'locked_at' WHERE org_id = $1 AND app_id = $2 AND id = $3 AND locked`, orgID, appID, deltaID)
+		if err != nil {
+			log.Printf("Database error unlocking delta `%s`. (%v)", deltaID, err)
+			return fmt.Errorf("unlock delta (%s): %w", deltaID, err)
+		}
+	}
+	numRows, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Database error requesting rows-affected updating lock of delta in org `%s` and app `%s` and ID `%s`. (%v)", orgID, appID, deltaID, err)
+		return fmt.Errorf("rows affected, update delta lock: %w", err)
+	}
+	if numRows == 0 {
+		// Either the delta does not exist or it is already locked or unlocked
+		_, err := db.selectDeltaLocked(orgID, appID, deltaID)
+		return err
+	}
+	return nil
+}
+
+// selectDeltaLocked returns whether a delta is locked.
+// The ErrNotFound sential error is returned if the delta could not be found.
+func (db model) selectDeltaLocked(orgID, appID, deltaID string) (bool, error) {
+	row := db.QueryRow(`SELECT locked FROM deltas WHERE org_id = $1 AND app_id = $2 AND id = $3`, orgID, appID, deltaID)
+	var locked bool
+	err := row.Scan(&locked)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	} else if err != nil {
+		log.Printf("Database error fetching lock of delta in org `%s` and app `%s` with Id `%s`. (%v)", orgID, appID, deltaID, err)
+		return false, fmt.Errorf("select delta lock (%s, %s, %s): %w", orgID, appID, deltaID, err)
+	}
+	return locked, nil
+}
+
+// recordDeltaApplication appends application to the applications in the metadata of a delta and, if lock is true,
+// locks the delta on behalf of the user who applied it. Nothing else is written and it is done in a single statement.
+// A delta that is already locked keeps its lock metadata.
+//
+// lastModifiedAt is the time the delta that was applied was last modified. If the delta has been edited since, the
+// application is not recorded, as the stored delta is not the one that was applied.
+// The ErrNotFound sential error is returned if the delta could not be found and ErrModified if it was edited.
+func (db model) recordDeltaApplication(orgID, appID, deltaID string, lastModifiedAt time.Time, application DeltaApplication, lock bool) error {
+	lastModified, err := json.Marshal(lastModifiedAt)
+	if err != nil {
+		return fmt.Errorf("marshal last modified time: %w", err)
+	}
+	applications, err := json.Marshal([]DeltaApplication{application})
+	if err != nil {
+		return fmt.Errorf("marshal delta application: %w", err)
+	}
+	lockMetadata, err := json.Marshal(map[string]interface{}{
+		"locked_by": application.AppliedBy,
+		"locked_at": application.AppliedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal lock metadata: %w", err)
+	}
+
+	result, err := db.Exec(`UPDATE deltas SET
+	    metadata = jsonb_set(metadata, '{applications}', COALESCE(metadata->'applications', '[]'::jsonb) || $4::jsonb)
+	      || CASE WHEN $5::boolean AND NOT locked THEN $6::jsonb ELSE '{}'::jsonb END,
+	    locked = locked OR $5::boolean
+	  WHERE org_id = $1 AND app_id = $2 AND id = $3 AND metadata->'last_modified_at' = $7::jsonb`, orgID, appID, deltaID, string(applications), lock, string(lockMetadata), string(lastModified))
+	if err != nil {
+		log.Printf("Database error recording application of delta `%s`. (%v)", deltaID, err)
+		return fmt.Errorf("record delta application (%s): %w", deltaID, err)
+	}
+	numRows, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Database error requesting rows-affected recording application of delta in org `%s` and app `%s` and ID `%s`. (%v)", orgID, appID, deltaID, err)
+		return fmt.Errorf("rows affected, record delta application: %w", err)
+	}
+	if numRows == 0 {
+		if _, err := db.selectDeltaLocked(orgID, appID, deltaID); err != nil {
+			return err
+		}
+		return ErrModified
+	}
+	return nil
+}
+
 // selecteSet fetches a particular set from an app.
 // The ErrNotFound sential error is returned if the specific set could not be found.
 func (db model) selectDelta(orgID string, appID string, deltaID string) (DeltaWrapper, error) {
-	row := db.QueryRow(`SELECT id, metadata, delta FROM deltas WHERE org_id = $1 AND app_id = $2 AND id = $3`, orgID, appID, deltaID)
+	row := db.QueryRow(`SELECT id, locked, metadata, delta FROM deltas WHERE org_id = $1 AND app_id = $2 AND id = $3`, orgID, appID, deltaID)
 	var dw DeltaWrapper
-	err := row.Scan(&dw.ID, (*persistableDeltaMetadata)(&dw.Metadata), (*persistableDelta)(&dw.Delta))
+	err := row.Scan(&dw.ID, &dw.Locked, (*persistableDeltaMetadata)(&dw.Metadata), (*persistableDelta)(&dw.Delta))
 	if err == sql.ErrNoRows {
 		return DeltaWrapper{}, ErrNotFound
 	} else if err != nil {
@@ -276,3 +434,29 @@ func (db model) selectDelta(orgID string, appID string, deltaID string) (DeltaWr
 	}
 	return dw, nil
 }
+
+// selectInvariants fetches the invariants attached to an app. An app without invariants has an empty list.
+func (db model) selectInvariants(orgID string, appID string) (invariant.Invariants, error) {
+	row := db.QueryRow(`SELECT invariants FROM invariants WHERE org_id = $1 AND app_id = $2`, orgID, appID)
+	var invariants invariant.Invariants
+	err := row.Scan((*persistableInvariants)(&invariants))
+	if err == sql.ErrNoRows {
+		return invariant.Invariants{}, nil
+	} else if err != nil {
+		log.Printf("Database error fetching invariants in org `%s` and app `%s`. (%v)", orgID, appID, err)
+		return nil, fmt.Errorf("select invariants (%s, %s): %w", orgID, appID, err)
+	}
+	return invariants, nil
+}
+
+// updateInvariants replaces the invariants attached to an app.
+func (db model) updateInvariants(orgID string, appID string, invariants invariant.Invariants) error {
+	_, err := db.Exec(`INSERT INTO invariants (org_id, app_id, invariants) VALUES ($1, $2, $3)
+		ON CONFLICT (org_id, app_id) DO UPDATE SET invariants = EXCLUDED.invariants`,
+		orgID, appID, (*persistableInvariants)(&invariants))
+	if err != nil {
+		log.Printf("Database error updating invariants in org `%s` and app `%s`. (%v)", orgID, appID, err)
+		return fmt.Errorf("update invariants (%s, %s): %w", orgID, appID, err)
+	}
+	return nil
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"strings"
 	"time"
+
+	"humanitec.io/deploymentset-svc/pkg/depset"
 )
 
 func twoToPow(i int) int {
@@ -92,6 +95,115 @@ func initDb(db *sql.DB) error {
 		log.Println("Unable to create deltas table.")
 		log.Fatal(err)
 	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS set_id_aliases (
+	    alias       TEXT NOT NULL PRIMARY KEY,
+			id          TEXT NOT NULL
+	)`)
+	if err != nil {
+		log.Println("Unable to create set_id_aliases table.")
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS invariants (
+      org_id      TEXT NOT NULL,
+      app_id      TEXT NOT NULL,
+			invariants  JSONB NOT NULL,
+			UNIQUE (org_id, app_id)
+	)`)
+	if err != nil {
+		log.Println("Unable to create invariants table.")
+		log.Fatal(err)
+	}
+
+	err = migrateSetIDs(db)
+	if err != nil {
+		log.Println("Unable to migrate set IDs.")
+		log.Fatal(err)
+	}
+	return nil
+}
+
+// migrateSetIDs moves sets stored under IDs generated by an older hash algorithm, or stored at an older set version,
+// to the ID of the set upgraded to the current version using the current algorithm. The old ID is recorded in
+// set_id_aliases so that it can still be used to fetch the set.
+// Sets whose stored ID does not match their content are left untouched.
+func migrateSetIDs(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, set FROM sets WHERE id NOT LIKE $1 OR COALESCE((set->>'version')::int, 0) < $2`,
+		depset.HashAlgorithmCurrent+".%", depset.CurrentVersion)
+	if err != nil {
+		return fmt.Errorf("select sets to migrate: %w", err)
+	}
+	var sets []SetWrapper
+	for rows.Next() {
+		var sw SetWrapper
+		var buf []byte
+		// The set is unmarshalled directly rather than via persistableSet so that the ID can be verified against the
+		// set as it was stored, before it is upgraded.
+		if err := rows.Scan(&sw.ID, &buf); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan set to migrate: %w", err)
+		}
+		if err := json.Unmarshal(buf, &sw.Set); err != nil {
+			rows.Close()
+			return fmt.Errorf("unmarshal set to migrate `%s`: %w", sw.ID, err)
+		}
+		sets = append(sets, sw)
+	}
+	rows.Close()
+
+	migrated := 0
+	for _, sw := range sets {
+		if depset.IsZeroID(sw.ID) {
+			continue
+		}
+		if err := sw.Set.VerifyID(sw.ID); err != nil {
+			log.Printf("Not migrating set with ID `%s`. (%v)", sw.ID, err)
+			continue
+		}
+		sw.Set, err = depset.Upgrade(sw.Set)
+		if err != nil {
+			log.Printf("Not migrating set with ID `%s`. (%v)", sw.ID, err)
+			continue
+		}
+		newID := sw.Set.Hash()
+		if newID == sw.ID {
+			// Upgrading a version 0 set does not change its ID, so it is only stored at the new version.
+			if _, err := db.Exec(`UPDATE sets SET set = $2 WHERE id = $1`, sw.ID, (*persistableSet)(&sw.Set)); err != nil {
+				return fmt.Errorf("upgrade set `%s`: %w", sw.ID, err)
+			}
+			migrated++
+			continue
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("begin migration of set `%s`: %w", sw.ID, err)
+		}
+		statements := []struct {
+			query string
+			args  []interface{}
+		}{
+			{`INSERT INTO sets (id, set) VALUES ($1, $2) ON CONFLICT DO NOTHING`, []interface{}{newID, (*persistableSet)(&sw.Set)}},
+			{`INSERT INTO set_owners (org_id, app_id, set_id) SELECT org_id, app_id, $1 FROM set_owners WHERE set_id = $2 ON CONFLICT DO NOTHING`, []interface{}{newID, sw.ID}},
+			{`DELETE FROM set_owners WHERE set_id = $1`, []interface{}{sw.ID}},
+			{`DELETE FROM sets WHERE id = $1`, []interface{}{sw.ID}},
+			{`INSERT INTO set_id_aliases (alias, id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, []interface{}{sw.ID, newID}},
+		}
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement.query, statement.args...); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migrate set `%s` to `%s`: %w", sw.ID, newID, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration of set `%s`: %w", sw.ID, err)
+		}
+		migrated++
+	}
+	if migrated > 0 {
+		log.Printf("Migrated %d sets to version %d with `%s` IDs.", migrated, depset.CurrentVersion, depset.HashAlgorithmCurrent)
+	}
 	return nil
 }
 
@@ -7,7 +7,9 @@ package main
 import (
 	gomock "github.com/golang/mock/gomock"
 	depset "humanitec.io/deploymentset-svc/pkg/depset"
+	invariant "humanitec.io/deploymentset-svc/pkg/invariant"
 	reflect "reflect"
+	time "time"
 )
 
 // Mockmodeler is a mock of modeler interface
@@ -138,17 +140,45 @@ func (mr *MockmodelerMockRecorder) insertDelta(orgID, appID, locked, metadata, c
 }
 
 // updateDelta mocks base method
-func (m *Mockmodeler) updateDelta(orgID, appID, deltaID string, locked bool, metadata DeltaMetadata, content depset.Delta) error {
+func (m *Mockmodeler) updateDelta(orgID, appID, deltaID string, metadata DeltaMetadata, content depset.Delta) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "updateDelta", orgID, appID, deltaID, locked, metadata, content)
+	ret := m.ctrl.Call(m, "updateDelta", orgID, appID, deltaID, metadata, content)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // updateDelta indicates an expected call of updateDelta
-func (mr *MockmodelerMockRecorder) updateDelta(orgID, appID, deltaID, locked, metadata, content interface{}) *gomock.Call {
+func (mr *MockmodelerMockRecorder) updateDelta(orgID, appID, deltaID, metadata, content interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "updateDelta", reflect.TypeOf((*Mockmodeler)(nil).updateDelta), orgID, appID, deltaID, locked, metadata, content)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "updateDelta", reflect.TypeOf((*Mockmodeler)(nil).updateDelta), orgID, appID, deltaID, metadata, content)
+}
+
+// updateDeltaLock mocks base method
+func (m *Mockmodeler) updateDeltaLock(orgID, appID, deltaID string, locked bool, lockedBy string, lockedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "updateDeltaLock", orgID, appID, deltaID, locked, lockedBy, lockedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// updateDeltaLock indicates an expected call of updateDeltaLock
+func (mr *MockmodelerMockRecorder) updateDeltaLock(orgID, appID, deltaID, locked, lockedBy, lockedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "updateDeltaLock", reflect.TypeOf((*Mockmodeler)(nil).updateDeltaLock), orgID, appID, deltaID, locked, lockedBy, lockedAt)
+}
+
+// recordDeltaApplication mocks base method
+func (m *Mockmodeler) recordDeltaApplication(orgID, appID, deltaID string, lastModifiedAt time.Time, application DeltaApplication, lock bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "recordDeltaApplication", orgID, appID, deltaID, lastModifiedAt, application, lock)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// recordDeltaApplication indicates an expected call of recordDeltaApplication
+func (mr *MockmodelerMockRecorder) recordDeltaApplication(orgID, appID, deltaID, lastModifiedAt, application, lock interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "recordDeltaApplication", reflect.TypeOf((*Mockmodeler)(nil).recordDeltaApplication), orgID, appID, deltaID, lastModifiedAt, application, lock)
 }
 
 // selectDelta mocks base method
@@ -165,3 +195,32 @@ func (mr *MockmodelerMockRecorder) selectDelta(orgID, appID, deltaID interface{}
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "selectDelta", reflect.TypeOf((*Mockmodeler)(nil).selectDelta), orgID, appID, deltaID)
 }
+
+// selectInvariants mocks base method
+func (m *Mockmodeler) selectInvariants(orgID, appID string) (invariant.Invariants, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "selectInvariants", orgID, appID)
+	ret0, _ := ret[0].(invariant.Invariants)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// selectInvariants indicates an expected call of selectInvariants
+func (mr *MockmodelerMockRecorder) selectInvariants(orgID, appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "selectInvariants", reflect.TypeOf((*Mockmodeler)(nil).selectInvariants), orgID, appID)
+}
+
+// updateInvariants mocks base method
+func (m *Mockmodeler) updateInvariants(orgID, appID string, invariants invariant.Invariants) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "updateInvariants", orgID, appID, invariants)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// updateInvariants indicates an expected call of updateInvariants
+func (mr *MockmodelerMockRecorder) updateInvariants(orgID, appID, invariants interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "updateInvariants", reflect.TypeOf((*Mockmodeler)(nil).updateInvariants), orgID, appID, invariants)
+}
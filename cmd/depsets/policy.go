@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"humanitec.io/deploymentset-svc/pkg/policy"
+)
+
+// loadPolicy reads the write policy from the JSON file at path.
+func loadPolicy(path string) (*policy.Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := policy.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("policy `%s`: %w", path, err)
+	}
+	return p, nil
+}
@@ -6,6 +6,8 @@ func (s *server) setupRoutes() {
 	r := mux.NewRouter()
 	r.Methods("GET").Path("/sets/{setId}").Handler(s.getUnscopedRawSet())
 	r.Methods("GET").Path("/orgs/{orgId}/apps/{appId}/sets/{leftSetId}").Queries("diff", "{rightSetId}").Handler(s.diffSets())
+	r.Methods("POST").Path("/orgs/{orgId}/apps/{appId}/sets/{baseSetId}/merge").Queries("ours", "{oursSetId}", "theirs", "{theirsSetId}").Handler(s.mergeSets())
+	r.Methods("POST").Path("/orgs/{orgId}/apps/{appId}/sets/{setId}").Queries("delta", "{deltaId}").Handler(s.applyStoredDelta())
 	r.Methods("POST").Path("/orgs/{orgId}/apps/{appId}/sets/{setId}").Handler(s.applyDelta())
 	r.Methods("GET").Path("/orgs/{orgId}/apps/{appId}/sets/{setId}").Handler(s.getSet())
 	r.Methods("GET").Path("/orgs/{orgId}/apps/{appId}/sets").Handler(s.listSets())
@@ -15,6 +17,12 @@ func (s *server) setupRoutes() {
 	r.Methods("GET").Path("/orgs/{orgId}/apps/{appId}/deltas/{deltaId}").Handler(s.getDelta())
 	r.Methods("PUT").Path("/orgs/{orgId}/apps/{appId}/deltas/{deltaId}").Handler(s.replaceDelta())
 	r.Methods("PATCH").Path("/orgs/{orgId}/apps/{appId}/deltas/{deltaId}").Handler(s.updateDelta())
+	r.Methods("POST").Path("/orgs/{orgId}/apps/{appId}/deltas/{deltaId}/lock").Handler(s.lockDelta())
+	r.Methods("POST").Path("/orgs/{orgId}/apps/{appId}/deltas/{deltaId}/unlock").Handler(s.unlockDelta())
+	r.Methods("POST").Path("/orgs/{orgId}/apps/{appId}/deltas/{deltaId}/rebase").Queries("from", "{fromSetId}", "onto", "{ontoSetId}").Handler(s.rebaseDelta())
+
+	r.Methods("GET").Path("/orgs/{orgId}/apps/{appId}/invariants").Handler(s.getInvariants())
+	r.Methods("PUT").Path("/orgs/{orgId}/apps/{appId}/invariants").Handler(s.replaceInvariants())
 
 	r.Methods("GET").Path("/alive").Handler(s.isAlive())
 	r.Methods("GET").Path("/health").Handler(s.isReady())
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"humanitec.io/deploymentset-svc/pkg/depset"
+	"humanitec.io/deploymentset-svc/pkg/jsonschema"
+)
+
+// loadSchemas reads every JSON Schema in dir. Each schema is registered for the Helm chart named by the path of its
+// file relative to dir without the ".json" extension, e.g. "humanitec/base-module.json" is the schema for the chart
+// "humanitec/base-module".
+func loadSchemas(dir string) (depset.Schemas, error) {
+	schemas := make(depset.Schemas)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		schema, err := jsonschema.Parse(data)
+		if err != nil {
+			return fmt.Errorf("schema `%s`: %w", relPath, err)
+		}
+		schemas[strings.TrimSuffix(filepath.ToSlash(relPath), ".json")] = schema
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schemas, nil
+}
@@ -0,0 +1,248 @@
+package depset
+
+import (
+	"humanitec.io/deploymentset-svc/pkg/jsonpointer"
+)
+
+// isArrayIndex returns true if the segment of a json-pointer could refer to an element of an array.
+func isArrayIndex(segment string) bool {
+	if segment == "-" {
+		return true
+	}
+	if segment == "" {
+		return false
+	}
+	for _, c := range segment {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// shiftsArray returns true if applying an action at path could change the indices of other elements in an array.
+func shiftsArray(operation string, path jsonpointer.Pointer) bool {
+	switch operation {
+	case "add", "remove", "move", "copy":
+		return len(path) > 0 && isArrayIndex(path[len(path)-1])
+	}
+	return false
+}
+
+// indexKind describes what the last segment of the path of an action refers to.
+type indexKind int
+
+const (
+	// objectKey is a property of an object.
+	objectKey indexKind = iota
+	// arrayElement is an element of an array.
+	arrayElement
+	// maybeArrayElement is made of digits, so it is an element of an array or a property of an object depending on
+	// what its parent is, which is not known.
+	maybeArrayElement
+)
+
+// pathIndexKind returns what the last segment of path refers to. module is the spec the action at path is applied to,
+// or nil if it is not known.
+func pathIndexKind(module map[string]interface{}, path jsonpointer.Pointer) indexKind {
+	if len(path) == 0 || !isArrayIndex(path[len(path)-1]) {
+		return objectKey
+	}
+	if path[len(path)-1] == "-" {
+		return arrayElement
+	}
+	if module != nil {
+		if parent, err := jsonpointer.Extract(module, path.Parent().String()); err == nil {
+			switch parent.(type) {
+			case []interface{}:
+				return arrayElement
+			case map[string]interface{}:
+				return objectKey
+			}
+		}
+	}
+	return maybeArrayElement
+}
+
+// actionPaths returns all of the paths an action reads or writes.
+func actionPaths(action UpdateAction) []jsonpointer.Pointer {
+	if action.Operation == "move" || action.Operation == "copy" {
+		return []jsonpointer.Pointer{jsonpointer.ToPath(action.Path), jsonpointer.ToPath(action.From)}
+	}
+	return []jsonpointer.Pointer{jsonpointer.ToPath(action.Path)}
+}
+
+// actionsRelated returns true if the order of a and b could matter. This is the case if one of the paths is an
+// ancestor of the other, or if one action shifts the elements of an array the other path is in.
+func actionsRelated(a, b UpdateAction) bool {
+	for _, pathA := range actionPaths(a) {
+		for _, pathB := range actionPaths(b) {
+			if pathA.HasPrefix(pathB) || pathB.HasPrefix(pathA) {
+				return true
+			}
+			if shiftsArray(a.Operation, pathA) && pathB.HasPrefix(pathA.Parent()) {
+				return true
+			}
+			if shiftsArray(b.Operation, pathB) && pathA.HasPrefix(pathB.Parent()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isValueOperation returns true for the operations that compaction understands.
+func isValueOperation(operation string) bool {
+	return operation == "add" || operation == "remove" || operation == "replace"
+}
+
+// compaction is a list of compacted actions along with what the last segment of the path of each refers to.
+type compaction struct {
+	actions []UpdateAction
+	kinds   []indexKind
+}
+
+func (c *compaction) append(action UpdateAction, kind indexKind) {
+	c.actions = append(c.actions, action)
+	c.kinds = append(c.kinds, kind)
+}
+
+func (c *compaction) remove(index int) {
+	c.actions = append(c.actions[:index], c.actions[index+1:]...)
+	c.kinds = append(c.kinds[:index], c.kinds[index+1:]...)
+}
+
+// add appends action to the compacted actions, combining it with earlier actions where possible. kind is what the last
+// segment of its path refers to.
+func (c *compaction) add(action UpdateAction, kind indexKind) {
+	if !isValueOperation(action.Operation) {
+		c.append(action, kind)
+		return
+	}
+	path := jsonpointer.Pointer(jsonpointer.ToPath(action.Path))
+	shifts := shiftsArray(action.Operation, path) && kind != objectKey
+	overwrites := action.Operation != "add" || !shifts
+
+	for {
+		// Only the most recent related action can be combined. Any actions after it are independent of action.
+		previousIndex := -1
+		for i := len(c.actions) - 1; i >= 0; i-- {
+			if actionsRelated(c.actions[i], action) {
+				previousIndex = i
+				break
+			}
+		}
+		if previousIndex < 0 {
+			c.append(action, kind)
+			return
+		}
+		previous := c.actions[previousIndex]
+		if !isValueOperation(previous.Operation) {
+			c.append(action, kind)
+			return
+		}
+		previousPath := jsonpointer.Pointer(jsonpointer.ToPath(previous.Path))
+		previousKind := c.kinds[previousIndex]
+		previousShifts := shiftsArray(previous.Operation, previousPath) && previousKind != objectKey
+
+		switch {
+		case action.Path == previous.Path && (shifts || previousShifts) &&
+			(kind == maybeArrayElement || previousKind == maybeArrayElement):
+			// Inserting into or removing from an array combines differently from adding or removing a property, so
+			// the actions cannot be combined without knowing which it is.
+			c.append(action, kind)
+			return
+
+		case action.Path == previous.Path && !previousShifts && !shifts:
+			if action.Operation == "remove" {
+				if previous.Operation == "remove" {
+					c.append(action, kind)
+					return
+				}
+				// The add or replace is irrelevant as the value is removed anyway.
+				c.remove(previousIndex)
+				continue
+			}
+			if previous.Operation == "remove" {
+				if action.Operation == "add" {
+					// The add overwrites whatever was there, so the remove is irrelevant.
+					c.remove(previousIndex)
+					continue
+				}
+				c.append(action, kind)
+				return
+			}
+			// Successive adds or replaces of the same path: only the last value matters.
+			c.actions[previousIndex].setValue(action)
+			return
+
+		case action.Path == previous.Path && previousShifts && path[len(path)-1] != "-":
+			switch {
+			case previous.Operation == "add" && action.Operation == "remove":
+				// Inserting and then removing the same element of an array has no effect.
+				c.remove(previousIndex)
+				return
+			case previous.Operation == "add" && action.Operation == "replace":
+				c.actions[previousIndex].setValue(action)
+				return
+			case previous.Operation == "remove" && action.Operation == "add":
+				c.actions[previousIndex] = UpdateAction{Operation: "replace", Path: action.Path}
+				c.actions[previousIndex].setValue(action)
+				return
+			}
+			c.append(action, kind)
+			return
+
+		case overwrites && len(previousPath) > len(path) && previousPath.HasPrefix(path):
+			// The previous action updated something inside a value that is now overwritten or removed.
+			c.remove(previousIndex)
+			continue
+
+		case previous.Operation != "remove" && len(path) > len(previousPath) && path.HasPrefix(previousPath):
+			// The action updates something inside a value that was previously added or replaced, so the value
+			// itself can be updated.
+			wrapper := map[string]interface{}{"value": copyValue(previous.Value)}
+			err := applyUpdateAction(UpdateAction{
+				Operation: action.Operation,
+				Path:      append(jsonpointer.Pointer{"value"}, path[len(previousPath):]...).String(),
+				Value:     action.Value,
+			}, wrapper)
+			if err != nil {
+				c.append(action, kind)
+				return
+			}
+			c.actions[previousIndex].Value = wrapper["value"]
+			return
+		}
+		c.append(action, kind)
+		return
+	}
+}
+
+// CompactUpdates reduces a list of update actions to a shorter list with the same effect. Successive adds or
+// replaces of the same path are combined, an element inserted into an array and then removed is dropped, updates
+// under a path that is later removed or overwritten are dropped and updates under a path that was previously added or
+// replaced are folded into the added value.
+//
+// module is the spec the actions are applied to. It is only used to tell whether a path segment made of digits refers
+// to an element of an array or a property of an object. If module is nil, or the actions cannot be applied to it,
+// actions at such paths that insert or remove a value are not combined with each other.
+//
+// Actions are only combined if no action in between could depend on them. "move", "copy" and "test" actions are never
+// combined.
+func CompactUpdates(module map[string]interface{}, actions []UpdateAction) []UpdateAction {
+	if module != nil {
+		module = copyModuleSpec(module)
+	}
+	c := compaction{
+		actions: make([]UpdateAction, 0, len(actions)),
+		kinds:   make([]indexKind, 0, len(actions)),
+	}
+	for _, action := range actions {
+		c.add(action, pathIndexKind(module, jsonpointer.ToPath(action.Path)))
+		if module != nil && applyUpdateAction(action, module) != nil {
+			module = nil
+		}
+	}
+	return c.actions
+}
@@ -0,0 +1,201 @@
+package depset
+
+import (
+	"reflect"
+	"testing"
+)
+
+func validateCompaction(module map[string]interface{}, actions, expected []UpdateAction, t *testing.T) {
+	t.Helper()
+	compacted := CompactUpdates(module, actions)
+	if !reflect.DeepEqual(compacted, expected) {
+		t.Errorf("Expected: `%+v`, got `%+v`", expected, compacted)
+	}
+
+	original := copyValue(module).(map[string]interface{})
+	for _, action := range actions {
+		if err := applyUpdateAction(action, original); err != nil {
+			t.Errorf("Applying original actions returned unexpected error: %v", err)
+			return
+		}
+	}
+	result := copyValue(module).(map[string]interface{})
+	for _, action := range compacted {
+		if err := applyUpdateAction(action, result); err != nil {
+			t.Errorf("Applying compacted actions returned unexpected error: %v", err)
+			return
+		}
+	}
+	if !reflect.DeepEqual(original, result) {
+		t.Errorf("Compacted actions generated `%+v`, original actions generated `%+v`", result, original)
+	}
+}
+
+func compactionTestModule() map[string]interface{} {
+	return map[string]interface{}{
+		"version": "1.0.0",
+		"configmap": map[string]interface{}{
+			"KEY": "Value",
+		},
+		"array": []interface{}{"value-one", "value-two", "value-three"},
+	}
+}
+
+func TestCompactUpdates_SuccessiveReplaces(t *testing.T) {
+	validateCompaction(compactionTestModule(), []UpdateAction{
+		UpdateAction{Operation: "replace", Path: "/version", Value: "1.0.1"},
+		UpdateAction{Operation: "replace", Path: "/configmap/KEY", Value: "Other Value"},
+		UpdateAction{Operation: "replace", Path: "/version", Value: "1.0.2"},
+		UpdateAction{Operation: "replace", Path: "/version", Value: "1.0.3"},
+	}, []UpdateAction{
+		UpdateAction{Operation: "replace", Path: "/version", Value: "1.0.3"},
+		UpdateAction{Operation: "replace", Path: "/configmap/KEY", Value: "Other Value"},
+	}, t)
+}
+
+func TestCompactUpdates_AddThenRemove(t *testing.T) {
+	validateCompaction(compactionTestModule(), []UpdateAction{
+		UpdateAction{Operation: "add", Path: "/configmap/NEW_KEY", Value: "New Value"},
+		UpdateAction{Operation: "add", Path: "/array/1", Value: "inserted"},
+		UpdateAction{Operation: "remove", Path: "/array/1"},
+		UpdateAction{Operation: "remove", Path: "/configmap/NEW_KEY"},
+	}, []UpdateAction{
+		UpdateAction{Operation: "remove", Path: "/configmap/NEW_KEY"},
+	}, t)
+}
+
+func TestCompactUpdates_UpdatesUnderRemovedPath(t *testing.T) {
+	validateCompaction(compactionTestModule(), []UpdateAction{
+		UpdateAction{Operation: "add", Path: "/configmap/NEW_KEY", Value: "New Value"},
+		UpdateAction{Operation: "replace", Path: "/configmap/KEY", Value: "Other Value"},
+		UpdateAction{Operation: "replace", Path: "/version", Value: "1.0.1"},
+		UpdateAction{Operation: "remove", Path: "/configmap"},
+	}, []UpdateAction{
+		UpdateAction{Operation: "replace", Path: "/version", Value: "1.0.1"},
+		UpdateAction{Operation: "remove", Path: "/configmap"},
+	}, t)
+}
+
+func TestCompactUpdates_UpdatesFoldedIntoAddedValue(t *testing.T) {
+	validateCompaction(compactionTestModule(), []UpdateAction{
+		UpdateAction{Operation: "add", Path: "/ingress", Value: map[string]interface{}{"enabled": false}},
+		UpdateAction{Operation: "replace", Path: "/ingress/enabled", Value: true},
+		UpdateAction{Operation: "add", Path: "/ingress/host", Value: "example.com"},
+	}, []UpdateAction{
+		UpdateAction{Operation: "add", Path: "/ingress", Value: map[string]interface{}{"enabled": true, "host": "example.com"}},
+	}, t)
+}
+
+func TestCompactUpdates_ArrayShiftsPreventCombining(t *testing.T) {
+	validateCompaction(compactionTestModule(), []UpdateAction{
+		UpdateAction{Operation: "replace", Path: "/array/1", Value: "replaced"},
+		UpdateAction{Operation: "remove", Path: "/array/0"},
+		UpdateAction{Operation: "replace", Path: "/array/1", Value: "replaced again"},
+	}, []UpdateAction{
+		UpdateAction{Operation: "replace", Path: "/array/1", Value: "replaced"},
+		UpdateAction{Operation: "remove", Path: "/array/0"},
+		UpdateAction{Operation: "replace", Path: "/array/1", Value: "replaced again"},
+	}, t)
+}
+
+func TestCompactUpdates_MoveIsNotCombined(t *testing.T) {
+	validateCompaction(compactionTestModule(), []UpdateAction{
+		UpdateAction{Operation: "replace", Path: "/version", Value: "1.0.1"},
+		UpdateAction{Operation: "move", From: "/version", Path: "/old-version"},
+		UpdateAction{Operation: "add", Path: "/version", Value: "1.0.2"},
+	}, []UpdateAction{
+		UpdateAction{Operation: "replace", Path: "/version", Value: "1.0.1"},
+		UpdateAction{Operation: "move", From: "/version", Path: "/old-version"},
+		UpdateAction{Operation: "add", Path: "/version", Value: "1.0.2"},
+	}, t)
+}
+
+func TestCompactUpdates_NumericObjectKeys(t *testing.T) {
+	module := map[string]interface{}{
+		"ports": map[string]interface{}{
+			"80":  "http",
+			"443": "https",
+		},
+	}
+	validateCompaction(module, []UpdateAction{
+		UpdateAction{Operation: "add", Path: "/ports/80", Value: "web"},
+		UpdateAction{Operation: "remove", Path: "/ports/80"},
+		UpdateAction{Operation: "remove", Path: "/ports/443"},
+		UpdateAction{Operation: "add", Path: "/ports/443", Value: "tls"},
+	}, []UpdateAction{
+		UpdateAction{Operation: "remove", Path: "/ports/80"},
+		UpdateAction{Operation: "add", Path: "/ports/443", Value: "tls"},
+	}, t)
+}
+
+func TestCompactUpdates_NumericSegmentsWithoutModule(t *testing.T) {
+	actions := []UpdateAction{
+		UpdateAction{Operation: "add", Path: "/ports/80", Value: "web"},
+		UpdateAction{Operation: "remove", Path: "/ports/80"},
+		UpdateAction{Operation: "remove", Path: "/x/0"},
+		UpdateAction{Operation: "add", Path: "/x/0", Value: "new"},
+		UpdateAction{Operation: "replace", Path: "/y/0", Value: "one"},
+		UpdateAction{Operation: "replace", Path: "/y/0", Value: "two"},
+	}
+	expected := []UpdateAction{
+		UpdateAction{Operation: "add", Path: "/ports/80", Value: "web"},
+		UpdateAction{Operation: "remove", Path: "/ports/80"},
+		UpdateAction{Operation: "remove", Path: "/x/0"},
+		UpdateAction{Operation: "add", Path: "/x/0", Value: "new"},
+		UpdateAction{Operation: "replace", Path: "/y/0", Value: "two"},
+	}
+
+	compacted := CompactUpdates(nil, actions)
+	if !reflect.DeepEqual(compacted, expected) {
+		t.Errorf("Expected: `%+v`, got `%+v`", expected, compacted)
+	}
+}
+
+func TestMargeDeltas_CompactsUpdates(t *testing.T) {
+	deltaA := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"module-update-a": []UpdateAction{
+					UpdateAction{Operation: "replace", Path: "/version", Value: "1.0.1"},
+					UpdateAction{Operation: "add", Path: "/configmap/NEW_KEY", Value: "New Value"},
+				},
+				"module-update-b": []UpdateAction{
+					UpdateAction{Operation: "add", Path: "/array/1", Value: "inserted"},
+				},
+			},
+		},
+	}
+	deltaB := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"module-update-a": []UpdateAction{
+					UpdateAction{Operation: "replace", Path: "/version", Value: "1.0.2"},
+				},
+				"module-update-b": []UpdateAction{
+					UpdateAction{Operation: "remove", Path: "/array/1"},
+				},
+			},
+		},
+	}
+	expectedDelta := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{},
+			Update: map[string][]UpdateAction{
+				"module-update-a": []UpdateAction{
+					UpdateAction{Operation: "replace", Path: "/version", Value: "1.0.2"},
+					UpdateAction{Operation: "add", Path: "/configmap/NEW_KEY", Value: "New Value"},
+				},
+				// The module is not known, so "1" could be an index or a key and the actions are not combined.
+				"module-update-b": []UpdateAction{
+					UpdateAction{Operation: "add", Path: "/array/1", Value: "inserted"},
+					UpdateAction{Operation: "remove", Path: "/array/1"},
+				},
+			},
+		},
+	}
+	mergedDelta, err := MergeDeltas(deltaA, deltaB)
+	if err != nil {
+		t.Errorf("MergeDeltas returned unexpected error: %v", err)
+	}
+	validateDelta(mergedDelta, expectedDelta, t)
+}
@@ -0,0 +1,38 @@
+package depset
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrConflict is wrapped by ConflictError so that it can be detected with errors.Is
+var ErrConflict = errors.New("conflict")
+
+// Conflict describes a single place where a Delta is not compatible with a Set, or where two Sets being merged
+// disagree. For merges, Ours and Theirs hold the two conflicting values. (A value that was removed is omitted.)
+type Conflict struct {
+	Module string      `json:"module"`
+	Path   string      `json:"path"`
+	Reason string      `json:"reason"`
+	Ours   interface{} `json:"ours,omitempty"`
+	Theirs interface{} `json:"theirs,omitempty"`
+}
+
+// ConflictError is returned when one or more conflicts were found. It lists every conflict rather than just the first.
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	descriptions := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		descriptions[i] = fmt.Sprintf("module `%s` path `%s`: %s", c.Module, c.Path, c.Reason)
+	}
+	return fmt.Sprintf("%d conflicts: %s", len(e.Conflicts), strings.Join(descriptions, "; "))
+}
+
+// Unwrap allows errors.Is(err, ErrConflict) to be used.
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
@@ -0,0 +1,107 @@
+package depset
+
+import (
+	"fmt"
+	"sort"
+
+	"humanitec.io/deploymentset-svc/pkg/jsonpointer"
+)
+
+// Validate checks the structure of the Delta and returns every problem found. It does not check module names or
+// specs (see ValidateModules) and does not need the Set the Delta will be applied to, so a valid Delta can still fail
+// to apply.
+//
+// Problems are reported in module name order, followed by problems with the preconditions.
+func (delta Delta) Validate() ValidationErrors {
+	var errs ValidationErrors
+
+	removed := make(map[string]bool)
+	for _, name := range delta.Modules.Remove {
+		removed[name] = true
+	}
+
+	for _, name := range getModuleSpecKeysAsSortedSlice(delta.Modules.Add) {
+		if removed[name] {
+			errs = append(errs, ValidationError{Module: name, Message: "module is both added and removed"})
+		}
+	}
+
+	updated := make([]string, 0, len(delta.Modules.Update))
+	for name := range delta.Modules.Update {
+		updated = append(updated, name)
+	}
+	sort.Strings(updated)
+	for _, name := range updated {
+		if removed[name] {
+			errs = append(errs, ValidationError{Module: name, Message: "module is both updated and removed"})
+		}
+		for i, action := range delta.Modules.Update[name] {
+			errs = append(errs, validateUpdateAction(name, i, action)...)
+		}
+	}
+
+	if delta.Preconditions != nil {
+		for i, precondition := range delta.Preconditions.Values {
+			if precondition.Module == "" {
+				errs = append(errs, ValidationError{Message: fmt.Sprintf("precondition %d: module must not be empty", i)})
+			}
+			if err := jsonpointer.Validate(precondition.Path); err != nil {
+				errs = append(errs, ValidationError{
+					Module:  precondition.Module,
+					Message: fmt.Sprintf("precondition %d: path `%s` is not a valid json-pointer", i, precondition.Path),
+				})
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateUpdateAction checks a single JSON-PATCH action. index is the position of the action in the module's list of
+// updates and is included in the messages so that problems can be found in long lists.
+func validateUpdateAction(name string, index int, action UpdateAction) ValidationErrors {
+	var errs ValidationErrors
+	newError := func(path, format string, a ...interface{}) {
+		errs = append(errs, ValidationError{
+			Module:  name,
+			Path:    path,
+			Message: fmt.Sprintf("update %d: ", index) + fmt.Sprintf(format, a...),
+		})
+	}
+
+	path := action.Path
+	parsedPath, err := jsonpointer.Parse(action.Path)
+	if err != nil {
+		newError("", "path `%s` is not a valid json-pointer", action.Path)
+		path = ""
+	}
+
+	switch action.Operation {
+	case "add", "remove", "replace", "move", "copy":
+		// These all change the value at path, which cannot be done to the module spec as a whole.
+		if action.Path == "" {
+			newError("", "path must not be empty")
+		}
+	case "test":
+	default:
+		newError(path, "op `%s` is not supported", action.Operation)
+	}
+
+	switch action.Operation {
+	case "add", "replace", "test":
+		if !action.HasValue() {
+			newError(path, "op `%s` requires a value", action.Operation)
+		}
+
+	case "move", "copy":
+		if from, err := jsonpointer.Parse(action.From); err != nil {
+			newError(path, "from `%s` is not a valid json-pointer", action.From)
+		} else if action.Operation == "move" && len(from) == 0 {
+			newError(path, "from must not be empty")
+		} else if action.Operation == "move" && parsedPath != nil && len(parsedPath) > len(from) && parsedPath.HasPrefix(from) {
+			newError(path, "cannot move `%s` into its own child", action.From)
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,140 @@
+package depset
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDeltaValidate(t *testing.T) {
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-one": map[string]interface{}{
+					"helmchart": "humanitec/base-module",
+				},
+			},
+			Remove: []string{"module-two"},
+			Update: map[string][]UpdateAction{
+				"module-one": []UpdateAction{
+					UpdateAction{Operation: "add", Path: "/values/replicas", Value: 2.0},
+					UpdateAction{Operation: "remove", Path: "/values/ingress"},
+					UpdateAction{Operation: "replace", Path: "/values/image", Value: "registry.humanitec.io/my-org/module-one:1.0.1"},
+					UpdateAction{Operation: "move", From: "/values/old~1name", Path: "/values/new~1name"},
+					UpdateAction{Operation: "copy", From: "", Path: "/values/copy"},
+					UpdateAction{Operation: "test", Path: "", Value: map[string]interface{}{"helmchart": "humanitec/base-module"}},
+				},
+			},
+		},
+	}
+
+	validateValidationErrors(delta.Validate(), nil, t)
+}
+
+func TestDeltaValidate_Invalid(t *testing.T) {
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-one": map[string]interface{}{
+					"helmchart": "humanitec/base-module",
+				},
+			},
+			Remove: []string{"module-one", "module-two"},
+			Update: map[string][]UpdateAction{
+				"module-two": []UpdateAction{
+					UpdateAction{Operation: "add", Path: "/values/replicas", Value: 2.0},
+				},
+				"module-three": []UpdateAction{
+					UpdateAction{Operation: "add", Path: "/values/replicas"},
+					UpdateAction{Operation: "replace", Path: "values/image", Value: "IMAGE"},
+					UpdateAction{Operation: "remove", Path: ""},
+					UpdateAction{Operation: "merge", Path: "/values~2"},
+					UpdateAction{Operation: "move", From: "/values", Path: "/values/nested"},
+					UpdateAction{Operation: "copy", From: "values", Path: "/values/copy"},
+					UpdateAction{Operation: "test", Path: "/values/image"},
+				},
+			},
+		},
+	}
+	expected := ValidationErrors{
+		{Module: "module-one", Message: "module is both added and removed"},
+		{Module: "module-three", Path: "/values/replicas", Message: "update 0: op `add` requires a value"},
+		{Module: "module-three", Message: "update 1: path `values/image` is not a valid json-pointer"},
+		{Module: "module-three", Message: "update 2: path must not be empty"},
+		{Module: "module-three", Message: "update 3: path `/values~2` is not a valid json-pointer"},
+		{Module: "module-three", Message: "update 3: op `merge` is not supported"},
+		{Module: "module-three", Path: "/values/nested", Message: "update 4: cannot move `/values` into its own child"},
+		{Module: "module-three", Path: "/values/copy", Message: "update 5: from `values` is not a valid json-pointer"},
+		{Module: "module-three", Path: "/values/image", Message: "update 6: op `test` requires a value"},
+		{Module: "module-two", Message: "module is both updated and removed"},
+	}
+
+	validateValidationErrors(delta.Validate(), expected, t)
+}
+
+func TestDeltaValidate_NullValue(t *testing.T) {
+	var delta Delta
+	err := json.Unmarshal([]byte(`{
+		"modules": {
+			"update": {
+				"module-one": [
+					{"op": "add", "path": "/values/replicas", "value": null},
+					{"op": "replace", "path": "/values/image"},
+					{"op": "test", "path": "/values/replicas", "value": null}
+				]
+			}
+		}
+	}`), &delta)
+	if err != nil {
+		t.Fatalf("Expected no error, got error: %v", err)
+	}
+	expected := ValidationErrors{
+		{Module: "module-one", Path: "/values/image", Message: "update 1: op `replace` requires a value"},
+	}
+
+	validateValidationErrors(delta.Validate(), expected, t)
+}
+
+func TestDiffNullValueRoundTrip(t *testing.T) {
+	left := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"added":    nil,
+					"replaced": nil,
+					"array":    []interface{}{nil},
+				},
+			},
+		},
+	}
+	right := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"replaced": "VALUE",
+					"array":    []interface{}{},
+				},
+			},
+		},
+	}
+
+	buf, err := json.Marshal(left.Diff(right))
+	if err != nil {
+		t.Fatalf("Expected no error, got error: %v", err)
+	}
+	var delta Delta
+	if err := json.Unmarshal(buf, &delta); err != nil {
+		t.Fatalf("Expected no error, got error: %v", err)
+	}
+
+	validateValidationErrors(delta.Validate(), nil, t)
+	result, err := right.Apply(delta)
+	if err != nil {
+		t.Fatalf("Expected no error, got error: %v", err)
+	}
+	if !reflect.DeepEqual(result.Modules, left.Modules) {
+		t.Errorf("Expected: `%+v`, got `%+v`", left.Modules, result.Modules)
+	}
+}
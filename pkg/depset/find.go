@@ -0,0 +1,44 @@
+package depset
+
+import (
+	"humanitec.io/deploymentset-svc/pkg/jsonpointer"
+)
+
+// Found is a value in a Set matched by FindAll.
+type Found struct {
+	Module string
+	Path   string
+	Value  interface{}
+}
+
+// FindAll returns every value in the Set matched by pattern. The first segment of the pattern matches the module name
+// and the rest matches the path within the module spec, e.g. "/*/values/image/tag" matches the image tag of every
+// module and "/module-one/values/**" matches every value of module-one. See jsonpointer.Pattern for the syntax.
+//
+// Values are returned in module name then path order and are copies, so they can be modified without affecting the
+// Set. An error is returned if pattern is not valid.
+func (inputSet Set) FindAll(pattern string) ([]Found, error) {
+	p, err := jsonpointer.ParsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := make(map[string]interface{}, len(inputSet.Modules))
+	for name, spec := range inputSet.Modules {
+		modules[name] = spec
+	}
+
+	var found []Found
+	for _, result := range p.FindAll(modules) {
+		if len(result.Pointer) == 0 {
+			// Only the root matches, e.g. for "/**", and it is not part of any module.
+			continue
+		}
+		found = append(found, Found{
+			Module: result.Pointer[0],
+			Path:   result.Pointer[1:].String(),
+			Value:  copyValue(result.Value),
+		})
+	}
+	return found, nil
+}
@@ -0,0 +1,51 @@
+package depset
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindAll(t *testing.T) {
+	set := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image": map[string]interface{}{"tag": "1.0.0"},
+				},
+			},
+			"module-two": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image": map[string]interface{}{"tag": "2.0.0"},
+				},
+			},
+			"redis-cache": map[string]interface{}{
+				"helmchart": "humanitec/redis",
+			},
+		},
+	}
+	found, err := set.FindAll("/*/values/image/tag")
+	if err != nil {
+		t.Fatalf("Expected no error, got error: %v", err)
+	}
+	expected := []Found{
+		{Module: "module-one", Path: "/values/image/tag", Value: "1.0.0"},
+		{Module: "module-two", Path: "/values/image/tag", Value: "2.0.0"},
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected %v, got %v", expected, found)
+	}
+
+	found, err = set.FindAll("/*")
+	if err != nil {
+		t.Fatalf("Expected no error, got error: %v", err)
+	}
+	if len(found) != 3 || found[2].Module != "redis-cache" || found[2].Path != "" {
+		t.Errorf("Expected the spec of every module, got %v", found)
+	}
+
+	if _, err := set.FindAll("*/values"); err == nil {
+		t.Errorf("Expected error for invalid pattern")
+	}
+}
@@ -0,0 +1,171 @@
+package depset
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"humanitec.io/deploymentset-svc/pkg/jcs"
+)
+
+// HashAlgorithmLegacy identifies IDs generated by encoding the set as nested sorted arrays with json.Marshal. These
+// IDs have no prefix and do not include the version.
+const HashAlgorithmLegacy = "legacy"
+
+// HashAlgorithmJCSSHA256 identifies IDs generated from the SHA-256 digest of the JSON Canonicalization Scheme
+// (RFC 8785) encoding of the set's modules and version.
+const HashAlgorithmJCSSHA256 = "jcs-sha256"
+
+// HashAlgorithmCurrent is the algorithm used by Hash.
+const HashAlgorithmCurrent = HashAlgorithmJCSSHA256
+
+// idSeparator separates the algorithm from the digest in an ID.
+const idSeparator = "."
+
+// zeroID is the ID of the empty set, regardless of algorithm.
+const zeroID = "0000000000000000000000000000000000000000000"
+
+// ErrUnknownAlgorithm returned when an ID or hash uses an algorithm that is not supported.
+var ErrUnknownAlgorithm = errors.New("unknown hash algorithm")
+
+// ErrIDMismatch returned when an ID does not match the set it is supposed to identify.
+var ErrIDMismatch = errors.New("id does not match set")
+
+// Hash generates an invarient id for a Deployment Set using the current algorithm.
+// The ID has the form "<algorithm>.<digest>". The empty set always has an ID made up entirely of zeros.
+func (inputSet Set) Hash() string {
+	id, _ := inputSet.HashWithAlgorithm(HashAlgorithmCurrent)
+	return id
+}
+
+// HashWithAlgorithm generates the id for a Deployment Set using a specific algorithm.
+func (inputSet Set) HashWithAlgorithm(algorithm string) (string, error) {
+	// sepecial case for the empty set, the hash is zero
+	if len(inputSet.Modules) == 0 {
+		return zeroID, nil
+	}
+
+	var buf []byte
+	var err error
+	switch algorithm {
+	case HashAlgorithmLegacy:
+		buf, err = legacyEncoding(inputSet)
+	case HashAlgorithmJCSSHA256:
+		// The version is part of the ID so that the same modules in different schema versions have different IDs.
+		// Version 0 and version 1 sets have the same shape, so the property is left out for both. This means upgrading
+		// a version 0 set does not change its ID.
+		version := inputSet.Version
+		if version <= 1 {
+			version = 0
+		}
+		buf, err = jcs.Marshal(struct {
+			Modules map[string]map[string]interface{} `json:"modules"`
+			Version int                               `json:"version,omitempty"`
+		}{inputSet.Modules, version})
+	default:
+		return "", fmt.Errorf("algorithm `%s`: %w", algorithm, ErrUnknownAlgorithm)
+	}
+	if err != nil {
+		return "", fmt.Errorf("encoding set: %w", err)
+	}
+
+	checksum := sha256.Sum256(buf)
+
+	// RawURLEncoding makes for URL safe IDs that don't have trailing '='. This means no URL encoding required.
+	digest := base64.RawURLEncoding.EncodeToString(checksum[:])
+	if algorithm == HashAlgorithmLegacy {
+		return digest, nil
+	}
+	return algorithm + idSeparator + digest, nil
+}
+
+// SplitID returns the algorithm and digest that make up an ID. IDs without an algorithm prefix are legacy IDs.
+func SplitID(id string) (algorithm string, digest string) {
+	if i := strings.Index(id, idSeparator); i >= 0 {
+		return id[:i], id[i+len(idSeparator):]
+	}
+	return HashAlgorithmLegacy, id
+}
+
+// IsZeroID returns true if the ID is the ID of the empty set. That is, it is made up entirely of zeros.
+func IsZeroID(id string) bool {
+	if len(id) == 0 {
+		return false
+	}
+	return strings.Trim(id, "0") == ""
+}
+
+// VerifyID checks that id is the ID of inputSet using the algorithm named in the ID.
+// The ErrUnknownAlgorithm sentinal error is returned if the algorithm is not supported and ErrIDMismatch if the ID
+// does not match.
+func (inputSet Set) VerifyID(id string) error {
+	if IsZeroID(id) {
+		if len(inputSet.Modules) == 0 {
+			return nil
+		}
+		return ErrIDMismatch
+	}
+
+	algorithm, _ := SplitID(id)
+	expected, err := inputSet.HashWithAlgorithm(algorithm)
+	if err != nil {
+		return err
+	}
+	if expected != id {
+		return ErrIDMismatch
+	}
+	return nil
+}
+
+// legacyEncoding converts the deployment set into an array structure before encoding it with json.Marshal. This was
+// done because we cannot control key order using the built in go json serializer.
+func legacyEncoding(inputSet Set) ([]byte, error) {
+	arrSet := [2]interface{}{"modules", getModulesAsSortedSlice(inputSet.Modules)}
+	return json.Marshal(arrSet)
+}
+
+func getMapKeysAsSortedSlice(m map[string]interface{}) []string {
+	a := make([]string, len(m))
+	i := 0
+	for k := range m {
+		a[i] = k
+		i++
+	}
+	sort.StringSlice(a).Sort()
+	return a
+}
+
+func getModuleSpecKeysAsSortedSlice(m map[string]map[string]interface{}) []string {
+	a := make([]string, len(m))
+	i := 0
+	for k := range m {
+		a[i] = k
+		i++
+	}
+	sort.StringSlice(a).Sort()
+	return a
+}
+
+func getModuleSpecAsSortedSlice(m map[string]interface{}) [][2]interface{} {
+	sortedKeys := getMapKeysAsSortedSlice(m)
+
+	kvpArr := make([][2]interface{}, len(m))
+	for i := range sortedKeys {
+		kvpArr[i] = [2]interface{}{sortedKeys[i], m[sortedKeys[i]]}
+	}
+	return kvpArr
+}
+
+func getModulesAsSortedSlice(m map[string]map[string]interface{}) [][2]interface{} {
+	sortedModules := getModuleSpecKeysAsSortedSlice(m)
+
+	kvpArr := make([][2]interface{}, len(m))
+	for i := range sortedModules {
+		kvpArr[i] = [2]interface{}{sortedModules[i], getModuleSpecAsSortedSlice(m[sortedModules[i]])}
+	}
+	return kvpArr
+}
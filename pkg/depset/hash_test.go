@@ -0,0 +1,170 @@
+package depset
+
+import (
+	"errors"
+	"testing"
+)
+
+func validateHash(inputSet Set, expected string, t *testing.T) {
+	actual := inputSet.Hash()
+	if expected != actual {
+		t.Errorf("Expected %s, got %s", expected, actual)
+	}
+}
+
+func TestHashEmptgySetNil(t *testing.T) {
+	inputSet := Set{}
+	expectedHash := "0000000000000000000000000000000000000000000"
+
+	validateHash(inputSet, expectedHash, t)
+}
+
+// This test is mainly to ensure hashes do not change unexpectadly
+func TestHashGeneralCase(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"first-module": map[string]interface{}{
+				"StringParam": "Some string!",
+				"IntParam":    123,
+				"FloatParam":  125.5,
+				"BoolParam":   true,
+			},
+			"another-one": map[string]interface{}{
+				"version": "TEST_VERSION",
+				"param":   "TEST_param",
+			},
+		},
+	}
+	expectedHash := "jcs-sha256.C7n_R9-MdKRYZrsyoaIajjZ9yrfefGsWjJa2KnkJ2TQ"
+
+	validateHash(inputSet, expectedHash, t)
+}
+
+// This test is mainly to ensure legacy IDs can still be verified
+func TestHashWithAlgorithm_Legacy(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"first-module": map[string]interface{}{
+				"StringParam": "Some string!",
+				"IntParam":    123,
+				"FloatParam":  125.5,
+				"BoolParam":   true,
+			},
+			"another-one": map[string]interface{}{
+				"version": "TEST_VERSION",
+				"param":   "TEST_param",
+			},
+		},
+	}
+	expectedHash := "uf6OiM_uMN_xhOO9iYVCGULbLlQjPqc2y6wHyfy6eBQ"
+
+	actual, err := inputSet.HashWithAlgorithm(HashAlgorithmLegacy)
+	if err != nil {
+		t.Errorf("Expected no error, got error: %v", err)
+	} else if expectedHash != actual {
+		t.Errorf("Expected %s, got %s", expectedHash, actual)
+	}
+}
+
+func TestHashWithAlgorithm_Unknown(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"first-module": map[string]interface{}{
+				"StringParam": "Some string!",
+				"IntParam":    123,
+				"FloatParam":  125.5,
+				"BoolParam":   true,
+			},
+			"another-one": map[string]interface{}{
+				"version": "TEST_VERSION",
+				"param":   "TEST_param",
+			},
+		},
+	}
+	_, err := inputSet.HashWithAlgorithm("md5")
+	if !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("Expected ErrUnknownAlgorithm, got: %v", err)
+	}
+}
+
+func TestHashNumberRepresentation(t *testing.T) {
+	// Numbers are hashed by value, so it does not matter how they are represented in Go.
+	intSet := Set{Modules: map[string]map[string]interface{}{"module": map[string]interface{}{"a": 1, "b": int64(1e15)}}}
+	floatSet := Set{Modules: map[string]map[string]interface{}{"module": map[string]interface{}{"a": 1.0, "b": 1e15}}}
+
+	validateHash(intSet, floatSet.Hash(), t)
+}
+
+func TestSplitID(t *testing.T) {
+	testCases := []struct {
+		id        string
+		algorithm string
+		digest    string
+	}{
+		{"jcs-sha256.C7n_R9-MdKRYZrsyoaIajjZ9yrfefGsWjJa2KnkJ2TQ", HashAlgorithmJCSSHA256, "C7n_R9-MdKRYZrsyoaIajjZ9yrfefGsWjJa2KnkJ2TQ"},
+		{"uf6OiM_uMN_xhOO9iYVCGULbLlQjPqc2y6wHyfy6eBQ", HashAlgorithmLegacy, "uf6OiM_uMN_xhOO9iYVCGULbLlQjPqc2y6wHyfy6eBQ"},
+	}
+	for _, testCase := range testCases {
+		algorithm, digest := SplitID(testCase.id)
+		if algorithm != testCase.algorithm || digest != testCase.digest {
+			t.Errorf("Expected (%s, %s), got (%s, %s)", testCase.algorithm, testCase.digest, algorithm, digest)
+		}
+	}
+}
+
+func TestVerifyID(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"first-module": map[string]interface{}{
+				"StringParam": "Some string!",
+				"IntParam":    123,
+				"FloatParam":  125.5,
+				"BoolParam":   true,
+			},
+			"another-one": map[string]interface{}{
+				"version": "TEST_VERSION",
+				"param":   "TEST_param",
+			},
+		},
+	}
+	for _, id := range []string{
+		"jcs-sha256.C7n_R9-MdKRYZrsyoaIajjZ9yrfefGsWjJa2KnkJ2TQ",
+		"uf6OiM_uMN_xhOO9iYVCGULbLlQjPqc2y6wHyfy6eBQ",
+	} {
+		if err := inputSet.VerifyID(id); err != nil {
+			t.Errorf("Expected ID `%s` to be verified, got error: %v", id, err)
+		}
+	}
+
+	if err := (Set{}).VerifyID("0000000000000000000000000000000000000000"); err != nil {
+		t.Errorf("Expected zero ID to be verified for empty set, got error: %v", err)
+	}
+}
+
+func TestVerifyID_Mismatch(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"first-module": map[string]interface{}{
+				"StringParam": "Some string!",
+				"IntParam":    123,
+				"FloatParam":  125.5,
+				"BoolParam":   true,
+			},
+			"another-one": map[string]interface{}{
+				"version": "TEST_VERSION",
+				"param":   "TEST_param",
+			},
+		},
+	}
+	testCases := map[string]error{
+		"jcs-sha256.mgwhntlRovaKCM30yBlQrLOnzWz9w6nZ-b82hSeIrfQ": ErrIDMismatch,
+		"CxtOgS619lvcCDnMqRDMAf5b7-huv5qkc74b8W4laOY":            ErrIDMismatch,
+		"0000000000000000000000000000000000000000000":            ErrIDMismatch,
+		"md5.C7n_R9-MdKRYZrsyoaIajjZ9yrfefGsWjJa2KnkJ2TQ":        ErrUnknownAlgorithm,
+	}
+	for id, expected := range testCases {
+		if err := inputSet.VerifyID(id); !errors.Is(err, expected) {
+			t.Errorf("ID `%s`: expected error `%v`, got `%v`", id, expected, err)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package depset
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"humanitec.io/deploymentset-svc/pkg/jsonpointer"
+)
+
+// mergeValue is a value taking part in a three-way merge. exists is false if the value is not present at all, which
+// is different from the value being present and null.
+type mergeValue struct {
+	value  interface{}
+	exists bool
+}
+
+// conflictValue returns the value to report in a Conflict. Values that do not exist are reported as nil.
+func (a mergeValue) conflictValue() interface{} {
+	if !a.exists {
+		return nil
+	}
+	return copyValue(a.value)
+}
+
+func (a mergeValue) equals(b mergeValue) bool {
+	return a.exists == b.exists && (!a.exists || reflect.DeepEqual(a.value, b.value))
+}
+
+// childMergeValue returns the property key of value if value is an object.
+func childMergeValue(value mergeValue, key string) mergeValue {
+	if obj, ok := value.value.(map[string]interface{}); ok && value.exists {
+		child, exists := obj[key]
+		return mergeValue{value: child, exists: exists}
+	}
+	return mergeValue{}
+}
+
+// sortedUnionOfKeys returns the keys in all of the supplied values that are objects in sorted order.
+func sortedUnionOfKeys(values ...mergeValue) []string {
+	keySet := make(map[string]bool)
+	for _, value := range values {
+		if obj, ok := value.value.(map[string]interface{}); ok && value.exists {
+			for key := range obj {
+				keySet[key] = true
+			}
+		}
+	}
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mergeValues performs a three-way merge of a single value. If ours and theirs have both changed the value
+// differently and both are objects, they are merged property by property. Otherwise, a conflict is reported.
+func mergeValues(module string, path jsonpointer.Pointer, base, ours, theirs mergeValue) (mergeValue, []Conflict) {
+	if ours.equals(theirs) {
+		return ours, nil
+	}
+	if base.equals(ours) {
+		return theirs, nil
+	}
+	if base.equals(theirs) {
+		return ours, nil
+	}
+
+	_, oursIsObj := ours.value.(map[string]interface{})
+	_, theirsIsObj := theirs.value.(map[string]interface{})
+	if !ours.exists || !theirs.exists || !oursIsObj || !theirsIsObj {
+		return mergeValue{}, []Conflict{Conflict{
+			Module: module,
+			Path:   path.String(),
+			Reason: "changed differently in ours and theirs",
+			Ours:   ours.conflictValue(),
+			Theirs: theirs.conflictValue(),
+		}}
+	}
+
+	// If base is not an object, each property in ours and theirs is treated as newly added.
+	merged := make(map[string]interface{})
+	var conflicts []Conflict
+	for _, key := range sortedUnionOfKeys(ours, theirs) {
+		value, keyConflicts := mergeValues(module, path.Append(key),
+			childMergeValue(base, key), childMergeValue(ours, key), childMergeValue(theirs, key))
+		if value.exists {
+			merged[key] = value.value
+		}
+		conflicts = append(conflicts, keyConflicts...)
+	}
+	return mergeValue{value: merged, exists: true}, conflicts
+}
+
+// Merge performs a three-way merge of the Sets ours and theirs which were both derrived from base.
+//
+// Changes made in only one of ours or theirs are kept. If both change the same value differently, a *ConflictError is
+// returned which lists both values for each conflicting path. Objects are merged property by property, so changes to
+// different properties of the same module do not conflict. Arrays are treated as single values.
+// The merged set is always at CurrentVersion.
+func Merge(base, ours, theirs Set) (Set, error) {
+	var err error
+	for _, s := range []*Set{&base, &ours, &theirs} {
+		if *s, err = Upgrade(*s); err != nil {
+			return Set{}, err
+		}
+	}
+
+	set := Set{
+		Modules: make(map[string]map[string]interface{}),
+		Version: CurrentVersion,
+	}
+
+	moduleNames := make(map[string]bool)
+	for _, modules := range []map[string]map[string]interface{}{base.Modules, ours.Modules, theirs.Modules} {
+		for name := range modules {
+			moduleNames[name] = true
+		}
+	}
+	sortedNames := make([]string, 0, len(moduleNames))
+	for name := range moduleNames {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	var conflicts []Conflict
+	for _, name := range sortedNames {
+		baseModule, inBase := base.Modules[name]
+		oursModule, inOurs := ours.Modules[name]
+		theirsModule, inTheirs := theirs.Modules[name]
+
+		merged, moduleConflicts := mergeValues(name, jsonpointer.Pointer{},
+			mergeValue{value: baseModule, exists: inBase},
+			mergeValue{value: oursModule, exists: inOurs},
+			mergeValue{value: theirsModule, exists: inTheirs})
+		conflicts = append(conflicts, moduleConflicts...)
+
+		if merged.exists {
+			set.Modules[name] = copyValue(merged.value).(map[string]interface{})
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return Set{}, &ConflictError{Conflicts: conflicts}
+	}
+	return set, nil
+}
+
+// Rebase generates a Delta for newBase that is equivalent to delta, which was written for oldBase.
+//
+// The changes made by delta are merged with the changes made between oldBase and newBase using Merge. If delta
+// changes a value that was also changed differently in newBase, a *ConflictError listing the conflicting paths is
+// returned. In the conflicts, Ours is the value from delta and Theirs is the value in newBase.
+//
+// The preconditions of delta must hold for oldBase. They are kept in the rebased Delta, except that a Set ID
+// precondition is replaced with the ID of newBase.
+func Rebase(oldBase, newBase Set, delta Delta) (Delta, error) {
+	target, err := oldBase.Apply(delta)
+	if err != nil {
+		return Delta{}, fmt.Errorf("delta not compatible with original base: %w", err)
+	}
+
+	merged, err := Merge(oldBase, target, newBase)
+	if err != nil {
+		return Delta{}, err
+	}
+	rebased := merged.Diff(newBase)
+	if delta.Preconditions != nil {
+		rebased.Preconditions = copyDelta(Delta{Preconditions: delta.Preconditions}).Preconditions
+		if rebased.Preconditions.SetID != "" {
+			rebased.Preconditions.SetID = newBase.Hash()
+		}
+	}
+	return rebased, nil
+}
@@ -0,0 +1,334 @@
+package depset
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	base := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image":    "registry.humanitec.io/my-org/module-one:1.0.0",
+					"replicas": 1.0,
+				},
+			},
+			"module-removed-by-theirs": map[string]interface{}{
+				"helmchart": "humanitec/redis",
+			},
+		},
+	}
+	ours := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image":    "registry.humanitec.io/my-org/module-one:1.0.1",
+					"replicas": 1.0,
+				},
+			},
+			"module-removed-by-theirs": map[string]interface{}{
+				"helmchart": "humanitec/redis",
+			},
+			"module-added-by-ours": map[string]interface{}{
+				"helmchart": "humanitec/postgres",
+			},
+		},
+	}
+	theirs := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image":    "registry.humanitec.io/my-org/module-one:1.0.0",
+					"replicas": 3.0,
+				},
+			},
+		},
+	}
+	expected := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image":    "registry.humanitec.io/my-org/module-one:1.0.1",
+					"replicas": 3.0,
+				},
+			},
+			"module-added-by-ours": map[string]interface{}{
+				"helmchart": "humanitec/postgres",
+			},
+		},
+		Version: CurrentVersion,
+	}
+
+	merged, err := Merge(base, ours, theirs)
+	if err != nil {
+		t.Errorf("Expected no error, got error: %v", err)
+	} else if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Expected: `%+v`, got `%+v`", expected, merged)
+	}
+}
+
+func TestMerge_Conflicts(t *testing.T) {
+	base := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"values": map[string]interface{}{
+					"image":    "registry.humanitec.io/my-org/module-one:1.0.0",
+					"replicas": 1.0,
+				},
+			},
+			"module-two": map[string]interface{}{
+				"helmchart": "humanitec/redis",
+			},
+		},
+	}
+	ours := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"values": map[string]interface{}{
+					"image":    "registry.humanitec.io/my-org/module-one:1.0.1",
+					"replicas": 1.0,
+				},
+			},
+		},
+	}
+	theirs := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"values": map[string]interface{}{
+					"image":    "registry.humanitec.io/my-org/module-one:2.0.0",
+					"replicas": 1.0,
+				},
+			},
+			"module-two": map[string]interface{}{
+				"helmchart": "humanitec/redis-ha",
+			},
+		},
+	}
+	expectedConflicts := []Conflict{
+		Conflict{
+			Module: "module-one",
+			Path:   "/values/image",
+			Reason: "changed differently in ours and theirs",
+			Ours:   "registry.humanitec.io/my-org/module-one:1.0.1",
+			Theirs: "registry.humanitec.io/my-org/module-one:2.0.0",
+		},
+		Conflict{
+			Module: "module-two",
+			Path:   "",
+			Reason: "changed differently in ours and theirs",
+			Ours:   nil,
+			Theirs: map[string]interface{}{"helmchart": "humanitec/redis-ha"},
+		},
+	}
+
+	_, err := Merge(base, ours, theirs)
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Errorf("Expected ConflictError, got `%v`", err)
+		return
+	}
+	if !reflect.DeepEqual(conflictErr.Conflicts, expectedConflicts) {
+		t.Errorf("Expected: `%+v`, got `%+v`", expectedConflicts, conflictErr.Conflicts)
+	}
+}
+
+func TestMerge_SameModuleAddedInBoth(t *testing.T) {
+	base := Set{}
+	ours := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"ingress": true,
+				},
+			},
+		},
+	}
+	theirs := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"replicas": 2.0,
+				},
+			},
+		},
+	}
+	expected := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"ingress":  true,
+					"replicas": 2.0,
+				},
+			},
+		},
+		Version: CurrentVersion,
+	}
+
+	merged, err := Merge(base, ours, theirs)
+	if err != nil {
+		t.Errorf("Expected no error, got error: %v", err)
+	} else if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Expected: `%+v`, got `%+v`", expected, merged)
+	}
+}
+
+func TestRebase(t *testing.T) {
+	oldBase := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"values": map[string]interface{}{
+					"image":    "registry.humanitec.io/my-org/module-one:1.0.0",
+					"replicas": 1.0,
+				},
+			},
+		},
+	}
+	newBase := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"values": map[string]interface{}{
+					"image":    "registry.humanitec.io/my-org/module-one:1.0.0",
+					"replicas": 3.0,
+				},
+			},
+			"module-two": map[string]interface{}{
+				"helmchart": "humanitec/redis",
+			},
+		},
+	}
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"module-one": []UpdateAction{
+					UpdateAction{Operation: "replace", Path: "/values/image", Value: "registry.humanitec.io/my-org/module-one:1.0.1"},
+				},
+			},
+		},
+	}
+	expected := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"values": map[string]interface{}{
+					"image":    "registry.humanitec.io/my-org/module-one:1.0.1",
+					"replicas": 3.0,
+				},
+			},
+			"module-two": map[string]interface{}{
+				"helmchart": "humanitec/redis",
+			},
+		},
+		Version: CurrentVersion,
+	}
+
+	rebased, err := Rebase(oldBase, newBase, delta)
+	if err != nil {
+		t.Errorf("Rebase returned unexpected error: %v", err)
+		return
+	}
+	validateApply(newBase, rebased, expected, t)
+}
+
+func TestRebase_Conflict(t *testing.T) {
+	oldBase := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"image": "registry.humanitec.io/my-org/module-one:1.0.0",
+			},
+		},
+	}
+	newBase := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"image": "registry.humanitec.io/my-org/module-one:2.0.0",
+			},
+		},
+	}
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"module-one": []UpdateAction{
+					UpdateAction{Operation: "replace", Path: "/image", Value: "registry.humanitec.io/my-org/module-one:1.0.1"},
+				},
+			},
+		},
+	}
+	expectedConflicts := []Conflict{
+		Conflict{
+			Module: "module-one",
+			Path:   "/image",
+			Reason: "changed differently in ours and theirs",
+			Ours:   "registry.humanitec.io/my-org/module-one:1.0.1",
+			Theirs: "registry.humanitec.io/my-org/module-one:2.0.0",
+		},
+	}
+
+	_, err := Rebase(oldBase, newBase, delta)
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Errorf("Expected ConflictError, got `%v`", err)
+		return
+	}
+	if !reflect.DeepEqual(conflictErr.Conflicts, expectedConflicts) {
+		t.Errorf("Expected: `%+v`, got `%+v`", expectedConflicts, conflictErr.Conflicts)
+	}
+}
+
+func TestRebase_NotCompatibleWithOldBase(t *testing.T) {
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"missing-module": []UpdateAction{
+					UpdateAction{Operation: "add", Path: "/version", Value: "NEW_VERSION"},
+				},
+			},
+		},
+	}
+
+	_, err := Rebase(Set{}, Set{}, delta)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected error `%v`, got `%v`", ErrNotFound, err)
+	}
+}
+
+func TestMerge_ConflictPathIsEscaped(t *testing.T) {
+	base := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"values": map[string]interface{}{"a/b": "BASE"},
+			},
+		},
+	}
+	ours := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"values": map[string]interface{}{"a/b": "OURS"},
+			},
+		},
+	}
+	theirs := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"values": map[string]interface{}{"a/b": "THEIRS"},
+			},
+		},
+	}
+
+	_, err := Merge(base, ours, theirs)
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Errorf("Expected ConflictError, got `%v`", err)
+		return
+	}
+	if len(conflictErr.Conflicts) != 1 || conflictErr.Conflicts[0].Path != "/values/a~1b" {
+		t.Errorf("Expected one conflict at `/values/a~1b`, got `%+v`", conflictErr.Conflicts)
+	}
+}
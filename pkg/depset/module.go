@@ -0,0 +1,268 @@
+package depset
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"humanitec.io/deploymentset-svc/pkg/jsonpointer"
+)
+
+// ErrInvalid is wrapped by ValidationErrors.
+var ErrInvalid = errors.New("invalid")
+
+// maxModuleNameLength is the maximum length of a module name. Module names are used as Helm release names which are
+// limited to 53 characters.
+const maxModuleNameLength = 53
+
+// moduleNamePattern matches DNS-1123 labels (https://tools.ietf.org/html/rfc1123). These are lowercase alphanumeric
+// characters or '-', starting and ending with an alphanumeric character.
+var moduleNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// Module is the typed representation of a module spec as described in doc/data-format.md. Properties other than
+// helmchart and values are kept in Properties so that modules stored before the format was enforced are not changed by
+// converting them.
+type Module struct {
+	HelmChart  string                 `json:"helmchart"`
+	Values     map[string]interface{} `json:"values,omitempty"`
+	Properties map[string]interface{} `json:"-"`
+}
+
+// ValidationError describes a single problem with a Set or Delta. Module and Path are empty if the problem is not
+// specific to a module or a value within a module.
+type ValidationError struct {
+	Module  string `json:"module,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	switch {
+	case e.Module != "" && e.Path != "":
+		return fmt.Sprintf("module `%s` path `%s`: %s", e.Module, e.Path, e.Message)
+	case e.Module != "":
+		return fmt.Sprintf("module `%s`: %s", e.Module, e.Message)
+	case e.Path != "":
+		return fmt.Sprintf("path `%s`: %s", e.Path, e.Message)
+	}
+	return e.Message
+}
+
+// ValidationErrors is a list of all the problems found when validating a Set or Delta.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	descriptions := make([]string, len(e))
+	for i := range e {
+		descriptions[i] = e[i].Error()
+	}
+	return fmt.Sprintf("%d validation errors: %s", len(e), strings.Join(descriptions, "; "))
+}
+
+// Unwrap allows errors.Is to match ValidationErrors against ErrInvalid.
+func (e ValidationErrors) Unwrap() error {
+	return ErrInvalid
+}
+
+// ValidateModuleName checks that name can be used as a Helm release name.
+func ValidateModuleName(name string) ValidationErrors {
+	var errs ValidationErrors
+	if len(name) > maxModuleNameLength {
+		errs = append(errs, ValidationError{
+			Module:  name,
+			Message: fmt.Sprintf("module name must be no more than %d characters", maxModuleNameLength),
+		})
+	}
+	if !moduleNamePattern.MatchString(name) {
+		errs = append(errs, ValidationError{
+			Module:  name,
+			Message: "module name must consist of lowercase alphanumeric characters or '-', and must start and end with an alphanumeric character",
+		})
+	}
+	return errs
+}
+
+// ParseModule converts a module spec into a Module. If the spec is not valid, the list of problems is returned.
+func ParseModule(name string, spec map[string]interface{}) (Module, ValidationErrors) {
+	module, errs := parseModule(name, spec)
+	if _, ok := spec["helmchart"]; !ok {
+		errs = append(ValidationErrors{{Module: name, Path: "/helmchart", Message: "helmchart is required"}}, errs...)
+	}
+	if len(errs) > 0 {
+		return Module{}, errs
+	}
+	return module, nil
+}
+
+// parseModule converts the properties of a module spec that are present into a Module, checking their types. Unlike
+// ParseModule, helmchart is not required.
+func parseModule(name string, spec map[string]interface{}) (Module, ValidationErrors) {
+	var module Module
+	var errs ValidationErrors
+
+	if helmChart, ok := spec["helmchart"]; ok {
+		if helmChartStr, ok := helmChart.(string); !ok {
+			errs = append(errs, ValidationError{Module: name, Path: "/helmchart", Message: "helmchart must be a string"})
+		} else if helmChartStr == "" {
+			errs = append(errs, ValidationError{Module: name, Path: "/helmchart", Message: "helmchart must not be empty"})
+		} else {
+			module.HelmChart = helmChartStr
+		}
+	}
+
+	if values, ok := spec["values"]; ok {
+		if valuesObj, ok := values.(map[string]interface{}); ok {
+			module.Values = valuesObj
+		} else {
+			errs = append(errs, ValidationError{Module: name, Path: "/values", Message: "values must be an object"})
+		}
+	}
+
+	for key, value := range spec {
+		if key != "helmchart" && key != "values" {
+			if module.Properties == nil {
+				module.Properties = make(map[string]interface{})
+			}
+			module.Properties[key] = value
+		}
+	}
+
+	return module, errs
+}
+
+// Spec converts a Module into a module spec as stored in a Set.
+func (module Module) Spec() map[string]interface{} {
+	spec := map[string]interface{}{
+		"helmchart": module.HelmChart,
+	}
+	if module.Values != nil {
+		spec["values"] = copyValue(module.Values)
+	}
+	for key, value := range module.Properties {
+		spec[key] = copyValue(value)
+	}
+	return spec
+}
+
+// Module returns the typed representation of the named module in the Set.
+// The ErrNotFound sentinal error is returned if the module is not in the Set, ValidationErrors if it is not valid.
+func (inputSet Set) Module(name string) (Module, error) {
+	spec, ok := inputSet.Modules[name]
+	if !ok {
+		return Module{}, fmt.Errorf("module `%s`: %w", name, ErrNotFound)
+	}
+	module, errs := ParseModule(name, spec)
+	if len(errs) > 0 {
+		return Module{}, errs
+	}
+	return module, nil
+}
+
+// ValidateModule checks both the name and the spec of a module.
+//
+// Unless strict is true, only the types of helmchart and values are checked if they are present, so that modules
+// stored before the format was enforced can still be changed. If strict is true, the spec must have the shape described
+// in doc/data-format.md: helmchart is required and no other properties are allowed.
+func ValidateModule(name string, spec map[string]interface{}, strict bool) ValidationErrors {
+	errs := ValidateModuleName(name)
+	if !strict {
+		_, specErrs := parseModule(name, spec)
+		return append(errs, specErrs...)
+	}
+
+	_, specErrs := ParseModule(name, spec)
+	errs = append(errs, specErrs...)
+
+	unknownKeys := make([]string, 0)
+	for key := range spec {
+		if key != "helmchart" && key != "values" {
+			unknownKeys = append(unknownKeys, key)
+		}
+	}
+	sort.Strings(unknownKeys)
+	for _, key := range unknownKeys {
+		errs = append(errs, ValidationError{
+			Module:  name,
+			Path:    jsonpointer.Pointer{key}.String(),
+			Message: "unknown property, only helmchart and values are allowed",
+		})
+	}
+	return errs
+}
+
+// ValidateAddedModule checks a module that is being added rather than updated (see ValidateModule). There is no
+// stored module to stay compatible with, so helmchart is required even if strict is false.
+func ValidateAddedModule(name string, spec map[string]interface{}, strict bool) ValidationErrors {
+	if strict {
+		return ValidateModule(name, spec, true)
+	}
+	_, specErrs := ParseModule(name, spec)
+	return append(ValidateModuleName(name), specErrs...)
+}
+
+// Validate strictly checks every module in the Set. Modules are checked in name order.
+func (inputSet Set) Validate() ValidationErrors {
+	return inputSet.ValidateModules(true, getModuleSpecKeysAsSortedSlice(inputSet.Modules)...)
+}
+
+// ValidateModules checks only the named modules in the Set (see ValidateModule). Modules that are not in the Set are
+// ignored.
+func (inputSet Set) ValidateModules(strict bool, names ...string) ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range removeDuplicates(names) {
+		if spec, ok := inputSet.Modules[name]; ok {
+			errs = append(errs, ValidateModule(name, spec, strict)...)
+		}
+	}
+	return errs
+}
+
+// ValidateChangedModules checks the modules of the Set that were changed by delta, where the Set is the result of
+// applying delta. Modules added by delta are checked with ValidateAddedModule and updated modules with ValidateModule, so that
+// modules stored before the format was enforced can still be updated. Modules that are not in the Set are ignored.
+func (inputSet Set) ValidateChangedModules(strict bool, delta Delta) ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range delta.ChangedModules() {
+		spec, ok := inputSet.Modules[name]
+		if !ok {
+			continue
+		}
+		if delta.Modules.Add[name] != nil {
+			errs = append(errs, ValidateAddedModule(name, spec, strict)...)
+		} else {
+			errs = append(errs, ValidateModule(name, spec, strict)...)
+		}
+	}
+	return errs
+}
+
+// ValidateModules checks the names and specs of the named modules added by the Delta (see ValidateAddedModule).
+// Modules that are not added by the Delta are ignored, as are nil adds as they do not add a module.
+func (delta Delta) ValidateModules(strict bool, names ...string) ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range removeDuplicates(names) {
+		if spec := delta.Modules.Add[name]; spec != nil {
+			errs = append(errs, ValidateAddedModule(name, spec, strict)...)
+		}
+	}
+	return errs
+}
+
+// ChangedModules returns the sorted names of the modules added or updated by the Delta.
+func (delta Delta) ChangedModules() []string {
+	names := make(map[string]bool)
+	for name := range delta.Modules.Add {
+		names[name] = true
+	}
+	for name := range delta.Modules.Update {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
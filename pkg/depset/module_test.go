@@ -0,0 +1,219 @@
+package depset
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func validateValidationErrors(actual, expected ValidationErrors, t *testing.T) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: `%+v`, got `%+v`", expected, actual)
+	}
+}
+
+func TestValidateModuleName(t *testing.T) {
+	for _, name := range []string{"a", "module-one", "0-redis-cache-9", strings.Repeat("a", 53)} {
+		if errs := ValidateModuleName(name); len(errs) != 0 {
+			t.Errorf("Expected `%s` to be valid, got: %v", name, errs)
+		}
+	}
+}
+
+func TestValidateModuleName_Invalid(t *testing.T) {
+	for _, name := range []string{"", "Module-One", "module_one", "-module", "module-", "module.one", "módulo"} {
+		if errs := ValidateModuleName(name); len(errs) != 1 {
+			t.Errorf("Expected `%s` to have 1 validation error, got: %v", name, errs)
+		}
+	}
+	if errs := ValidateModuleName(strings.Repeat("a", 54)); len(errs) != 1 {
+		t.Errorf("Expected long name to have 1 validation error, got: %v", errs)
+	}
+}
+
+func TestParseModule(t *testing.T) {
+	spec := map[string]interface{}{
+		"helmchart": "humanitec/base-module",
+		"values": map[string]interface{}{
+			"image": "registry.humanitec.io/my-org/module-one:1.0.0",
+		},
+	}
+	expected := Module{
+		HelmChart: "humanitec/base-module",
+		Values: map[string]interface{}{
+			"image": "registry.humanitec.io/my-org/module-one:1.0.0",
+		},
+	}
+
+	module, errs := ParseModule("module-one", spec)
+	validateValidationErrors(errs, nil, t)
+	if !reflect.DeepEqual(module, expected) {
+		t.Errorf("Expected: `%+v`, got `%+v`", expected, module)
+	}
+	if !reflect.DeepEqual(module.Spec(), spec) {
+		t.Errorf("Expected: `%+v`, got `%+v`", spec, module.Spec())
+	}
+}
+
+func TestParseModule_ValuesOptional(t *testing.T) {
+	spec := map[string]interface{}{
+		"helmchart": "humanitec/redis",
+	}
+
+	module, errs := ParseModule("redis-cache", spec)
+	validateValidationErrors(errs, nil, t)
+	if !reflect.DeepEqual(module.Spec(), spec) {
+		t.Errorf("Expected: `%+v`, got `%+v`", spec, module.Spec())
+	}
+}
+
+func TestParseModule_Invalid(t *testing.T) {
+	testCases := []struct {
+		spec     map[string]interface{}
+		expected ValidationErrors
+	}{
+		{
+			spec:     map[string]interface{}{},
+			expected: ValidationErrors{{Module: "module-one", Path: "/helmchart", Message: "helmchart is required"}},
+		},
+		{
+			spec:     map[string]interface{}{"helmchart": 5.0},
+			expected: ValidationErrors{{Module: "module-one", Path: "/helmchart", Message: "helmchart must be a string"}},
+		},
+		{
+			spec:     map[string]interface{}{"helmchart": ""},
+			expected: ValidationErrors{{Module: "module-one", Path: "/helmchart", Message: "helmchart must not be empty"}},
+		},
+		{
+			spec: map[string]interface{}{"helmchart": "humanitec/redis", "values": []interface{}{}},
+			expected: ValidationErrors{
+				{Module: "module-one", Path: "/values", Message: "values must be an object"},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		_, errs := ParseModule("module-one", testCase.spec)
+		validateValidationErrors(errs, testCase.expected, t)
+	}
+}
+
+func TestParseModule_OtherProperties(t *testing.T) {
+	spec := map[string]interface{}{
+		"helmchart": "humanitec/base-module",
+		"image":     "registry.humanitec.io/my-org/module-one:1.0.0",
+	}
+
+	module, errs := ParseModule("module-one", spec)
+	validateValidationErrors(errs, nil, t)
+	if !reflect.DeepEqual(module.Spec(), spec) {
+		t.Errorf("Expected: `%+v`, got `%+v`", spec, module.Spec())
+	}
+}
+
+func TestValidateModule(t *testing.T) {
+	spec := map[string]interface{}{"profile": "humanitec/redis", "version": "1.0.0", "a/b": true}
+
+	validateValidationErrors(ValidateModule("module-one", spec, false), nil, t)
+	validateValidationErrors(ValidateModule("module-one", map[string]interface{}{"helmchart": 5.0}, false), ValidationErrors{
+		{Module: "module-one", Path: "/helmchart", Message: "helmchart must be a string"},
+	}, t)
+	validateValidationErrors(ValidateModule("module-one", spec, true), ValidationErrors{
+		{Module: "module-one", Path: "/helmchart", Message: "helmchart is required"},
+		{Module: "module-one", Path: "/a~1b", Message: "unknown property, only helmchart and values are allowed"},
+		{Module: "module-one", Path: "/profile", Message: "unknown property, only helmchart and values are allowed"},
+		{Module: "module-one", Path: "/version", Message: "unknown property, only helmchart and values are allowed"},
+	}, t)
+}
+
+func TestSetModule(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{"helmchart": "humanitec/base-module"},
+			"invalid":    map[string]interface{}{},
+		},
+	}
+
+	module, err := inputSet.Module("module-one")
+	if err != nil || module.HelmChart != "humanitec/base-module" {
+		t.Errorf("Expected module with helmchart `humanitec/base-module`, got `%+v` (%v)", module, err)
+	}
+
+	if _, err := inputSet.Module("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got: %v", err)
+	}
+	if _, err := inputSet.Module("invalid"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Expected ErrInvalid, got: %v", err)
+	}
+}
+
+func TestSetValidate(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one":   map[string]interface{}{"helmchart": "humanitec/base-module"},
+			"Module_Two":   map[string]interface{}{"helmchart": "humanitec/base-module"},
+			"module-three": map[string]interface{}{"values": map[string]interface{}{}},
+		},
+	}
+	expected := ValidationErrors{
+		{Module: "Module_Two", Message: "module name must consist of lowercase alphanumeric characters or '-', and must start and end with an alphanumeric character"},
+		{Module: "module-three", Path: "/helmchart", Message: "helmchart is required"},
+	}
+
+	validateValidationErrors(inputSet.Validate(), expected, t)
+	validateValidationErrors(inputSet.ValidateModules(true, "module-one", "module-three", "missing"), expected[1:], t)
+	validateValidationErrors(inputSet.ValidateModules(false, "module-one", "module-three", "missing"), nil, t)
+}
+
+func TestDeltaValidateModules(t *testing.T) {
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-one":   map[string]interface{}{"helmchart": "humanitec/base-module", "replicas": 2.0},
+				"module-two":   map[string]interface{}{"values": map[string]interface{}{}},
+				"module-three": nil,
+			},
+			Update: map[string][]UpdateAction{
+				"module-four": []UpdateAction{{Operation: "remove", Path: "/helmchart"}},
+			},
+		},
+	}
+
+	// helmchart is required for added modules even if strict is false
+	validateValidationErrors(delta.ValidateModules(true, delta.ChangedModules()...), ValidationErrors{
+		{Module: "module-one", Path: "/replicas", Message: "unknown property, only helmchart and values are allowed"},
+		{Module: "module-two", Path: "/helmchart", Message: "helmchart is required"},
+	}, t)
+	validateValidationErrors(delta.ValidateModules(false, delta.ChangedModules()...), ValidationErrors{
+		{Module: "module-two", Path: "/helmchart", Message: "helmchart is required"},
+	}, t)
+}
+
+func TestSetValidateChangedModules(t *testing.T) {
+	set := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one":   map[string]interface{}{"values": map[string]interface{}{}},
+			"module-two":   map[string]interface{}{"values": map[string]interface{}{"replicas": 2.0}},
+			"module-three": map[string]interface{}{"helmchart": 5.0},
+		},
+	}
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-one": map[string]interface{}{"values": map[string]interface{}{}},
+			},
+			Update: map[string][]UpdateAction{
+				"module-two": []UpdateAction{{Operation: "add", Path: "/values/replicas", Value: 2.0}},
+			},
+		},
+	}
+
+	// Only the added module needs a helmchart unless strict is true; module-three is not changed so is not checked.
+	validateValidationErrors(set.ValidateChangedModules(false, delta), ValidationErrors{
+		{Module: "module-one", Path: "/helmchart", Message: "helmchart is required"},
+	}, t)
+	validateValidationErrors(set.ValidateChangedModules(true, delta), ValidationErrors{
+		{Module: "module-one", Path: "/helmchart", Message: "helmchart is required"},
+		{Module: "module-two", Path: "/helmchart", Message: "helmchart is required"},
+	}, t)
+}
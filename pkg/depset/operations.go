@@ -1,9 +1,6 @@
 package depset
 
 import (
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
@@ -22,124 +19,225 @@ var ErrNotFound = errors.New("not found")
 // ErrTypeMismatch returned when the type of an object is not what was expected.
 var ErrTypeMismatch = errors.New("type mismatch")
 
+// ErrTestFailed returned when a "test" UpdateAction does not match the value in the object.
+var ErrTestFailed = errors.New("test failed")
+
+// copyModuleSpec makes a deep copy of a module spec so that nested objects and arrays can be updated without
+// affecting the original.
 func copyModuleSpec(ms map[string]interface{}) map[string]interface{} {
-	// for now we assume that all values are actially value type and not secretly maps or slices...
-	// Maybe we should use something like: https://gist.github.com/soroushjp/0ec92102641ddfc3ad5515ca76405f4d
-	out := make(map[string]interface{})
+	out := make(map[string]interface{}, len(ms))
 	for k := range ms {
-		out[k] = ms[k]
+		out[k] = copyValue(ms[k])
 	}
 	return out
 }
 
-// applyUpdateAction applies a JSON-PATCH (https://tools.ietf.org/html/rfc6902) action to a structure derrived from a
-// JSON object.
-// The update happens in place. If an error is thrown, the state of object is undefined.
-func applyUpdateAction(action UpdateAction, object map[string]interface{}) error {
-	parent, key, err := jsonpointer.ExtractParent(object, action.Path)
+// copyDelta makes a deep copy of a Delta. nil maps and slices stay nil.
+func copyDelta(delta Delta) Delta {
+	out := Delta{}
+	if delta.Modules.Add != nil {
+		out.Modules.Add = make(map[string]map[string]interface{}, len(delta.Modules.Add))
+		for name, module := range delta.Modules.Add {
+			if module == nil {
+				out.Modules.Add[name] = nil
+			} else {
+				out.Modules.Add[name] = copyModuleSpec(module)
+			}
+		}
+	}
+	if delta.Modules.Remove != nil {
+		out.Modules.Remove = make([]string, len(delta.Modules.Remove))
+		copy(out.Modules.Remove, delta.Modules.Remove)
+	}
+	if delta.Modules.Update != nil {
+		out.Modules.Update = make(map[string][]UpdateAction, len(delta.Modules.Update))
+		for name, actions := range delta.Modules.Update {
+			out.Modules.Update[name] = make([]UpdateAction, len(actions))
+			for i, action := range actions {
+				action.Value = copyValue(action.Value)
+				out.Modules.Update[name][i] = action
+			}
+		}
+	}
+	if delta.Preconditions != nil {
+		preconditions := Preconditions{SetID: delta.Preconditions.SetID}
+		if delta.Preconditions.Values != nil {
+			preconditions.Values = make([]ValuePrecondition, len(delta.Preconditions.Values))
+			for i, precondition := range delta.Preconditions.Values {
+				precondition.Value = copyValue(precondition.Value)
+				preconditions.Values[i] = precondition
+			}
+		}
+		out.Preconditions = &preconditions
+	}
+	return out
+}
+
+// copyValue makes a deep copy of a value derrived from a JSON object.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k := range v {
+			out[k] = copyValue(v[k])
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = copyValue(v[i])
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// pointerError is returned when a json-pointer in an UpdateAction cannot be resolved. errors.Is matches both the
+// jsonpointer error and the equivalent error of this package (ErrNotFound or ErrTypeMismatch).
+type pointerError struct {
+	path string
+	err  error
+}
+
+func (e *pointerError) Error() string {
+	return fmt.Sprintf("path `%s`: %v", e.path, e.err)
+}
+
+func (e *pointerError) Unwrap() error {
+	return e.err
+}
+
+func (e *pointerError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return errors.Is(e.err, jsonpointer.ErrDoesNotExist)
+	case ErrTypeMismatch:
+		return errors.Is(e.err, jsonpointer.ErrNotContainer) || errors.Is(e.err, jsonpointer.ErrInvalidIndex)
+	}
+	return false
+}
+
+// applyMoveOrCopy applies a "move" or "copy" action by extracting the value at action.From and adding it at
+// action.Path. For "move", the value at action.From is removed first.
+func applyMoveOrCopy(action UpdateAction, object map[string]interface{}) error {
+	value, err := jsonpointer.Extract(object, action.From)
 	if err != nil {
-		return fmt.Errorf("path `%s`: %w", action.Path, err)
+		return &pointerError{path: action.From, err: err}
 	}
 
-	if mapObj, ok := parent.(map[string]interface{}); ok {
-		switch action.Operation {
-		case "add":
-			mapObj[key] = action.Value
+	if action.Operation == "copy" {
+		return applyUpdateAction(UpdateAction{Operation: "add", Path: action.Path, Value: copyValue(value)}, object)
+	}
 
-		case "remove":
-			delete(mapObj, key)
+	if action.From == action.Path {
+		return nil
+	}
+	path, err := jsonpointer.Parse(action.Path)
+	if err != nil {
+		return &pointerError{path: action.Path, err: err}
+	}
+	from, _ := jsonpointer.Parse(action.From) // We know this works because it has worked in Extract earlier
+	if path.HasPrefix(from) {
+		return fmt.Errorf("cannot move `%s` into its own child `%s`: %w", action.From, action.Path, ErrNotSupported)
+	}
+	err = applyUpdateAction(UpdateAction{Operation: "remove", Path: action.From}, object)
+	if err != nil {
+		return err
+	}
+	return applyUpdateAction(UpdateAction{Operation: "add", Path: action.Path, Value: value}, object)
+}
 
-		case "replace":
-			if _, ok := mapObj[key]; ok {
-				mapObj[key] = action.Value
-			} else {
-				return fmt.Errorf("path `%s` does not exist: %w", action.Path, ErrNotFound)
-			}
+// applyTest checks that the value at action.Path is equal to action.Value.
+func applyTest(action UpdateAction, object map[string]interface{}) error {
+	value, err := jsonpointer.Extract(object, action.Path)
+	if err != nil {
+		return &pointerError{path: action.Path, err: err}
+	}
+	if !reflect.DeepEqual(value, action.Value) {
+		return fmt.Errorf("path `%s` is `%v` not `%v`: %w", action.Path, value, action.Value, ErrTestFailed)
+	}
+	return nil
+}
 
-		default:
-			return ErrNotSupported
-		}
-	} else if slice, ok := parent.([]interface{}); ok {
-		// Becasue we need to manipulate the slice which might involve creating a new slice, we need the
-		// parent object of the slice.
-		parentOfParent, parentKey, _ := jsonpointer.ExtractParentOfParent(object, action.Path)
-		switch action.Operation {
-		case "add":
-			var target []interface{}
-			if key == "-" {
-				target = append(slice, action.Value)
-			} else {
-				if index, err := strconv.Atoi(key); err == nil {
-					if index < len(slice) {
-						target = append(slice[:index], append([]interface{}{action.Value}, slice[index:]...)...)
-					} else {
-						return fmt.Errorf("index in path `%s` out of range: %w", action.Path, ErrNotFound)
-					}
-				} else {
-					return fmt.Errorf("path `%s` refers to array and does not have a numerical index: %w", action.Path, ErrTypeMismatch)
-				}
-			}
-			if parentMapObj, ok := parentOfParent.(map[string]interface{}); ok {
-				parentMapObj[parentKey] = target
-			} else {
-				parentSlice := parentOfParent.([]interface{})
-				parentIndex, _ := strconv.Atoi(parentKey) // We know this works because it has worked in ExtractParent earlier
-				parentSlice[parentIndex] = target
-			}
+// applyUpdateAction applies a JSON-PATCH (https://tools.ietf.org/html/rfc6902) action to a structure derrived from a
+// JSON object.
+// The update happens in place. If an error is thrown, the state of object is undefined. The action is never updated.
+func applyUpdateAction(action UpdateAction, object map[string]interface{}) error {
+	switch action.Operation {
+	case "move", "copy":
+		return applyMoveOrCopy(action, object)
+	case "test":
+		return applyTest(action, object)
+	}
 
-		case "remove":
-			if index, err := strconv.Atoi(key); err == nil {
-				if index < len(slice) {
-					if parentMapObj, ok := parentOfParent.(map[string]interface{}); ok {
-						parentMapObj[parentKey] = append(slice[:index], slice[index+1:]...)
-					} else {
-						parentSlice := parentOfParent.([]interface{})
-						parentIndex, _ := strconv.Atoi(parentKey) // We know this works because it has worked in ExtractParent earlier
-						parentSlice[parentIndex] = append(slice[:index], slice[index+1:]...)
-					}
-				} else {
-					return fmt.Errorf("index in path `%s` out of range: %w", action.Path, ErrNotFound)
-				}
-			} else {
-				return fmt.Errorf("path `%s` refers to array and does not have a numerical index: %w", action.Path, ErrTypeMismatch)
-			}
-		case "replace":
-			if index, err := strconv.Atoi(key); err == nil {
-				if index < len(slice) {
-					slice[index] = action.Value
-				} else {
-					return fmt.Errorf("index in path `%s` out of range: %w", action.Path, ErrNotFound)
+	if action.Path == "" {
+		return fmt.Errorf("cannot %s the whole module: %w", action.Operation, ErrNotSupported)
+	}
+
+	// The value is copied so that later updates to object cannot change the action.
+	value := copyValue(action.Value)
+
+	// As the path is not empty, object itself is always updated in place so the updated document returned by the
+	// jsonpointer functions can be ignored.
+	var err error
+	switch action.Operation {
+	case "add":
+		_, err = jsonpointer.Insert(object, action.Path, value)
+
+	case "remove":
+		// Removing a property that does not exist is ignored, in the same way as removing a module that does not
+		// exist. (ApplyStrict does check.)
+		if parent, key, parentErr := jsonpointer.ExtractParent(object, action.Path); parentErr == nil {
+			if mapObj, ok := parent.(map[string]interface{}); ok {
+				if _, exists := mapObj[key]; !exists {
+					return nil
 				}
-			} else {
-				return fmt.Errorf("path `%s` refers to array and does not have a numerical index: %w", action.Path, ErrTypeMismatch)
 			}
-
-		default:
-			return ErrNotSupported
 		}
-	} else {
-		return fmt.Errorf("parent of path `%s` must be an array or object to be updateable. got (%v): %w", action.Path, reflect.TypeOf(parent), ErrTypeMismatch)
+		_, err = jsonpointer.Remove(object, action.Path)
+
+	case "replace":
+		_, err = jsonpointer.Replace(object, action.Path, value)
+
+	default:
+		return ErrNotSupported
+	}
+	if err != nil {
+		return &pointerError{path: action.Path, err: err}
 	}
 	return nil
 }
 
-// Apply generates a new Deployment Set from an existsing set by applying a Deployment Delta.
+// Apply generates a new Deployment Set from an existsing set by applying a Deployment Delta. The new set is always at
+// CurrentVersion.
+// If the preconditions of the Delta do not hold, a *ConflictError listing them is returned (see CheckPreconditions).
+// Neither inputSet nor delta are updated. The returned Set is a deep copy and shares no maps or slices with either.
 func (inputSet Set) Apply(delta Delta) (Set, error) {
 	// Note: The Set structure makes a lot of use of map
 	// In Go, maps are always passed by referece, so they should not be mutated
-	// For this function, we need to make sure we *never* update any map inside inputSet
+	// For this function, we need to make sure we *never* update any map inside inputSet, at any depth. This is why
+	// module specs are deep copied before any update actions are applied.
 
-	// TODO: Check for conflicts.
+	if err := inputSet.CheckPreconditions(delta); err != nil {
+		return Set{}, err
+	}
+
+	// The delta is written against the current schema, so older sets are upgraded first.
+	inputSet, err := Upgrade(inputSet)
+	if err != nil {
+		return Set{}, err
+	}
 
 	set := Set{
 		Modules: make(map[string]map[string]interface{}),
-		Version: 0,
+		Version: CurrentVersion,
 	}
 
 	removeModules := make(map[string]bool)
 	for _, name := range delta.Modules.Remove {
 		// Question: Should we check if a module to be removes actually exists?
-		// Probably not, as the "desired state" would be no module.
+		// Probably not, as the "desired state" would be no module. (ApplyStrict does check.)
 		removeModules[name] = true
 	}
 
@@ -154,7 +252,7 @@ func (inputSet Set) Apply(delta Delta) (Set, error) {
 	// Add modules
 	for name, values := range delta.Modules.Add {
 		// Question: Should we check if module exists in set *before* adding it. Otherwise an add becomes a replace.
-		// Probably not, as the "desired state" would be this module
+		// Probably not, as the "desired state" would be this module. (ApplyStrict does check.)
 
 		set.Modules[name] = copyModuleSpec(values)
 	}
@@ -176,6 +274,98 @@ func (inputSet Set) Apply(delta Delta) (Set, error) {
 	return set, nil
 }
 
+// strictActionConflict returns a reason if action would silently succeed on object even though the path it refers to
+// is not in the expected state. An empty string means there is no conflict.
+func strictActionConflict(action UpdateAction, object map[string]interface{}) string {
+	switch action.Operation {
+	case "add":
+		parent, key, err := jsonpointer.ExtractParent(object, action.Path)
+		if err != nil {
+			return ""
+		}
+		if mapObj, ok := parent.(map[string]interface{}); ok {
+			if _, exists := mapObj[key]; exists {
+				return "path to add already exists"
+			}
+		}
+	case "remove":
+		if _, err := jsonpointer.Extract(object, action.Path); err != nil {
+			return "path to remove does not exist"
+		}
+	}
+	return ""
+}
+
+// ApplyStrict generates a new Deployment Set in the same way as Apply but rejects deltas that do not exactly match
+// the input set. Adding a module or path that already exists, removing a module or path that does not exist and
+// updating a module that does not exist are all conflicts.
+//
+// Rather than stopping at the first conflict, all conflicts are collected and returned in a *ConflictError. Failed
+// preconditions are reported first.
+func (inputSet Set) ApplyStrict(delta Delta) (Set, error) {
+	var conflicts []Conflict
+
+	if err := inputSet.CheckPreconditions(delta); err != nil {
+		var conflictErr *ConflictError
+		if !errors.As(err, &conflictErr) {
+			return Set{}, err
+		}
+		conflicts = append(conflicts, conflictErr.Conflicts...)
+	}
+
+	for _, name := range delta.Modules.Remove {
+		if _, exists := inputSet.Modules[name]; !exists {
+			conflicts = append(conflicts, Conflict{Module: name, Reason: "module to remove does not exist"})
+		}
+	}
+
+	for _, name := range getModuleSpecKeysAsSortedSlice(delta.Modules.Add) {
+		if _, exists := inputSet.Modules[name]; exists && !isInList(delta.Modules.Remove, name) {
+			conflicts = append(conflicts, Conflict{Module: name, Reason: "module to add already exists"})
+		}
+	}
+
+	// Apply everything but the updates leniently, then apply the updates one action at a time so that all
+	// conflicting actions are found.
+	set, err := inputSet.Apply(Delta{
+		Modules: ModuleDeltas{
+			Add:    delta.Modules.Add,
+			Remove: delta.Modules.Remove,
+		},
+	})
+	if err != nil {
+		return Set{}, err
+	}
+
+	updateModuleNames := make([]string, 0, len(delta.Modules.Update))
+	for name := range delta.Modules.Update {
+		updateModuleNames = append(updateModuleNames, name)
+	}
+	sort.Strings(updateModuleNames)
+
+	for _, name := range updateModuleNames {
+		module, ok := set.Modules[name]
+		if !ok {
+			conflicts = append(conflicts, Conflict{Module: name, Reason: "module to update does not exist"})
+			continue
+		}
+		for _, action := range delta.Modules.Update[name] {
+			if reason := strictActionConflict(action, module); reason != "" {
+				conflicts = append(conflicts, Conflict{Module: name, Path: action.Path, Reason: reason})
+				continue
+			}
+			if err := applyUpdateAction(action, module); err != nil {
+				conflicts = append(conflicts, Conflict{Module: name, Path: action.Path, Reason: err.Error()})
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return Set{}, &ConflictError{Conflicts: conflicts}
+	}
+	return set, nil
+}
+
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -183,46 +373,117 @@ func max(a, b int) int {
 	return b
 }
 
-func ModuleSpecDiff(left, right map[string]interface{}) []UpdateAction {
-	updates := make([]UpdateAction, 0, max(len(left), len(right)))
+// diffValues appends the update actions that convert right into left to updates. Objects are compared property by
+// property and arrays element by element (see diffArrays) so that the actions refer to the deepest path that differs.
+// All other values are replaced as a whole.
+func diffValues(path jsonpointer.Pointer, left, right interface{}, updates []UpdateAction) []UpdateAction {
+	leftObj, leftIsObj := left.(map[string]interface{})
+	rightObj, rightIsObj := right.(map[string]interface{})
+	if leftIsObj && rightIsObj {
+		return diffObjects(path, leftObj, rightObj, updates)
+	}
+	leftSlice, leftIsSlice := left.([]interface{})
+	rightSlice, rightIsSlice := right.([]interface{})
+	if leftIsSlice && rightIsSlice {
+		return diffArrays(path, leftSlice, rightSlice, updates)
+	}
+	if !reflect.DeepEqual(left, right) {
+		updates = append(updates, valueAction("replace", path.String(), copyValue(left)))
+	}
+	return updates
+}
 
-	for rightSpec := range right {
-		_, exists := left[rightSpec]
-		if exists {
-			// config is common to both - replace
-			// only update if they are different
-			if !reflect.DeepEqual(left[rightSpec], right[rightSpec]) {
-				updates = append(updates, UpdateAction{
-					Operation: "replace",
-					Path:      "/" + rightSpec,
-					Value:     left[rightSpec],
-				})
-			}
-		} else {
-			// 	only in right - should be removed
+// diffObjects appends the update actions that convert the right object into the left object to updates. Properties
+// are visited in key order so that the actions are always generated in the same order.
+func diffObjects(path jsonpointer.Pointer, left, right map[string]interface{}, updates []UpdateAction) []UpdateAction {
+	keys := getMapKeysAsSortedSlice(right)
+	for leftKey := range left {
+		if _, exists := right[leftKey]; !exists {
+			keys = append(keys, leftKey)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		_, inLeft := left[key]
+		_, inRight := right[key]
+		switch {
+		case inLeft && inRight:
+			// property is common to both - compare the values
+			updates = diffValues(path.Append(key), left[key], right[key], updates)
+		case inRight:
+			// only in right - should be removed
 			updates = append(updates, UpdateAction{
 				Operation: "remove",
-				Path:      "/" + rightSpec,
+				Path:      path.Append(key).String(),
 			})
+		default:
+			// only in left - add
+			updates = append(updates, valueAction("add", path.Append(key).String(), copyValue(left[key])))
 		}
 	}
+	return updates
+}
 
-	for leftSpec := range left {
-		_, exists := right[leftSpec]
-		if !exists {
-			// config is only in right - add
+// diffArrays appends the update actions that convert the right array into the left array to updates. The elements
+// common to both are found with a longest common subsequence, so inserting or removing a single element results in a
+// single "add" or "remove" action rather than replacing the array. An element removed from right at the same place an
+// element is added from left is diffed with diffValues instead.
+//
+// The actions are applied in order, so each index refers to the array as updated by the actions before it.
+func diffArrays(path jsonpointer.Pointer, left, right []interface{}, updates []UpdateAction) []UpdateAction {
+	// lcs[i][j] is the length of the longest common subsequence of right[i:] and left[j:].
+	lcs := make([][]int, len(right)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(left)+1)
+	}
+	for i := len(right) - 1; i >= 0; i-- {
+		for j := len(left) - 1; j >= 0; j-- {
+			if reflect.DeepEqual(right[i], left[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// index is the position of right[i] in the array as updated so far.
+	i, j, index := 0, 0, 0
+	for i < len(right) || j < len(left) {
+		elementPath := path.Append(strconv.Itoa(index))
+		switch {
+		case i < len(right) && j < len(left) && reflect.DeepEqual(right[i], left[j]):
+			i, j, index = i+1, j+1, index+1
+		case i < len(right) && j < len(left) && lcs[i+1][j+1] == lcs[i][j]:
+			// Neither element is part of the common subsequence, so one can be changed into the other.
+			updates = diffValues(elementPath, left[j], right[i], updates)
+			i, j, index = i+1, j+1, index+1
+		case j == len(left) || (i < len(right) && lcs[i+1][j] >= lcs[i][j+1]):
 			updates = append(updates, UpdateAction{
-				Operation: "add",
-				Path:      "/" + leftSpec,
-				Value:     left[leftSpec],
+				Operation: "remove",
+				Path:      elementPath.String(),
 			})
+			i++
+		default:
+			updates = append(updates, valueAction("add", elementPath.String(), copyValue(left[j])))
+			j, index = j+1, index+1
 		}
 	}
 	return updates
 }
 
+// ModuleSpecDiff generates the update actions that convert the right module spec into the left module spec.
+// Nested objects and arrays are compared recursively, so each action refers to the deepest path that differs. Actions
+// are ordered by path, except that the actions for an array are in the order they must be applied in.
+func ModuleSpecDiff(left, right map[string]interface{}) []UpdateAction {
+	return diffObjects(jsonpointer.Pointer{}, left, right, make([]UpdateAction, 0, max(len(left), len(right))))
+}
+
 // Diff generates the Delta between two sets. Specifically, if the generated delta is applied to rightSet, leftSet is
 // generated.
+// The Delta is canonical: Remove is sorted by module name and the update actions for each module are ordered by path
+// (see ModuleSpecDiff), so diffing the same two sets always produces the same Delta.
+// Values in the returned Delta are copied from leftSet, so updating the Delta does not update either Set.
 func (leftSet Set) Diff(rightSet Set) Delta {
 	delta := Delta{
 		Modules: ModuleDeltas{
@@ -233,7 +494,8 @@ func (leftSet Set) Diff(rightSet Set) Delta {
 	}
 	// Find all modules that are in rightSet but not in leftSet
 	// Also deal with modules that are common to both
-	for rightModuleName := range rightSet.Modules {
+	// Modules are visited in name order so that Remove is sorted.
+	for _, rightModuleName := range getModuleSpecKeysAsSortedSlice(rightSet.Modules) {
 		_, exists := leftSet.Modules[rightModuleName]
 		if exists {
 			// Module is common to both
@@ -247,17 +509,30 @@ func (leftSet Set) Diff(rightSet Set) Delta {
 		}
 	}
 
-	// Find all modules that are in leftSet but not in leftSet
+	// Find all modules that are in leftSet but not in rightSet
 	for leftModuleName := range leftSet.Modules {
 		_, exists := rightSet.Modules[leftModuleName]
 		if !exists {
 			// Module is only in left - add it
-			delta.Modules.Add[leftModuleName] = leftSet.Modules[leftModuleName]
+			delta.Modules.Add[leftModuleName] = copyModuleSpec(leftSet.Modules[leftModuleName])
 		}
 	}
 	return delta
 }
 
+// Invert generates the Delta that undoes delta. Specifically, if delta is applied to base and then the inverted delta
+// is applied to the result, base is generated. As Apply always generates sets at CurrentVersion, an older base is
+// generated upgraded to CurrentVersion. Upgrading does not change the shape of a version 0 set, so the generated set
+// has the same ID as base.
+// Removed modules are re-added with their specs from base and replaced or removed values are restored.
+func (delta Delta) Invert(base Set) (Delta, error) {
+	result, err := base.Apply(delta)
+	if err != nil {
+		return Delta{}, fmt.Errorf("delta not compatible with base: %w", err)
+	}
+	return base.Diff(result), nil
+}
+
 func removeDuplicates(a []string) []string {
 	buf := make(map[string]int)
 	b := make([]string, 0, len(buf))
@@ -274,6 +549,15 @@ func removeDuplicates(a []string) []string {
 	return b
 }
 
+func isInList(a []string, value string) bool {
+	for i := range a {
+		if a[i] == value {
+			return true
+		}
+	}
+	return false
+}
+
 func removeFromList(a []string, value string) []string {
 	b := make([]string, 0, len(a))
 	for i := range a {
@@ -284,10 +568,37 @@ func removeFromList(a []string, value string) []string {
 	return b
 }
 
+// mergePreconditions combines the preconditions of two Deltas that will be applied to the same Set. A *ConflictError is
+// returned if they require different Set IDs.
+func mergePreconditions(a, b *Preconditions) (*Preconditions, error) {
+	if b == nil {
+		return a, nil
+	}
+	if a == nil {
+		return b, nil
+	}
+	merged := Preconditions{SetID: a.SetID}
+	if b.SetID != "" {
+		if a.SetID != "" && a.SetID != b.SetID {
+			return nil, &ConflictError{Conflicts: []Conflict{{
+				Reason: "preconditions require different set IDs",
+				Ours:   a.SetID,
+				Theirs: b.SetID,
+			}}}
+		}
+		merged.SetID = b.SetID
+	}
+	merged.Values = append(append(merged.Values, a.Values...), b.Values...)
+	return &merged, nil
+}
+
 // MergeDeltas combines an array of deltas into a single delta.
 // NOTE: Order matters. E.g. Update
-// NOTE: This implementation updates baseDelta in place...
+// The preconditions of all the deltas are combined. A *ConflictError is returned if they require different Set IDs.
+// None of the supplied deltas are updated, the returned Delta shares no maps, slices or values with them.
 func MergeDeltas(baseDelta Delta, deltas ...Delta) (Delta, error) {
+	baseDelta = copyDelta(baseDelta)
+
 	// Sanitize Input, maps should not be nil:
 	if nil == baseDelta.Modules.Add {
 		baseDelta.Modules.Add = make(map[string]map[string]interface{})
@@ -297,6 +608,13 @@ func MergeDeltas(baseDelta Delta, deltas ...Delta) (Delta, error) {
 	}
 
 	for deltaIndex, delta := range deltas {
+		delta = copyDelta(delta)
+		preconditions, err := mergePreconditions(baseDelta.Preconditions, delta.Preconditions)
+		if err != nil {
+			return Delta{}, fmt.Errorf("preconditions for delta at index %d: %w", deltaIndex, err)
+		}
+		baseDelta.Preconditions = preconditions
+
 		for _, removeModuleName := range delta.Modules.Remove {
 			delete(baseDelta.Modules.Add, removeModuleName)
 			delete(baseDelta.Modules.Update, removeModuleName)
@@ -326,76 +644,17 @@ func MergeDeltas(baseDelta Delta, deltas ...Delta) (Delta, error) {
 					}
 				}
 			} else {
-				// for now, just append.
-				// In future we can combine updates into the smallest set of update statements...
-				if _, ok := baseDelta.Modules.Update[updateModuleName]; !ok {
-					baseDelta.Modules.Update[updateModuleName] = moduleUpdates
+				// Append the updates and then combine them into the smallest set of update actions.
+				combinedUpdates := make([]UpdateAction, 0, len(baseDelta.Modules.Update[updateModuleName])+len(moduleUpdates))
+				combinedUpdates = append(combinedUpdates, baseDelta.Modules.Update[updateModuleName]...)
+				combinedUpdates = CompactUpdates(nil, append(combinedUpdates, moduleUpdates...))
+				if len(combinedUpdates) > 0 {
+					baseDelta.Modules.Update[updateModuleName] = combinedUpdates
 				} else {
-					baseDelta.Modules.Update[updateModuleName] = append(baseDelta.Modules.Update[updateModuleName], moduleUpdates...)
+					delete(baseDelta.Modules.Update, updateModuleName)
 				}
 			}
 		}
 	}
 	return baseDelta, nil
 }
-
-func getMapKeysAsSortedSlice(m map[string]interface{}) []string {
-	a := make([]string, len(m))
-	i := 0
-	for k := range m {
-		a[i] = k
-		i++
-	}
-	sort.StringSlice(a).Sort()
-	return a
-}
-
-func getModuleSpecKeysAsSortedSlice(m map[string]map[string]interface{}) []string {
-	a := make([]string, len(m))
-	i := 0
-	for k := range m {
-		a[i] = k
-		i++
-	}
-	sort.StringSlice(a).Sort()
-	return a
-}
-
-func getModuleSpecAsSortedSlice(m map[string]interface{}) [][2]interface{} {
-	sortedKeys := getMapKeysAsSortedSlice(m)
-
-	kvpArr := make([][2]interface{}, len(m))
-	for i := range sortedKeys {
-		kvpArr[i] = [2]interface{}{sortedKeys[i], m[sortedKeys[i]]}
-	}
-	return kvpArr
-}
-
-func getModulesAsSortedSlice(m map[string]map[string]interface{}) [][2]interface{} {
-	sortedModules := getModuleSpecKeysAsSortedSlice(m)
-
-	kvpArr := make([][2]interface{}, len(m))
-	for i := range sortedModules {
-		kvpArr[i] = [2]interface{}{sortedModules[i], getModuleSpecAsSortedSlice(m[sortedModules[i]])}
-	}
-	return kvpArr
-}
-
-// Hash generates an invarient id for a Deployment Set
-func (inputSet Set) Hash() string {
-	// For now, we hack it by converting the deployment set into an array structure
-	// This is because we cannot control key order using the built in go json serializer.
-
-	// sepecial case for the empty set, the hash is zero
-	if len(inputSet.Modules) == 0 {
-		return "0000000000000000000000000000000000000000000"
-	}
-
-	arrSet := [2]interface{}{"modules", getModulesAsSortedSlice(inputSet.Modules)}
-
-	buf, _ := json.Marshal(arrSet)
-	checksum := sha256.Sum256(buf)
-
-	// RawURLEncoding makes for URL safe IDs that don't have trailing '='. This means no URL encoding required.
-	return base64.RawURLEncoding.EncodeToString(checksum[:])
-}
@@ -1,9 +1,14 @@
 package depset
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"log"
 	"reflect"
 	"testing"
+
+	"humanitec.io/deploymentset-svc/pkg/jsonpointer"
 )
 
 func orderInvariantEqual(aIn, bIn interface{}) bool {
@@ -69,6 +74,7 @@ func TestApplyToEmptySet(t *testing.T) {
 				"version": "TEST_VERSION",
 			},
 		},
+		Version: CurrentVersion,
 	}
 	validateApply(emptySet, delta, expectedSet, t)
 }
@@ -88,7 +94,7 @@ func TestApplyRemoveSingleModule(t *testing.T) {
 			},
 		},
 	}
-	expectedSet := Set{Modules: make(map[string]map[string]interface{})}
+	expectedSet := Set{Modules: make(map[string]map[string]interface{}), Version: CurrentVersion}
 	validateApply(inputSet, delta, expectedSet, t)
 }
 
@@ -117,6 +123,7 @@ func TestApplyRemoveModule(t *testing.T) {
 				"version": "TEST_VERSION",
 			},
 		},
+		Version: CurrentVersion,
 	}
 	validateApply(inputSet, delta, expectedSet, t)
 }
@@ -150,6 +157,7 @@ func TestApplyUpdateModuleAddField(t *testing.T) {
 				"NEW_FIELD": "NEW_VALUE",
 			},
 		},
+		Version: CurrentVersion,
 	}
 
 	validateApply(inputSet, delta, expectedSet, t)
@@ -202,6 +210,7 @@ func TestApplyUpdateModuleManipulateSubObject(t *testing.T) {
 				},
 			},
 		},
+		Version: CurrentVersion,
 	}
 
 	validateApply(inputSet, delta, expectedSet, t)
@@ -264,11 +273,81 @@ func TestApplyUpdateModuleManipulateArrayValues(t *testing.T) {
 				},
 			},
 		},
+		Version: CurrentVersion,
+	}
+
+	validateApply(inputSet, delta, expectedSet, t)
+}
+
+func TestApplyUpdateModuleAddAtEndOfArray(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"array": []interface{}{"value-one"},
+			},
+		},
+	}
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"test-module": []UpdateAction{
+					UpdateAction{Operation: "add", Path: "/array/1", Value: "value-two"},
+				},
+			},
+		},
+	}
+	expectedSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"array": []interface{}{"value-one", "value-two"},
+			},
+		},
+		Version: CurrentVersion,
 	}
 
 	validateApply(inputSet, delta, expectedSet, t)
 }
 
+func TestApplyUpdateModuleBadPaths(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"values": map[string]interface{}{
+					"list":   []interface{}{"value-one"},
+					"scalar": "VALUE",
+				},
+			},
+		},
+	}
+	testCases := []struct {
+		action   UpdateAction
+		expected error
+	}{
+		{UpdateAction{Operation: "replace", Path: "/values/list/99", Value: "X"}, ErrNotFound},
+		{UpdateAction{Operation: "remove", Path: "/values/list/99"}, ErrNotFound},
+		{UpdateAction{Operation: "add", Path: "/values/list/99", Value: "X"}, ErrNotFound},
+		{UpdateAction{Operation: "add", Path: "/values/list/-1", Value: "X"}, ErrTypeMismatch},
+		{UpdateAction{Operation: "replace", Path: "/values/list/first", Value: "X"}, ErrTypeMismatch},
+		{UpdateAction{Operation: "add", Path: "/values/scalar/key", Value: "X"}, ErrTypeMismatch},
+		{UpdateAction{Operation: "copy", From: "/values/list/99", Path: "/values/copy"}, jsonpointer.ErrIndexOutOfRange},
+		{UpdateAction{Operation: "test", Path: "/values/scalar/0", Value: "X"}, jsonpointer.ErrNotContainer},
+	}
+
+	for _, testCase := range testCases {
+		delta := Delta{
+			Modules: ModuleDeltas{
+				Update: map[string][]UpdateAction{
+					"test-module": []UpdateAction{testCase.action},
+				},
+			},
+		}
+		_, err := inputSet.Apply(delta)
+		if !errors.Is(err, testCase.expected) {
+			t.Errorf("%+v: Expected error `%v`, got `%v`", testCase.action, testCase.expected, err)
+		}
+	}
+}
+
 func TestApplyUpdateModuleManipulateArrayValuesWithOuterArray(t *testing.T) {
 	inputSet := Set{
 		Modules: map[string]map[string]interface{}{
@@ -332,6 +411,7 @@ func TestApplyUpdateModuleManipulateArrayValuesWithOuterArray(t *testing.T) {
 				},
 			},
 		},
+		Version: CurrentVersion,
 	}
 
 	validateApply(inputSet, delta, expectedSet, t)
@@ -367,6 +447,7 @@ func TestApplyUpdateModuleRemoveField(t *testing.T) {
 				"param03": "VALUE03",
 			},
 		},
+		Version: CurrentVersion,
 	}
 
 	validateApply(inputSet, delta, expectedSet, t)
@@ -404,6 +485,7 @@ func TestApplyUpdateModuleReplaceField(t *testing.T) {
 				"param03": "VALUE03",
 			},
 		},
+		Version: CurrentVersion,
 	}
 
 	validateApply(inputSet, delta, expectedSet, t)
@@ -439,212 +521,880 @@ func TestApplyUpdateModuleNotFound(t *testing.T) {
 	}
 }
 
-func validateDiff(left, right Set, expected Delta, t *testing.T) {
-	validateDelta(left.Diff(right), expected, t)
-}
-
-func validateDelta(actual, expected Delta, t *testing.T) {
-	// NOTE, we are using reflect.DeepEqual here. There are a few gotchas:
-	// 1. it compares typed nils differently. e.g. nil != map[string]interface{}
-
-	if !reflect.DeepEqual(actual.Modules.Add, expected.Modules.Add) {
-		t.Errorf("Expected: `%v`, got `%v`", actual, expected)
-		return
-	}
-	if !orderInvariantEqual(actual.Modules.Remove, expected.Modules.Remove) {
-		t.Errorf("Expected: `%v`, got `%v`", actual, expected)
-		return
-	}
-	if len(actual.Modules.Update) != len(expected.Modules.Update) {
-		t.Errorf("Expected: `%v`, got `%v`", actual, expected)
-		return
-	}
-	for module := range actual.Modules.Update {
-		if _, ok := expected.Modules.Update[module]; !ok {
-			t.Errorf("Expected: `%v`, got `%v`", actual, expected)
-			return
-		}
-		if !orderInvariantEqual(actual.Modules.Update[module], expected.Modules.Update[module]) {
-			t.Errorf("Expected: `%v`, got `%v`", actual, expected)
-			return
-		}
-	}
-}
-
-func TestDiffToEmptySet(t *testing.T) {
-	emptySet := Set{}
-	left := Set{
+func TestApplyUpdateModuleMoveField(t *testing.T) {
+	inputSet := Set{
 		Modules: map[string]map[string]interface{}{
 			"test-module": map[string]interface{}{
-				"version": "TEST_VERSION",
+				"configmap": map[string]interface{}{
+					"OLD_KEY": "Value",
+				},
+				"array": []interface{}{
+					"value-one",
+					"value-two",
+					"value-three",
+				},
 			},
 		},
 	}
-	expected := Delta{
+	delta := Delta{
 		Modules: ModuleDeltas{
-			Add: map[string]map[string]interface{}{
-				"test-module": map[string]interface{}{
-					"version": "TEST_VERSION",
+			Update: map[string][]UpdateAction{
+				"test-module": []UpdateAction{
+					UpdateAction{
+						Operation: "move",
+						From:      "/configmap/OLD_KEY",
+						Path:      "/configmap/NEW_KEY",
+					},
+					UpdateAction{
+						Operation: "move",
+						From:      "/array/0",
+						Path:      "/array/1",
+					},
 				},
 			},
-			Remove: []string{},
-			Update: map[string][]UpdateAction{},
 		},
 	}
-	validateDiff(left, emptySet, expected, t)
-}
 
-func TestDiffFromEmptySet(t *testing.T) {
-	emptySet := Set{}
-	right := Set{
+	expectedSet := Set{
 		Modules: map[string]map[string]interface{}{
 			"test-module": map[string]interface{}{
-				"version": "TEST_VERSION",
+				"configmap": map[string]interface{}{
+					"NEW_KEY": "Value",
+				},
+				"array": []interface{}{
+					"value-two",
+					"value-one",
+					"value-three",
+				},
 			},
 		},
+		Version: CurrentVersion,
 	}
-	expected := Delta{
-		Modules: ModuleDeltas{
-			Add:    map[string]map[string]interface{}{},
-			Remove: []string{"test-module"},
-			Update: map[string][]UpdateAction{},
-		},
-	}
-	validateDiff(emptySet, right, expected, t)
+
+	validateApply(inputSet, delta, expectedSet, t)
 }
 
-func TestDiffAllChange(t *testing.T) {
-	left := Set{
-		Modules: map[string]map[string]interface{}{
-			"only-left": map[string]interface{}{
-				"version": "TEST_VERSION_LEFT",
-			},
-			"in-both": map[string]interface{}{
-				"only-left":  "TEST_VERSION_LEFT",
-				"in-both-01": "LEFT_VALUE",
-				"in-both-02": "SAME_VALUE",
-			},
-		},
-	}
-	right := Set{
+func TestApplyUpdateModuleMoveIntoChild(t *testing.T) {
+	inputSet := Set{
 		Modules: map[string]map[string]interface{}{
-			"only-right": map[string]interface{}{
-				"version": "TEST_VERSION_RIGHT",
-			},
-			"in-both": map[string]interface{}{
-				"only-right": "TEST_VERSION_RIGHT",
-				"in-both-01": "RIGHT_VALUE",
-				"in-both-02": "SAME_VALUE",
+			"test-module": map[string]interface{}{
+				"configmap": map[string]interface{}{
+					"KEY": "Value",
+				},
 			},
 		},
 	}
-	expected := Delta{
+	delta := Delta{
 		Modules: ModuleDeltas{
-			Add: map[string]map[string]interface{}{
-				"only-left": map[string]interface{}{
-					"version": "TEST_VERSION_LEFT",
-				},
-			},
-			Remove: []string{"only-right"},
 			Update: map[string][]UpdateAction{
-				"in-both": []UpdateAction{
-					UpdateAction{Operation: "remove", Path: "/only-right"},
-					UpdateAction{Operation: "replace", Path: "/in-both-01", Value: "LEFT_VALUE"},
-					UpdateAction{Operation: "add", Path: "/only-left", Value: "TEST_VERSION_LEFT"},
+				"test-module": []UpdateAction{
+					UpdateAction{
+						Operation: "move",
+						From:      "/configmap",
+						Path:      "/configmap/child",
+					},
 				},
 			},
 		},
 	}
-	validateDiff(left, right, expected, t)
+
+	_, err := inputSet.Apply(delta)
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected error `%v`, got `%v`", ErrNotSupported, err)
+	}
 }
 
-func TestMargeDeltas(t *testing.T) {
-	deltaA := Delta{
-		Modules: ModuleDeltas{
-			Add: map[string]map[string]interface{}{
-				"module-add-a": map[string]interface{}{
-					"version": "VERSION_LEFT_MODULE_ADD_A",
-				},
-			},
-			Remove: []string{"module-remove-a"},
-			Update: map[string][]UpdateAction{
-				"module-update-a": []UpdateAction{
-					UpdateAction{Operation: "remove", Path: "/module-a/remove"},
-					UpdateAction{Operation: "replace", Path: "/module-a/replace", Value: "MODULE_A_REPLACE"},
-					UpdateAction{Operation: "add", Path: "/module-a/add", Value: "MODULE_A_ADD"},
+func TestApplyUpdateModuleCopyField(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"configmap": map[string]interface{}{
+					"KEY": "Value",
 				},
 			},
 		},
 	}
-	deltaB := Delta{
+	delta := Delta{
 		Modules: ModuleDeltas{
-			Add: map[string]map[string]interface{}{
-				"module-add-b": map[string]interface{}{
-					"version": "VERSION_LEFT_MODULE_ADD_B",
-				},
-			},
-			Remove: []string{"module-add-a"},
 			Update: map[string][]UpdateAction{
-				"module-update-a": []UpdateAction{
-					UpdateAction{Operation: "remove", Path: "/module-b/remove"},
-					UpdateAction{Operation: "replace", Path: "/module-b/replace", Value: "MODULE_B_REPLACE"},
-					UpdateAction{Operation: "add", Path: "/module-b/add", Value: "MODULE_B_ADD"},
+				"test-module": []UpdateAction{
+					UpdateAction{
+						Operation: "copy",
+						From:      "/configmap",
+						Path:      "/configmap-copy",
+					},
+					UpdateAction{
+						Operation: "replace",
+						Path:      "/configmap-copy/KEY",
+						Value:     "Other Value",
+					},
 				},
 			},
 		},
 	}
-	expectedDelta := Delta{
-		Modules: ModuleDeltas{
-			Add: map[string]map[string]interface{}{
-				"module-add-b": map[string]interface{}{
-					"version": "VERSION_LEFT_MODULE_ADD_B",
+
+	expectedSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"configmap": map[string]interface{}{
+					"KEY": "Value",
 				},
-			},
-			Remove: []string{"module-remove-a", "module-add-a"},
-			Update: map[string][]UpdateAction{
-				"module-update-a": []UpdateAction{
-					UpdateAction{Operation: "remove", Path: "/module-a/remove"},
-					UpdateAction{Operation: "replace", Path: "/module-a/replace", Value: "MODULE_A_REPLACE"},
-					UpdateAction{Operation: "add", Path: "/module-a/add", Value: "MODULE_A_ADD"},
-					UpdateAction{Operation: "remove", Path: "/module-b/remove"},
-					UpdateAction{Operation: "replace", Path: "/module-b/replace", Value: "MODULE_B_REPLACE"},
-					UpdateAction{Operation: "add", Path: "/module-b/add", Value: "MODULE_B_ADD"},
+				"configmap-copy": map[string]interface{}{
+					"KEY": "Other Value",
 				},
 			},
 		},
+		Version: CurrentVersion,
 	}
-	mergedDelta, err := MergeDeltas(deltaA, deltaB)
-	if err != nil {
-		t.Errorf("MergeDeltas returned unexpected error: %v", err)
-	}
-	validateDelta(mergedDelta, expectedDelta, t)
+
+	validateApply(inputSet, delta, expectedSet, t)
 }
 
-func TestMargeDeltas_UpdatesUpdatePreviouslyAdded(t *testing.T) {
-	deltaA := Delta{
-		Modules: ModuleDeltas{
-			Add: map[string]map[string]interface{}{
-				"module-add-a": map[string]interface{}{
-					"property01": "VALUE_01",
-					"property02": "VALUE_02",
-				},
+func TestApplyUpdateModuleCopyFromMissing(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"param01": "VALUE01",
 			},
 		},
 	}
-	deltaB := Delta{
+	delta := Delta{
 		Modules: ModuleDeltas{
 			Update: map[string][]UpdateAction{
-				"module-add-a": []UpdateAction{
-					UpdateAction{Operation: "remove", Path: "/property01"},
-					UpdateAction{Operation: "replace", Path: "/property02", Value: "REPLACED_VALUE"},
-					UpdateAction{Operation: "add", Path: "/newProperty", Value: "NEW_VALUE"},
+				"test-module": []UpdateAction{
+					UpdateAction{
+						Operation: "copy",
+						From:      "/missing",
+						Path:      "/param02",
+					},
 				},
 			},
 		},
 	}
-	expectedDelta := Delta{
-		Modules: ModuleDeltas{
-			Add: map[string]map[string]interface{}{
+
+	_, err := inputSet.Apply(delta)
+	if !errors.Is(err, jsonpointer.ErrDoesNotExist) {
+		t.Errorf("Expected error `%v`, got `%v`", jsonpointer.ErrDoesNotExist, err)
+	}
+}
+
+func TestApplyUpdateModuleTest(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION",
+			},
+		},
+	}
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"test-module": []UpdateAction{
+					UpdateAction{
+						Operation: "test",
+						Path:      "/version",
+						Value:     "TEST_VERSION",
+					},
+					UpdateAction{
+						Operation: "replace",
+						Path:      "/version",
+						Value:     "NEW_VERSION",
+					},
+				},
+			},
+		},
+	}
+
+	expectedSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "NEW_VERSION",
+			},
+		},
+		Version: CurrentVersion,
+	}
+
+	validateApply(inputSet, delta, expectedSet, t)
+}
+
+func TestApplyUpdateModuleTestFailed(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION",
+			},
+		},
+	}
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"test-module": []UpdateAction{
+					UpdateAction{
+						Operation: "test",
+						Path:      "/version",
+						Value:     "OTHER_VERSION",
+					},
+					UpdateAction{
+						Operation: "replace",
+						Path:      "/version",
+						Value:     "NEW_VERSION",
+					},
+				},
+			},
+		},
+	}
+
+	_, err := inputSet.Apply(delta)
+	if !errors.Is(err, ErrTestFailed) {
+		t.Errorf("Expected error `%v`, got `%v`", ErrTestFailed, err)
+	}
+}
+
+func TestApplyStrict(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION",
+				"configmap": map[string]interface{}{
+					"KEY": "Value",
+				},
+			},
+			"other-module": map[string]interface{}{
+				"version": "TEST_VERSION",
+			},
+		},
+	}
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"new-module": map[string]interface{}{
+					"version": "NEW_VERSION",
+				},
+			},
+			Remove: []string{"other-module"},
+			Update: map[string][]UpdateAction{
+				"test-module": []UpdateAction{
+					UpdateAction{Operation: "add", Path: "/configmap/NEW_KEY", Value: "New Value"},
+					UpdateAction{Operation: "remove", Path: "/configmap/KEY"},
+				},
+			},
+		},
+	}
+
+	expectedSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION",
+				"configmap": map[string]interface{}{
+					"NEW_KEY": "New Value",
+				},
+			},
+			"new-module": map[string]interface{}{
+				"version": "NEW_VERSION",
+			},
+		},
+		Version: CurrentVersion,
+	}
+
+	generatedSet, err := inputSet.ApplyStrict(delta)
+	if err != nil {
+		t.Errorf("Expected no error, got error: %v", err)
+	} else if !reflect.DeepEqual(generatedSet, expectedSet) {
+		t.Errorf("Expected: `%+v`, got `%+v`", expectedSet, generatedSet)
+	}
+}
+
+func TestApplyStrict_AllConflictsReported(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION",
+				"configmap": map[string]interface{}{
+					"KEY": "Value",
+				},
+			},
+		},
+	}
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"test-module": map[string]interface{}{
+					"version": "NEW_VERSION",
+				},
+			},
+			Remove: []string{"missing-module"},
+			Update: map[string][]UpdateAction{
+				"test-module": []UpdateAction{
+					UpdateAction{Operation: "add", Path: "/version", Value: "OTHER_VERSION"},
+					UpdateAction{Operation: "remove", Path: "/configmap/MISSING_KEY"},
+					UpdateAction{Operation: "replace", Path: "/missing", Value: "Value"},
+				},
+				"other-missing-module": []UpdateAction{
+					UpdateAction{Operation: "add", Path: "/version", Value: "OTHER_VERSION"},
+				},
+			},
+		},
+	}
+
+	expectedConflicts := []Conflict{
+		Conflict{Module: "missing-module", Reason: "module to remove does not exist"},
+		Conflict{Module: "test-module", Reason: "module to add already exists"},
+		Conflict{Module: "other-missing-module", Reason: "module to update does not exist"},
+		Conflict{Module: "test-module", Path: "/version", Reason: "path to add already exists"},
+		Conflict{Module: "test-module", Path: "/configmap/MISSING_KEY", Reason: "path to remove does not exist"},
+	}
+
+	_, err := inputSet.ApplyStrict(delta)
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected error `%v`, got `%v`", ErrConflict, err)
+		return
+	}
+	var conflictErr *ConflictError
+	errors.As(err, &conflictErr)
+
+	// The replace of a missing path is reported with the underlying error as the reason
+	if len(conflictErr.Conflicts) != len(expectedConflicts)+1 {
+		t.Errorf("Expected %d conflicts, got `%+v`", len(expectedConflicts)+1, conflictErr.Conflicts)
+		return
+	}
+	if !reflect.DeepEqual(conflictErr.Conflicts[:len(expectedConflicts)], expectedConflicts) {
+		t.Errorf("Expected: `%+v`, got `%+v`", expectedConflicts, conflictErr.Conflicts)
+	}
+	lastConflict := conflictErr.Conflicts[len(expectedConflicts)]
+	if lastConflict.Module != "test-module" || lastConflict.Path != "/missing" {
+		t.Errorf("Expected conflict on `/missing` in `test-module`, got `%+v`", lastConflict)
+	}
+}
+
+func validateDiff(left, right Set, expected Delta, t *testing.T) {
+	validateDelta(left.Diff(right), expected, t)
+}
+
+func validateDelta(actual, expected Delta, t *testing.T) {
+	// NOTE, we are using reflect.DeepEqual here. There are a few gotchas:
+	// 1. it compares typed nils differently. e.g. nil != map[string]interface{}
+
+	if !reflect.DeepEqual(actual.Modules.Add, expected.Modules.Add) {
+		t.Errorf("Expected: `%v`, got `%v`", actual, expected)
+		return
+	}
+	if !orderInvariantEqual(actual.Modules.Remove, expected.Modules.Remove) {
+		t.Errorf("Expected: `%v`, got `%v`", actual, expected)
+		return
+	}
+	if len(actual.Modules.Update) != len(expected.Modules.Update) {
+		t.Errorf("Expected: `%v`, got `%v`", actual, expected)
+		return
+	}
+	for module := range actual.Modules.Update {
+		if _, ok := expected.Modules.Update[module]; !ok {
+			t.Errorf("Expected: `%v`, got `%v`", actual, expected)
+			return
+		}
+		if !orderInvariantEqual(actual.Modules.Update[module], expected.Modules.Update[module]) {
+			t.Errorf("Expected: `%v`, got `%v`", actual, expected)
+			return
+		}
+	}
+}
+
+func TestDiffToEmptySet(t *testing.T) {
+	emptySet := Set{}
+	left := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION",
+			},
+		},
+	}
+	expected := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"test-module": map[string]interface{}{
+					"version": "TEST_VERSION",
+				},
+			},
+			Remove: []string{},
+			Update: map[string][]UpdateAction{},
+		},
+	}
+	validateDiff(left, emptySet, expected, t)
+}
+
+func TestDiffFromEmptySet(t *testing.T) {
+	emptySet := Set{}
+	right := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION",
+			},
+		},
+	}
+	expected := Delta{
+		Modules: ModuleDeltas{
+			Add:    map[string]map[string]interface{}{},
+			Remove: []string{"test-module"},
+			Update: map[string][]UpdateAction{},
+		},
+	}
+	validateDiff(emptySet, right, expected, t)
+}
+
+func TestDiffAllChange(t *testing.T) {
+	left := Set{
+		Modules: map[string]map[string]interface{}{
+			"only-left": map[string]interface{}{
+				"version": "TEST_VERSION_LEFT",
+			},
+			"in-both": map[string]interface{}{
+				"only-left":  "TEST_VERSION_LEFT",
+				"in-both-01": "LEFT_VALUE",
+				"in-both-02": "SAME_VALUE",
+			},
+		},
+	}
+	right := Set{
+		Modules: map[string]map[string]interface{}{
+			"only-right": map[string]interface{}{
+				"version": "TEST_VERSION_RIGHT",
+			},
+			"in-both": map[string]interface{}{
+				"only-right": "TEST_VERSION_RIGHT",
+				"in-both-01": "RIGHT_VALUE",
+				"in-both-02": "SAME_VALUE",
+			},
+		},
+	}
+	expected := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"only-left": map[string]interface{}{
+					"version": "TEST_VERSION_LEFT",
+				},
+			},
+			Remove: []string{"only-right"},
+			Update: map[string][]UpdateAction{
+				"in-both": []UpdateAction{
+					UpdateAction{Operation: "remove", Path: "/only-right"},
+					UpdateAction{Operation: "replace", Path: "/in-both-01", Value: "LEFT_VALUE"},
+					UpdateAction{Operation: "add", Path: "/only-left", Value: "TEST_VERSION_LEFT"},
+				},
+			},
+		},
+	}
+	validateDiff(left, right, expected, t)
+}
+
+func TestDiffIsOrdered(t *testing.T) {
+	left := Set{
+		Modules: map[string]map[string]interface{}{
+			"in-both": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"d": "LEFT_VALUE",
+					"b": "ADDED",
+					"c": map[string]interface{}{"z": 1.0, "y": 2.0},
+				},
+			},
+		},
+	}
+	right := Set{
+		Modules: map[string]map[string]interface{}{
+			"only-right-c": map[string]interface{}{"helmchart": "humanitec/redis"},
+			"only-right-a": map[string]interface{}{"helmchart": "humanitec/redis"},
+			"only-right-b": map[string]interface{}{"helmchart": "humanitec/redis"},
+			"in-both": map[string]interface{}{
+				"helmchart": "humanitec/base-module-old",
+				"values": map[string]interface{}{
+					"d": "RIGHT_VALUE",
+					"a": "REMOVED",
+					"c": map[string]interface{}{"x": 3.0},
+				},
+			},
+		},
+	}
+	expectedRemove := []string{"only-right-a", "only-right-b", "only-right-c"}
+	expectedUpdates := []UpdateAction{
+		UpdateAction{Operation: "replace", Path: "/helmchart", Value: "humanitec/base-module"},
+		UpdateAction{Operation: "remove", Path: "/values/a"},
+		UpdateAction{Operation: "add", Path: "/values/b", Value: "ADDED"},
+		UpdateAction{Operation: "remove", Path: "/values/c/x"},
+		UpdateAction{Operation: "add", Path: "/values/c/y", Value: 2.0},
+		UpdateAction{Operation: "add", Path: "/values/c/z", Value: 1.0},
+		UpdateAction{Operation: "replace", Path: "/values/d", Value: "LEFT_VALUE"},
+	}
+
+	// Map iteration order is random, so repeat to make an accidentally correct order unlikely.
+	for i := 0; i < 10; i++ {
+		delta := left.Diff(right)
+		if !reflect.DeepEqual(delta.Modules.Remove, expectedRemove) {
+			t.Fatalf("Expected: `%v`, got `%v`", expectedRemove, delta.Modules.Remove)
+		}
+		if !reflect.DeepEqual(delta.Modules.Update["in-both"], expectedUpdates) {
+			t.Fatalf("Expected: `%v`, got `%v`", expectedUpdates, delta.Modules.Update["in-both"])
+		}
+	}
+}
+
+func TestDiffArrays(t *testing.T) {
+	env := func(names ...string) []interface{} {
+		vars := make([]interface{}, len(names))
+		for i, name := range names {
+			vars[i] = map[string]interface{}{"name": name, "value": "VALUE_" + name}
+		}
+		return vars
+	}
+	changedB := env("A", "B", "C")
+	changedB[1].(map[string]interface{})["value"] = "CHANGED"
+
+	testCases := []struct {
+		name     string
+		left     []interface{}
+		right    []interface{}
+		expected []UpdateAction
+	}{
+		{
+			name:  "insert in middle",
+			left:  env("A", "B", "NEW", "C", "D"),
+			right: env("A", "B", "C", "D"),
+			expected: []UpdateAction{
+				UpdateAction{Operation: "add", Path: "/values/env/2", Value: map[string]interface{}{"name": "NEW", "value": "VALUE_NEW"}},
+			},
+		},
+		{
+			name:  "append",
+			left:  env("A", "B", "C", "D"),
+			right: env("A", "B", "C"),
+			expected: []UpdateAction{
+				UpdateAction{Operation: "add", Path: "/values/env/3", Value: map[string]interface{}{"name": "D", "value": "VALUE_D"}},
+			},
+		},
+		{
+			name:  "remove",
+			left:  env("A", "C"),
+			right: env("A", "B", "C"),
+			expected: []UpdateAction{
+				UpdateAction{Operation: "remove", Path: "/values/env/1"},
+			},
+		},
+		{
+			name:  "change element",
+			left:  changedB,
+			right: env("A", "B", "C"),
+			expected: []UpdateAction{
+				UpdateAction{Operation: "replace", Path: "/values/env/1/value", Value: "CHANGED"},
+			},
+		},
+		{
+			name:  "remove and add",
+			left:  []interface{}{"b", "c", "x", "d", "y"},
+			right: []interface{}{"a", "b", "c", "d"},
+			expected: []UpdateAction{
+				UpdateAction{Operation: "remove", Path: "/values/env/0"},
+				UpdateAction{Operation: "add", Path: "/values/env/2", Value: "x"},
+				UpdateAction{Operation: "add", Path: "/values/env/4", Value: "y"},
+			},
+		},
+		{
+			name:     "to empty",
+			left:     []interface{}{},
+			right:    []interface{}{80.0, 443.0},
+			expected: []UpdateAction{UpdateAction{Operation: "remove", Path: "/values/env/0"}, UpdateAction{Operation: "remove", Path: "/values/env/0"}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		left := Set{
+			Modules: map[string]map[string]interface{}{
+				"module-one": map[string]interface{}{
+					"helmchart": "humanitec/base-module",
+					"values":    map[string]interface{}{"env": testCase.left},
+				},
+			},
+			Version: CurrentVersion,
+		}
+		right := Set{
+			Modules: map[string]map[string]interface{}{
+				"module-one": map[string]interface{}{
+					"helmchart": "humanitec/base-module",
+					"values":    map[string]interface{}{"env": testCase.right},
+				},
+			},
+			Version: CurrentVersion,
+		}
+		actual := left.Diff(right).Modules.Update["module-one"]
+		if !reflect.DeepEqual(actual, testCase.expected) {
+			t.Errorf("%s: Expected: `%v`, got `%v`", testCase.name, testCase.expected, actual)
+		}
+		validateApply(right, left.Diff(right), left, t)
+		validateApply(left, right.Diff(left), right, t)
+	}
+}
+
+func TestDiffEscapesKeys(t *testing.T) {
+	left := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"a/b": "NEW",
+					"m~n": map[string]interface{}{"~1": true},
+				},
+			},
+		},
+		Version: CurrentVersion,
+	}
+	right := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"a/b": "OLD",
+					"a":   map[string]interface{}{"b": "UNCHANGED"},
+				},
+			},
+		},
+		Version: CurrentVersion,
+	}
+	expected := []UpdateAction{
+		UpdateAction{Operation: "replace", Path: "/values/a~1b", Value: "NEW"},
+		UpdateAction{Operation: "remove", Path: "/values/a"},
+		UpdateAction{Operation: "add", Path: "/values/m~0n", Value: map[string]interface{}{"~1": true}},
+	}
+
+	delta := left.Diff(right)
+	if !orderInvariantEqual(delta.Modules.Update["module-one"], expected) {
+		t.Errorf("Expected: `%v`, got `%v`", expected, delta.Modules.Update["module-one"])
+	}
+	validateApply(right, delta, left, t)
+}
+
+func TestDiffNestedObjects(t *testing.T) {
+	left := Set{
+		Modules: map[string]map[string]interface{}{
+			"in-both": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image": map[string]interface{}{
+						"repository": "registry.humanitec.io/my-org/module-one",
+						"tag":        "1.0.1",
+					},
+					"only-left": "LEFT_VALUE",
+					"changes-type": map[string]interface{}{
+						"was": "a string",
+					},
+				},
+			},
+		},
+		Version: CurrentVersion,
+	}
+	right := Set{
+		Modules: map[string]map[string]interface{}{
+			"in-both": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image": map[string]interface{}{
+						"repository": "registry.humanitec.io/my-org/module-one",
+						"tag":        "1.0.0",
+					},
+					"only-right":   "RIGHT_VALUE",
+					"changes-type": "a string",
+				},
+			},
+		},
+	}
+	expected := Delta{
+		Modules: ModuleDeltas{
+			Add:    map[string]map[string]interface{}{},
+			Remove: []string{},
+			Update: map[string][]UpdateAction{
+				"in-both": []UpdateAction{
+					UpdateAction{Operation: "replace", Path: "/values/image/tag", Value: "1.0.1"},
+					UpdateAction{Operation: "remove", Path: "/values/only-right"},
+					UpdateAction{Operation: "add", Path: "/values/only-left", Value: "LEFT_VALUE"},
+					UpdateAction{Operation: "replace", Path: "/values/changes-type", Value: map[string]interface{}{"was": "a string"}},
+				},
+			},
+		},
+	}
+	validateDiff(left, right, expected, t)
+	validateApply(right, left.Diff(right), left, t)
+}
+
+func TestInvert(t *testing.T) {
+	base := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-removed": map[string]interface{}{
+				"helmchart": "humanitec/redis",
+			},
+			"module-updated": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image":   "registry.humanitec.io/my-org/module-one:1.0.0",
+					"removed": "REMOVED_VALUE",
+					"array":   []interface{}{"value-one", "value-two"},
+				},
+			},
+		},
+	}
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-added": map[string]interface{}{
+					"helmchart": "humanitec/postgres",
+				},
+			},
+			Remove: []string{"module-removed"},
+			Update: map[string][]UpdateAction{
+				"module-updated": []UpdateAction{
+					UpdateAction{Operation: "replace", Path: "/values/image", Value: "registry.humanitec.io/my-org/module-one:1.0.1"},
+					UpdateAction{Operation: "remove", Path: "/values/removed"},
+					UpdateAction{Operation: "add", Path: "/values/array/-", Value: "value-three"},
+				},
+			},
+		},
+	}
+	expectedInverse := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-removed": map[string]interface{}{
+					"helmchart": "humanitec/redis",
+				},
+			},
+			Remove: []string{"module-added"},
+			Update: map[string][]UpdateAction{
+				"module-updated": []UpdateAction{
+					UpdateAction{Operation: "replace", Path: "/values/image", Value: "registry.humanitec.io/my-org/module-one:1.0.0"},
+					UpdateAction{Operation: "add", Path: "/values/removed", Value: "REMOVED_VALUE"},
+					UpdateAction{Operation: "remove", Path: "/values/array/2"},
+				},
+			},
+		},
+	}
+
+	inverse, err := delta.Invert(base)
+	if err != nil {
+		t.Errorf("Invert returned unexpected error: %v", err)
+		return
+	}
+	validateDelta(inverse, expectedInverse, t)
+
+	result, err := base.Apply(delta)
+	if err != nil {
+		t.Errorf("Apply returned unexpected error: %v", err)
+		return
+	}
+	// base is a version 0 set, so it is restored at CurrentVersion
+	validateApply(result, inverse, Set{Modules: base.Modules, Version: CurrentVersion}, t)
+
+	restored, _ := result.Apply(inverse)
+	validateHash(restored, base.Hash(), t)
+}
+
+func TestInvert_NotCompatible(t *testing.T) {
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"missing-module": []UpdateAction{
+					UpdateAction{Operation: "add", Path: "/version", Value: "NEW_VERSION"},
+				},
+			},
+		},
+	}
+
+	_, err := delta.Invert(Set{})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected error `%v`, got `%v`", ErrNotFound, err)
+	}
+}
+
+func TestMargeDeltas(t *testing.T) {
+	deltaA := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-add-a": map[string]interface{}{
+					"version": "VERSION_LEFT_MODULE_ADD_A",
+				},
+			},
+			Remove: []string{"module-remove-a"},
+			Update: map[string][]UpdateAction{
+				"module-update-a": []UpdateAction{
+					UpdateAction{Operation: "remove", Path: "/module-a/remove"},
+					UpdateAction{Operation: "replace", Path: "/module-a/replace", Value: "MODULE_A_REPLACE"},
+					UpdateAction{Operation: "add", Path: "/module-a/add", Value: "MODULE_A_ADD"},
+				},
+			},
+		},
+	}
+	deltaB := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-add-b": map[string]interface{}{
+					"version": "VERSION_LEFT_MODULE_ADD_B",
+				},
+			},
+			Remove: []string{"module-add-a"},
+			Update: map[string][]UpdateAction{
+				"module-update-a": []UpdateAction{
+					UpdateAction{Operation: "remove", Path: "/module-b/remove"},
+					UpdateAction{Operation: "replace", Path: "/module-b/replace", Value: "MODULE_B_REPLACE"},
+					UpdateAction{Operation: "add", Path: "/module-b/add", Value: "MODULE_B_ADD"},
+				},
+			},
+		},
+	}
+	expectedDelta := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-add-b": map[string]interface{}{
+					"version": "VERSION_LEFT_MODULE_ADD_B",
+				},
+			},
+			Remove: []string{"module-remove-a", "module-add-a"},
+			Update: map[string][]UpdateAction{
+				"module-update-a": []UpdateAction{
+					UpdateAction{Operation: "remove", Path: "/module-a/remove"},
+					UpdateAction{Operation: "replace", Path: "/module-a/replace", Value: "MODULE_A_REPLACE"},
+					UpdateAction{Operation: "add", Path: "/module-a/add", Value: "MODULE_A_ADD"},
+					UpdateAction{Operation: "remove", Path: "/module-b/remove"},
+					UpdateAction{Operation: "replace", Path: "/module-b/replace", Value: "MODULE_B_REPLACE"},
+					UpdateAction{Operation: "add", Path: "/module-b/add", Value: "MODULE_B_ADD"},
+				},
+			},
+		},
+	}
+	mergedDelta, err := MergeDeltas(deltaA, deltaB)
+	if err != nil {
+		t.Errorf("MergeDeltas returned unexpected error: %v", err)
+	}
+	validateDelta(mergedDelta, expectedDelta, t)
+}
+
+func TestMargeDeltas_UpdatesUpdatePreviouslyAdded(t *testing.T) {
+	deltaA := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-add-a": map[string]interface{}{
+					"property01": "VALUE_01",
+					"property02": "VALUE_02",
+				},
+			},
+		},
+	}
+	deltaB := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"module-add-a": []UpdateAction{
+					UpdateAction{Operation: "remove", Path: "/property01"},
+					UpdateAction{Operation: "replace", Path: "/property02", Value: "REPLACED_VALUE"},
+					UpdateAction{Operation: "add", Path: "/newProperty", Value: "NEW_VALUE"},
+				},
+			},
+		},
+	}
+	expectedDelta := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
 				"module-add-a": map[string]interface{}{
 					"property02":  "REPLACED_VALUE",
 					"newProperty": "NEW_VALUE",
@@ -658,6 +1408,46 @@ func TestMargeDeltas_UpdatesUpdatePreviouslyAdded(t *testing.T) {
 	}
 	validateDelta(mergedDelta, expectedDelta, t)
 }
+func TestMargeDeltas_MoveCopyTestUpdatePreviouslyAdded(t *testing.T) {
+	deltaA := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-add-a": map[string]interface{}{
+					"property01": "VALUE_01",
+					"property02": "VALUE_02",
+				},
+			},
+		},
+	}
+	deltaB := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"module-add-a": []UpdateAction{
+					UpdateAction{Operation: "test", Path: "/property01", Value: "VALUE_01"},
+					UpdateAction{Operation: "move", From: "/property01", Path: "/movedProperty"},
+					UpdateAction{Operation: "copy", From: "/property02", Path: "/copiedProperty"},
+				},
+			},
+		},
+	}
+	expectedDelta := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-add-a": map[string]interface{}{
+					"movedProperty":  "VALUE_01",
+					"property02":     "VALUE_02",
+					"copiedProperty": "VALUE_02",
+				},
+			},
+		},
+	}
+	mergedDelta, err := MergeDeltas(deltaA, deltaB)
+	if err != nil {
+		t.Errorf("MergeDeltas returned unexpected error: %v", err)
+	}
+	validateDelta(mergedDelta, expectedDelta, t)
+}
+
 func TestMargeDeltas_UpdatesAdded(t *testing.T) {
 	deltaA := Delta{
 		Modules: ModuleDeltas{
@@ -784,37 +1574,221 @@ func TestMargeDeltas_RemoveRemove(t *testing.T) {
 	validateDelta(mergedDelta, expectedDelta, t)
 }
 
-func validateHash(inputSet Set, expected string, t *testing.T) {
-	actual := inputSet.Hash()
-	if expected != actual {
-		t.Errorf("Expected %s, got %s", expected, actual)
+// scribble updates every map and array reachable from value. It is used to check that results share nothing with
+// the inputs they were generated from.
+func scribble(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key := range v {
+			scribble(v[key])
+		}
+		v["scribbled"] = true
+	case map[string]map[string]interface{}:
+		for key := range v {
+			scribble(v[key])
+		}
+	case []interface{}:
+		for i := range v {
+			scribble(v[i])
+			if _, ok := v[i].(map[string]interface{}); !ok {
+				if _, ok := v[i].([]interface{}); !ok {
+					v[i] = "scribbled"
+				}
+			}
+		}
+	case Set:
+		scribble(v.Modules)
+	case Delta:
+		scribble(v.Modules.Add)
+		for i := range v.Modules.Remove {
+			v.Modules.Remove[i] = "scribbled"
+		}
+		for _, actions := range v.Modules.Update {
+			for i := range actions {
+				scribble(actions[i].Value)
+				actions[i].Path = "/scribbled"
+			}
+		}
 	}
 }
 
-func TestHashEmptgySetNil(t *testing.T) {
-	inputSet := Set{}
-	expectedHash := "0000000000000000000000000000000000000000000"
+// validateUnchanged checks that the JSON representation of each input is the same before and after operation is run
+// and its result is scribbled over. name identifies the operation in failures.
+func validateUnchanged(name string, operation func() (interface{}, error), t *testing.T, inputs ...interface{}) {
+	before := make([][]byte, len(inputs))
+	for i := range inputs {
+		var err error
+		before[i], err = json.Marshal(inputs[i])
+		if err != nil {
+			t.Fatalf("%s: unable to marshal input %d: %v", name, i, err)
+		}
+	}
+
+	result, err := operation()
+	if err != nil {
+		t.Errorf("%s: expected no error, got error: %v", name, err)
+		return
+	}
+	scribble(result)
 
-	validateHash(inputSet, expectedHash, t)
+	for i := range inputs {
+		after, err := json.Marshal(inputs[i])
+		if err != nil {
+			t.Fatalf("%s: unable to marshal input %d: %v", name, i, err)
+		}
+		if !bytes.Equal(before[i], after) {
+			t.Errorf("%s: input %d was changed.\nBefore: %s\nAfter:  %s", name, i, before[i], after)
+		}
+	}
 }
 
-// This test is mainly to ensure hashes do not change unexpectadly
-func TestHashGeneralCase(t *testing.T) {
-	inputSet := Set{
+func TestOperations_InputsUnchanged(t *testing.T) {
+	base := Set{
 		Modules: map[string]map[string]interface{}{
-			"first-module": map[string]interface{}{
-				"StringParam": "Some string!",
-				"IntParam":    123,
-				"FloatParam":  125.5,
-				"BoolParam":   true,
+			"module-one": map[string]interface{}{
+				"version": "1.0.0",
+				"config": map[string]interface{}{
+					"replicas": 1.0,
+					"ports":    []interface{}{80.0, 443.0},
+					"labels": map[string]interface{}{
+						"tier": "frontend",
+					},
+				},
 			},
-			"another-one": map[string]interface{}{
-				"version": "TEST_VERSION",
-				"param":   "TEST_param",
+			"module-two": map[string]interface{}{
+				"version": "2.0.0",
+				"env": []interface{}{
+					map[string]interface{}{"name": "DEBUG", "value": "false"},
+				},
+			},
+		},
+	}
+	// ours is base with delta applied
+	ours := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"version": "1.0.0",
+				"config": map[string]interface{}{
+					"replicas": 3.0,
+					"ports":    []interface{}{8443.0, 80.0, 443.0},
+					"labels": map[string]interface{}{
+						"team": map[string]interface{}{"name": "platform"},
+					},
+				},
+				"labels": map[string]interface{}{
+					"team": map[string]interface{}{"name": "platform"},
+				},
+			},
+			"module-three": map[string]interface{}{
+				"config": map[string]interface{}{
+					"ports": []interface{}{8081.0, 8080.0},
+					"extra": []interface{}{"a", "b"},
+				},
+			},
+		},
+	}
+	theirs := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"version": "1.1.0",
+				"config": map[string]interface{}{
+					"replicas": 1.0,
+					"ports":    []interface{}{80.0, 443.0},
+					"labels": map[string]interface{}{
+						"tier": "frontend",
+					},
+				},
+			},
+			"module-two": map[string]interface{}{
+				"version": "2.0.0",
+				"env": []interface{}{
+					map[string]interface{}{"name": "DEBUG", "value": "false"},
+				},
+			},
+		},
+	}
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-three": map[string]interface{}{
+					"config": map[string]interface{}{
+						"ports": []interface{}{8080.0},
+					},
+				},
+			},
+			Remove: []string{"module-two"},
+			Update: map[string][]UpdateAction{
+				"module-one": []UpdateAction{
+					{Operation: "replace", Path: "/config/replicas", Value: 3.0},
+					{Operation: "add", Path: "/config/ports/0", Value: 8443.0},
+					{Operation: "add", Path: "/config/labels/team", Value: map[string]interface{}{"name": "platform"}},
+					{Operation: "remove", Path: "/config/labels/tier"},
+					{Operation: "copy", From: "/config/labels", Path: "/labels"},
+				},
+				"module-three": []UpdateAction{
+					{Operation: "add", Path: "/config/ports/0", Value: 8081.0},
+					{Operation: "add", Path: "/config/extra", Value: []interface{}{"a", "b"}},
+				},
+			},
+		},
+	}
+	deltaA := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-four": map[string]interface{}{
+					"nested": map[string]interface{}{"value": 1.0},
+				},
+			},
+			Update: map[string][]UpdateAction{
+				"module-one": []UpdateAction{
+					{Operation: "replace", Path: "/config/replicas", Value: 5.0},
+					{Operation: "add", Path: "/config/labels/team/size", Value: 4.0},
+				},
+				"module-three": []UpdateAction{
+					{Operation: "replace", Path: "/config/ports", Value: []interface{}{9090.0}},
+				},
+			},
+		},
+	}
+	deltaB := Delta{
+		Modules: ModuleDeltas{
+			Remove: []string{"module-one"},
+			Update: map[string][]UpdateAction{
+				"module-four": []UpdateAction{
+					{Operation: "add", Path: "/nested/other", Value: map[string]interface{}{"deep": true}},
+				},
 			},
 		},
 	}
-	expectedHash := "uf6OiM_uMN_xhOO9iYVCGULbLlQjPqc2y6wHyfy6eBQ"
 
-	validateHash(inputSet, expectedHash, t)
+	tests := []struct {
+		name      string
+		operation func() (interface{}, error)
+		inputs    []interface{}
+	}{
+		{"Apply", func() (interface{}, error) { return base.Apply(delta) }, []interface{}{base, delta}},
+		{"ApplyStrict", func() (interface{}, error) { return base.ApplyStrict(delta) }, []interface{}{base, delta}},
+		{"Diff", func() (interface{}, error) { return base.Diff(ours), nil }, []interface{}{base, ours}},
+		{"Diff reversed", func() (interface{}, error) { return ours.Diff(base), nil }, []interface{}{base, ours}},
+		{"Invert", func() (interface{}, error) { return delta.Invert(base) }, []interface{}{base, delta}},
+		{"MergeDeltas", func() (interface{}, error) { return MergeDeltas(delta, deltaA, deltaB) }, []interface{}{delta, deltaA, deltaB}},
+		{"Merge", func() (interface{}, error) { return Merge(base, ours, theirs) }, []interface{}{base, ours, theirs}},
+		{"Rebase", func() (interface{}, error) { return Rebase(base, theirs, delta) }, []interface{}{base, theirs, delta}},
+		{"Render", func() (interface{}, error) { return delta.Render(base, false) }, []interface{}{base, delta}},
+		{
+			"FindAll",
+			func() (interface{}, error) {
+				found, err := base.FindAll("/**")
+				values := make([]interface{}, len(found))
+				for i := range found {
+					values[i] = found[i].Value
+				}
+				return values, err
+			},
+			[]interface{}{base},
+		},
+	}
+	for _, test := range tests {
+		validateUnchanged(test.name, test.operation, t, test.inputs...)
+	}
 }
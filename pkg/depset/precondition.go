@@ -0,0 +1,81 @@
+package depset
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"humanitec.io/deploymentset-svc/pkg/jsonpointer"
+)
+
+// CheckPreconditions returns a *ConflictError listing every precondition of the Delta that does not hold for the Set,
+// or nil if they all hold. In the conflicts, Ours is the value the precondition expects and Theirs is the value in the
+// Set. (A value that does not exist is omitted.)
+//
+// A Set ID precondition matches either the Set as it is or the Set upgraded to CurrentVersion, so IDs of sets stored
+// at an older version can still be used.
+func (inputSet Set) CheckPreconditions(delta Delta) error {
+	if delta.Preconditions == nil {
+		return nil
+	}
+
+	var conflicts []Conflict
+	if id := delta.Preconditions.SetID; id != "" && !inputSet.hasID(id) {
+		conflicts = append(conflicts, Conflict{Reason: fmt.Sprintf("set ID is not `%s`", id)})
+	}
+
+	for _, precondition := range delta.Preconditions.Values {
+		actual, exists, err := inputSet.lookup(precondition.Module, precondition.Path)
+		if err != nil {
+			return fmt.Errorf("module %s: precondition: %w", precondition.Module, err)
+		}
+		conflict := Conflict{Module: precondition.Module, Path: precondition.Path}
+		switch {
+		case precondition.Absent && exists:
+			conflict.Reason = "value exists"
+			conflict.Theirs = copyValue(actual)
+		case precondition.Absent:
+			continue
+		case !exists:
+			conflict.Reason = "value does not exist"
+			conflict.Ours = copyValue(precondition.Value)
+		case !reflect.DeepEqual(precondition.Value, actual):
+			conflict.Reason = "value does not match precondition"
+			conflict.Ours = copyValue(precondition.Value)
+			conflict.Theirs = copyValue(actual)
+		default:
+			continue
+		}
+		conflicts = append(conflicts, conflict)
+	}
+
+	if len(conflicts) > 0 {
+		return &ConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// hasID returns true if id is the ID of the Set or of the Set upgraded to CurrentVersion.
+func (inputSet Set) hasID(id string) bool {
+	if inputSet.VerifyID(id) == nil {
+		return true
+	}
+	upgraded, err := Upgrade(inputSet)
+	return err == nil && upgraded.VerifyID(id) == nil
+}
+
+// lookup returns the value at path in the named module and whether it exists. An error is only returned if path is
+// not a valid json-pointer.
+func (inputSet Set) lookup(module, path string) (interface{}, bool, error) {
+	spec, ok := inputSet.Modules[module]
+	if !ok {
+		return nil, false, nil
+	}
+	value, err := jsonpointer.Extract(spec, path)
+	if errors.Is(err, jsonpointer.ErrInvalidPointer) {
+		return nil, false, &pointerError{path: path, err: err}
+	} else if err != nil {
+		return nil, false, nil
+	}
+	return value, true, nil
+}
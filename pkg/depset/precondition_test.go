@@ -0,0 +1,285 @@
+package depset
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	set := Set{
+		Modules: map[string]map[string]interface{}{
+			"api": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image": map[string]interface{}{
+						"tag": "1.2.3",
+					},
+				},
+			},
+		},
+		Version: CurrentVersion,
+	}
+	tests := []struct {
+		name          string
+		preconditions *Preconditions
+		conflicts     []Conflict
+	}{
+		{"none", nil, nil},
+		{"set ID matches", &Preconditions{SetID: set.Hash()}, nil},
+		{
+			"set ID does not match",
+			&Preconditions{SetID: "jcs-sha256.AAAA"},
+			[]Conflict{{Reason: "set ID is not `jcs-sha256.AAAA`"}},
+		},
+		{
+			"value matches",
+			&Preconditions{Values: []ValuePrecondition{{Module: "api", Path: "/values/image/tag", Value: "1.2.3"}}},
+			nil,
+		},
+		{
+			"value does not match",
+			&Preconditions{Values: []ValuePrecondition{{Module: "api", Path: "/values/image/tag", Value: "1.2.2"}}},
+			[]Conflict{{Module: "api", Path: "/values/image/tag", Reason: "value does not match precondition", Ours: "1.2.2", Theirs: "1.2.3"}},
+		},
+		{
+			"value does not exist",
+			&Preconditions{Values: []ValuePrecondition{{Module: "api", Path: "/values/replicas", Value: 1.0}}},
+			[]Conflict{{Module: "api", Path: "/values/replicas", Reason: "value does not exist", Ours: 1.0}},
+		},
+		{
+			"absent value",
+			&Preconditions{Values: []ValuePrecondition{{Module: "api", Path: "/values/replicas", Absent: true}}},
+			nil,
+		},
+		{
+			"absent value exists",
+			&Preconditions{Values: []ValuePrecondition{{Module: "api", Path: "/values/image/tag", Absent: true}}},
+			[]Conflict{{Module: "api", Path: "/values/image/tag", Reason: "value exists", Theirs: "1.2.3"}},
+		},
+		{
+			"absent module",
+			&Preconditions{Values: []ValuePrecondition{{Module: "database", Path: "", Absent: true}}},
+			nil,
+		},
+		{
+			"every failure is reported",
+			&Preconditions{
+				SetID: "jcs-sha256.AAAA",
+				Values: []ValuePrecondition{
+					{Module: "database", Path: "/helmchart", Value: "humanitec/postgres"},
+					{Module: "api", Path: "/values/image/tag", Value: "1.2.3"},
+					{Module: "api", Path: "", Absent: true},
+				},
+			},
+			[]Conflict{
+				{Reason: "set ID is not `jcs-sha256.AAAA`"},
+				{Module: "database", Path: "/helmchart", Reason: "value does not exist", Ours: "humanitec/postgres"},
+				{Module: "api", Path: "", Reason: "value exists", Theirs: set.Modules["api"]},
+			},
+		},
+	}
+	for _, test := range tests {
+		delta := Delta{
+			Modules: ModuleDeltas{
+				Update: map[string][]UpdateAction{
+					"api": {{Operation: "replace", Path: "/values/image/tag", Value: "1.2.4"}},
+				},
+			},
+			Preconditions: test.preconditions,
+		}
+		err := set.CheckPreconditions(delta)
+		if test.conflicts == nil {
+			if err != nil {
+				t.Errorf("%s: expected no error, got error: %v", test.name, err)
+			}
+			continue
+		}
+		var conflictErr *ConflictError
+		if !errors.As(err, &conflictErr) {
+			t.Errorf("%s: expected ConflictError, got: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(conflictErr.Conflicts, test.conflicts) {
+			t.Errorf("%s: expected conflicts %v, got %v", test.name, test.conflicts, conflictErr.Conflicts)
+		}
+	}
+}
+
+func TestCheckPreconditions_LegacySetID(t *testing.T) {
+	set := Set{
+		Modules: map[string]map[string]interface{}{
+			"api": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image": map[string]interface{}{
+						"tag": "1.2.3",
+					},
+				},
+			},
+		},
+	}
+	upgraded, err := Upgrade(set)
+	if err != nil {
+		t.Fatalf("Expected no error, got error: %v", err)
+	}
+	for _, id := range []string{set.Hash(), upgraded.Hash()} {
+		if err := set.CheckPreconditions(Delta{Preconditions: &Preconditions{SetID: id}}); err != nil {
+			t.Errorf("Expected ID `%s` to match, got error: %v", id, err)
+		}
+	}
+}
+
+func TestApplyChecksPreconditions(t *testing.T) {
+	set := Set{
+		Modules: map[string]map[string]interface{}{
+			"api": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image": map[string]interface{}{
+						"tag": "1.2.3",
+					},
+				},
+			},
+		},
+		Version: CurrentVersion,
+	}
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"api": {{Operation: "replace", Path: "/values/image/tag", Value: "1.2.4"}},
+			},
+		},
+		Preconditions: &Preconditions{
+			Values: []ValuePrecondition{{Module: "api", Path: "/values/image/tag", Value: "1.2.2"}},
+		},
+	}
+
+	for name, apply := range map[string]func(Delta) (Set, error){"Apply": set.Apply, "ApplyStrict": set.ApplyStrict} {
+		_, err := apply(delta)
+		if !errors.Is(err, ErrConflict) {
+			t.Errorf("%s: expected conflict, got: %v", name, err)
+		}
+	}
+
+	delta.Preconditions.Values[0].Value = "1.2.3"
+	result, err := set.Apply(delta)
+	if err != nil {
+		t.Fatalf("Expected no error, got error: %v", err)
+	}
+	if tag := result.Modules["api"]["values"].(map[string]interface{})["image"].(map[string]interface{})["tag"]; tag != "1.2.4" {
+		t.Errorf("Expected tag to be updated, got %v", tag)
+	}
+}
+
+func TestValidatePreconditions(t *testing.T) {
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"api": {{Operation: "replace", Path: "/values/image/tag", Value: "1.2.4"}},
+			},
+		},
+		Preconditions: &Preconditions{
+			Values: []ValuePrecondition{
+				{Path: "/values"},
+				{Module: "api", Path: "values"},
+			},
+		},
+	}
+	validateValidationErrors(delta.Validate(), ValidationErrors{
+		{Message: "precondition 0: module must not be empty"},
+		{Module: "api", Message: "precondition 1: path `values` is not a valid json-pointer"},
+	}, t)
+}
+
+func TestMergeDeltasPreconditions(t *testing.T) {
+	first := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"api": {{Operation: "replace", Path: "/values/image/tag", Value: "1.2.4"}},
+			},
+		},
+		Preconditions: &Preconditions{
+			SetID:  "jcs-sha256.AAAA",
+			Values: []ValuePrecondition{{Module: "api", Path: "/values/image/tag", Value: "1.2.3"}},
+		},
+	}
+	second := Delta{Preconditions: &Preconditions{
+		Values: []ValuePrecondition{{Module: "database", Absent: true}},
+	}}
+
+	merged, err := MergeDeltas(first, second)
+	if err != nil {
+		t.Fatalf("Expected no error, got error: %v", err)
+	}
+	expected := &Preconditions{
+		SetID: "jcs-sha256.AAAA",
+		Values: []ValuePrecondition{
+			{Module: "api", Path: "/values/image/tag", Value: "1.2.3"},
+			{Module: "database", Absent: true},
+		},
+	}
+	if !reflect.DeepEqual(merged.Preconditions, expected) {
+		t.Errorf("Expected %v, got %v", expected, merged.Preconditions)
+	}
+
+	_, err = MergeDeltas(first, Delta{Preconditions: &Preconditions{SetID: "jcs-sha256.BBBB"}})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected conflict for different set IDs, got: %v", err)
+	}
+}
+
+func TestRebaseKeepsPreconditions(t *testing.T) {
+	oldBase := Set{
+		Modules: map[string]map[string]interface{}{
+			"api": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image": map[string]interface{}{
+						"tag": "1.2.3",
+					},
+				},
+			},
+		},
+		Version: CurrentVersion,
+	}
+	newBase := Set{
+		Modules: map[string]map[string]interface{}{
+			"api": map[string]interface{}{
+				"helmchart": "humanitec/other-module",
+				"values": map[string]interface{}{
+					"image": map[string]interface{}{
+						"tag": "1.2.3",
+					},
+				},
+			},
+		},
+		Version: CurrentVersion,
+	}
+
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"api": {{Operation: "replace", Path: "/values/image/tag", Value: "1.2.4"}},
+			},
+		},
+		Preconditions: &Preconditions{
+			SetID:  oldBase.Hash(),
+			Values: []ValuePrecondition{{Module: "api", Path: "/values/image/tag", Value: "1.2.3"}},
+		},
+	}
+	rebased, err := Rebase(oldBase, newBase, delta)
+	if err != nil {
+		t.Fatalf("Expected no error, got error: %v", err)
+	}
+	expected := &Preconditions{
+		SetID:  newBase.Hash(),
+		Values: []ValuePrecondition{{Module: "api", Path: "/values/image/tag", Value: "1.2.3"}},
+	}
+	if !reflect.DeepEqual(rebased.Preconditions, expected) {
+		t.Errorf("Expected %v, got %v", expected, rebased.Preconditions)
+	}
+	if _, err := newBase.Apply(rebased); err != nil {
+		t.Errorf("Expected rebased delta to apply to new base, got error: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package depset
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"humanitec.io/deploymentset-svc/pkg/jsonpointer"
+)
+
+// ANSI escape sequences used when rendering with color.
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// devNullPath is used in place of a module name in the header of added and removed modules.
+const devNullPath = "/dev/null"
+
+// valueChange describes a value that differs between the old and new version of a module spec. Old or New is only
+// meaningful if HasOld or HasNew is true respectively.
+type valueChange struct {
+	Path   string
+	Old    interface{}
+	HasOld bool
+	New    interface{}
+	HasNew bool
+}
+
+// Render describes the changes the Delta makes to base as text in the style of a unified diff. Modules are listed in
+// name order, each with a "---"/"+++" header. Added and removed modules are shown in full. For updated modules, each
+// change found by ModuleSpecDiff is shown under a "@@ <json-pointer> @@" header with the old value prefixed by "-" and
+// the new value prefixed by "+", so arrays are compared element by element as in Diff. Values are formatted as
+// indented JSON.
+//
+// If color is true, the text includes ANSI escape sequences to color it in the same way as `git diff`.
+//
+// An error is returned if the Delta cannot be applied to base.
+func (delta Delta) Render(base Set, color bool) (string, error) {
+	base, err := Upgrade(base)
+	if err != nil {
+		return "", err
+	}
+	updated, err := base.Apply(delta)
+	if err != nil {
+		return "", err
+	}
+
+	names := make(map[string]bool)
+	for name := range base.Modules {
+		names[name] = true
+	}
+	for name := range updated.Modules {
+		names[name] = true
+	}
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	r := renderer{color: color}
+	for _, name := range sortedNames {
+		oldSpec, hasOld := base.Modules[name]
+		newSpec, hasNew := updated.Modules[name]
+		switch {
+		case hasOld && hasNew:
+			changes, err := specChanges(oldSpec, newSpec)
+			if err != nil {
+				return "", err
+			}
+			if len(changes) == 0 {
+				continue
+			}
+			r.header("a/"+name, "b/"+name)
+			for _, change := range changes {
+				r.hunk(change.Path)
+				if change.HasOld {
+					r.value("-", colorRed, change.Old)
+				}
+				if change.HasNew {
+					r.value("+", colorGreen, change.New)
+				}
+			}
+		case hasNew:
+			r.header(devNullPath, "b/"+name)
+			r.value("+", colorGreen, newSpec)
+		default:
+			r.header("a/"+name, devNullPath)
+			r.value("-", colorRed, oldSpec)
+		}
+	}
+	return r.buf.String(), nil
+}
+
+// specChanges describes the changes between the old and new version of a module spec as found by ModuleSpecDiff. The
+// actions are applied to a copy of old in turn so that the value each one replaces or removes is known. As in the Delta
+// returned by Diff, each array index refers to the array as updated by the changes before it.
+func specChanges(old, new map[string]interface{}) ([]valueChange, error) {
+	current := copyModuleSpec(old)
+	actions := ModuleSpecDiff(new, old)
+	changes := make([]valueChange, 0, len(actions))
+	for _, action := range actions {
+		change := valueChange{Path: action.Path}
+		// ModuleSpecDiff only adds properties that do not exist yet and elements inserted into arrays, so nothing is
+		// overwritten by an add.
+		if action.Operation != "add" {
+			value, err := jsonpointer.Extract(current, action.Path)
+			if err != nil {
+				return nil, err
+			}
+			change.Old, change.HasOld = value, true
+		}
+		if action.Operation != "remove" {
+			change.New, change.HasNew = action.Value, true
+		}
+		if err := applyUpdateAction(action, current); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// renderer accumulates the lines of a rendered Delta.
+type renderer struct {
+	buf   bytes.Buffer
+	color bool
+}
+
+func (r *renderer) line(color, text string) {
+	if r.color {
+		r.buf.WriteString(color)
+		r.buf.WriteString(text)
+		r.buf.WriteString(colorReset)
+	} else {
+		r.buf.WriteString(text)
+	}
+	r.buf.WriteByte('\n')
+}
+
+func (r *renderer) header(oldName, newName string) {
+	r.line(colorBold, "--- "+oldName)
+	r.line(colorBold, "+++ "+newName)
+}
+
+func (r *renderer) hunk(path string) {
+	r.line(colorCyan, "@@ "+path+" @@")
+}
+
+func (r *renderer) value(prefix, color string, value interface{}) {
+	for _, line := range formatValue(value) {
+		r.line(color, prefix+line)
+	}
+}
+
+// formatValue formats value as indented JSON split into lines. HTML characters are not escaped as the output is
+// intended to be read by people.
+func formatValue(value interface{}) []string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		// Values derrived from JSON can always be encoded.
+		return []string{err.Error()}
+	}
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
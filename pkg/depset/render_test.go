@@ -0,0 +1,175 @@
+package depset
+
+import (
+	"testing"
+)
+
+func TestDeltaRender(t *testing.T) {
+	set := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image":    "registry.humanitec.io/my-org/module-one:1.0.0",
+					"replicas": 1.0,
+					"a/b":      "<unchanged>",
+				},
+			},
+			"module-two": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+			},
+			"redis-cache": map[string]interface{}{
+				"helmchart": "humanitec/redis",
+			},
+		},
+	}
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"postgres": map[string]interface{}{
+					"helmchart": "humanitec/postgres",
+					"values": map[string]interface{}{
+						"databases": []interface{}{"one", "two"},
+					},
+				},
+			},
+			Remove: []string{"redis-cache"},
+			Update: map[string][]UpdateAction{
+				"module-one": []UpdateAction{
+					UpdateAction{Operation: "replace", Path: "/values/image", Value: "registry.humanitec.io/my-org/module-one:1.0.1"},
+					UpdateAction{Operation: "remove", Path: "/values/replicas"},
+					UpdateAction{Operation: "add", Path: "/values/a~1b", Value: "<changed>"},
+				},
+				"module-two": []UpdateAction{
+					UpdateAction{Operation: "test", Path: "/helmchart", Value: "humanitec/base-module"},
+				},
+			},
+		},
+	}
+	expected := `--- a/module-one
++++ b/module-one
+@@ /values/a~1b @@
+-"<unchanged>"
++"<changed>"
+@@ /values/image @@
+-"registry.humanitec.io/my-org/module-one:1.0.0"
++"registry.humanitec.io/my-org/module-one:1.0.1"
+@@ /values/replicas @@
+-1
+--- /dev/null
++++ b/postgres
++{
++  "helmchart": "humanitec/postgres",
++  "values": {
++    "databases": [
++      "one",
++      "two"
++    ]
++  }
++}
+--- a/redis-cache
++++ /dev/null
+-{
+-  "helmchart": "humanitec/redis"
+-}
+`
+
+	text, err := delta.Render(set, false)
+	if err != nil {
+		t.Errorf("Expected no error, got error: %v", err)
+	} else if text != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, text)
+	}
+}
+
+func TestDeltaRender_Color(t *testing.T) {
+	set := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-two": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+			},
+		},
+	}
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"module-two": []UpdateAction{
+					UpdateAction{Operation: "replace", Path: "/helmchart", Value: "humanitec/base-module-v2"},
+				},
+			},
+		},
+	}
+	expected := "\x1b[1m--- a/module-two\x1b[0m\n" +
+		"\x1b[1m+++ b/module-two\x1b[0m\n" +
+		"\x1b[36m@@ /helmchart @@\x1b[0m\n" +
+		"\x1b[31m-\"humanitec/base-module\"\x1b[0m\n" +
+		"\x1b[32m+\"humanitec/base-module-v2\"\x1b[0m\n"
+
+	text, err := delta.Render(set, true)
+	if err != nil {
+		t.Errorf("Expected no error, got error: %v", err)
+	} else if text != expected {
+		t.Errorf("Expected: %q, got %q", expected, text)
+	}
+}
+
+func TestDeltaRender_Arrays(t *testing.T) {
+	base := Set{
+		Modules: map[string]map[string]interface{}{
+			"postgres": map[string]interface{}{
+				"helmchart": "humanitec/postgres",
+				"values": map[string]interface{}{
+					"databases": []interface{}{"one", "two", "three"},
+				},
+			},
+		},
+	}
+	updated := Set{
+		Modules: map[string]map[string]interface{}{
+			"postgres": map[string]interface{}{
+				"helmchart": "humanitec/postgres",
+				"values": map[string]interface{}{
+					"databases": []interface{}{"zero", "one", "three"},
+				},
+			},
+		},
+	}
+	// The same changes as in the Delta returned by Diff, with indices referring to the array as updated so far.
+	expected := `--- a/postgres
++++ b/postgres
+@@ /values/databases/0 @@
++"zero"
+@@ /values/databases/2 @@
+-"two"
+`
+
+	text, err := updated.Diff(base).Render(base, false)
+	if err != nil {
+		t.Errorf("Expected no error, got error: %v", err)
+	} else if text != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, text)
+	}
+}
+
+func TestDeltaRender_NotCompatible(t *testing.T) {
+	set := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-two": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+			},
+		},
+	}
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Update: map[string][]UpdateAction{
+				"missing-module": []UpdateAction{
+					UpdateAction{Operation: "add", Path: "/values", Value: map[string]interface{}{}},
+				},
+			},
+		},
+	}
+
+	if _, err := delta.Render(set, false); err == nil {
+		t.Errorf("Expected error, got none")
+	}
+}
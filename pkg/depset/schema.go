@@ -0,0 +1,65 @@
+package depset
+
+import (
+	"humanitec.io/deploymentset-svc/pkg/jsonpointer"
+	"humanitec.io/deploymentset-svc/pkg/jsonschema"
+)
+
+// Schemas maps Helm chart names (as used in the helmchart property of a module) to the JSON Schema that the values of
+// modules using that chart must conform to.
+type Schemas map[string]*jsonschema.Schema
+
+// ValidateValues checks the values of a module spec against the schema registered for its Helm chart. Modules using a
+// chart with no registered schema or without a helmchart are not checked, neither are specs where helmchart or values
+// have the wrong type as these are reported by ValidateModule. A module without values is checked as if it had an
+// empty values object.
+func (schemas Schemas) ValidateValues(name string, spec map[string]interface{}) ValidationErrors {
+	module, specErrs := parseModule(name, spec)
+	if len(specErrs) > 0 {
+		return nil
+	}
+	schema, ok := schemas[module.HelmChart]
+	if !ok || schema == nil {
+		return nil
+	}
+
+	values := module.Values
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	var errs ValidationErrors
+	for _, err := range schema.Validate(values) {
+		// jsonschema builds the paths of errors from escaped property names and indices, so they are always valid.
+		path, _ := jsonpointer.Parse(err.Path)
+		errs = append(errs, ValidationError{
+			Module:  name,
+			Path:    append(jsonpointer.Pointer{"values"}, path...).String(),
+			Message: err.Message,
+		})
+	}
+	return errs
+}
+
+// ValidateValues checks the values of the named modules in the Set against schemas. Modules that are not in the Set
+// are ignored.
+func (inputSet Set) ValidateValues(schemas Schemas, names ...string) ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range removeDuplicates(names) {
+		if spec, ok := inputSet.Modules[name]; ok {
+			errs = append(errs, schemas.ValidateValues(name, spec)...)
+		}
+	}
+	return errs
+}
+
+// ValidateValues checks the values of the named modules added by the Delta against schemas. Modules that are not
+// added by the Delta are ignored, as are nil adds as they do not add a module.
+func (delta Delta) ValidateValues(schemas Schemas, names ...string) ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range removeDuplicates(names) {
+		if spec := delta.Modules.Add[name]; spec != nil {
+			errs = append(errs, schemas.ValidateValues(name, spec)...)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,77 @@
+package depset
+
+import (
+	"testing"
+
+	"humanitec.io/deploymentset-svc/pkg/jsonschema"
+)
+
+func testSchemas(t *testing.T) Schemas {
+	schema, err := jsonschema.Parse([]byte(`{
+  "type": "object",
+  "required": ["image"],
+  "properties": {
+    "image": {"type": "string"},
+    "replicas": {"type": "integer", "minimum": 1}
+  }
+}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got error: %v", err)
+	}
+	return Schemas{"humanitec/base-module": schema}
+}
+
+func TestSetValidateValues(t *testing.T) {
+	set := Set{
+		Modules: map[string]map[string]interface{}{
+			"module-one": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+				"values": map[string]interface{}{
+					"image":    "registry.humanitec.io/my-org/module-one:1.0.0",
+					"replicas": 0.0,
+				},
+			},
+			"module-two": map[string]interface{}{
+				"helmchart": "humanitec/base-module",
+			},
+			"redis-cache": map[string]interface{}{
+				"helmchart": "humanitec/redis",
+				"values": map[string]interface{}{
+					"anything": true,
+				},
+			},
+			"invalid-module": map[string]interface{}{
+				"helmchart": 5.0,
+			},
+		},
+	}
+	expected := ValidationErrors{
+		{Module: "module-one", Path: "/values/replicas", Message: "must be at least 1"},
+		{Module: "module-two", Path: "/values", Message: "missing required property `image`"},
+	}
+
+	errs := set.ValidateValues(testSchemas(t), "invalid-module", "module-one", "module-two", "redis-cache", "missing")
+	validateValidationErrors(errs, expected, t)
+}
+
+func TestDeltaValidateValues(t *testing.T) {
+	delta := Delta{
+		Modules: ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"module-one": map[string]interface{}{
+					"helmchart": "humanitec/base-module",
+					"values": map[string]interface{}{
+						"image": 1.0,
+					},
+				},
+				"module-two": nil,
+			},
+		},
+	}
+	expected := ValidationErrors{
+		{Module: "module-one", Path: "/values/image", Message: "expected string, got integer"},
+	}
+
+	errs := delta.ValidateValues(testSchemas(t), delta.ChangedModules()...)
+	validateValidationErrors(errs, expected, t)
+}
@@ -1,5 +1,7 @@
 package depset
 
+import "encoding/json"
+
 // Set is the actual Deployment Set
 type Set struct {
 	Modules map[string]map[string]interface{} `json:"modules"`
@@ -12,7 +14,27 @@ type Set struct {
 
 // Delta is the actual Deployment Set
 type Delta struct {
-	Modules ModuleDeltas `json:"modules"`
+	Modules       ModuleDeltas   `json:"modules"`
+	Preconditions *Preconditions `json:"preconditions,omitempty"`
+}
+
+// Preconditions must hold for the Set a Delta is applied to, otherwise the Delta is not applied. They allow clients to
+// detect that a Set was changed by someone else since they read it.
+//
+// SetID, if not empty, must be the ID of the Set. Each of Values must hold for the Set.
+type Preconditions struct {
+	SetID  string              `json:"set_id,omitempty"`
+	Values []ValuePrecondition `json:"values,omitempty"`
+}
+
+// ValuePrecondition requires the value at Path in the named module to equal Value. If Absent is true, the value at
+// Path must not exist instead and Value is ignored. The empty Path refers to the whole module spec, so it can be used
+// to require that a module does or does not exist.
+type ValuePrecondition struct {
+	Module string      `json:"module"`
+	Path   string      `json:"path"`
+	Value  interface{} `json:"value,omitempty"`
+	Absent bool        `json:"absent,omitempty"`
 }
 
 // ModuleDeltas groups the different operations together.
@@ -23,10 +45,69 @@ type ModuleDeltas struct {
 }
 
 // UpdateAction is a representation of the main object defined in JSON Patch specified in RFC 6902 from the IETF.
-// The main difference is that we only support values of type string for now.
-// Operation can be one of "add", "remove", "replace"
+// Operation can be one of "add", "remove", "replace", "move", "copy" or "test".
+// From is only used by "move" and "copy" and holds the json-pointer of the source value.
+// A Value of JSON null is held as a nil Value, so use HasValue to check whether the action has a value at all.
 type UpdateAction struct {
 	Operation string      `json:"op"`
 	Path      string      `json:"path"`
+	From      string      `json:"from,omitempty"`
 	Value     interface{} `json:"value,omitempty"`
+
+	// null is true if Value is nil because the value is JSON null rather than missing.
+	null bool
+}
+
+// updateActionJSON is the JSON encoding of an UpdateAction. Value is kept raw so that a null value can be told apart
+// from a missing one.
+type updateActionJSON struct {
+	Operation string          `json:"op"`
+	Path      string          `json:"path"`
+	From      string          `json:"from,omitempty"`
+	Value     json.RawMessage `json:"value,omitempty"`
+}
+
+// valueAction returns an UpdateAction with a value, which may be nil for JSON null.
+func valueAction(operation, path string, value interface{}) UpdateAction {
+	return UpdateAction{Operation: operation, Path: path, Value: value, null: value == nil}
+}
+
+// HasValue returns true if the action has a value, including a value of JSON null.
+func (action UpdateAction) HasValue() bool {
+	return action.Value != nil || action.null
+}
+
+// setValue copies the value of other, which may be JSON null, to action.
+func (action *UpdateAction) setValue(other UpdateAction) {
+	action.Value = other.Value
+	action.null = other.null
+}
+
+// MarshalJSON includes the value if the action has one, even if it is null.
+func (action UpdateAction) MarshalJSON() ([]byte, error) {
+	encoded := updateActionJSON{Operation: action.Operation, Path: action.Path, From: action.From}
+	if action.HasValue() {
+		value, err := json.Marshal(action.Value)
+		if err != nil {
+			return nil, err
+		}
+		encoded.Value = value
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON records whether the value was present, so that a null value can be told apart from a missing one.
+func (action *UpdateAction) UnmarshalJSON(data []byte) error {
+	var encoded updateActionJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	*action = UpdateAction{Operation: encoded.Operation, Path: encoded.Path, From: encoded.From}
+	if encoded.Value != nil {
+		if err := json.Unmarshal(encoded.Value, &action.Value); err != nil {
+			return err
+		}
+		action.null = action.Value == nil
+	}
+	return nil
 }
@@ -0,0 +1,56 @@
+package depset
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CurrentVersion is the version of the Set schema generated by this package.
+// Version 0 sets are unversioned and have the same shape as version 1 sets, so both have the same ID. From version 2 on,
+// the version is included in the ID of the set.
+const CurrentVersion = 1
+
+// ErrUnsupportedVersion returned when a Set has a version that cannot be upgraded to CurrentVersion.
+var ErrUnsupportedVersion = errors.New("unsupported set version")
+
+// upgradeFunc upgrades a Set from one version to the next. It must not update the Set passed to it.
+type upgradeFunc func(Set) (Set, error)
+
+// upgrades holds the function used to upgrade a Set from each version to the next version.
+var upgrades = map[int]upgradeFunc{
+	0: upgradeFromVersion0,
+}
+
+// upgradeFromVersion0 only updates the version number as version 0 and version 1 sets have the same shape.
+func upgradeFromVersion0(set Set) (Set, error) {
+	return Set{
+		Modules: set.Modules,
+		Version: 1,
+	}, nil
+}
+
+// Upgrade generates a Set at CurrentVersion from a Set at an older version by applying each registered upgrade in
+// turn. Sets already at CurrentVersion are returned unchanged.
+// The ErrUnsupportedVersion sentinal error is returned if the Set is newer than CurrentVersion or there is no upgrade
+// registered for one of the versions.
+func Upgrade(set Set) (Set, error) {
+	if set.Version > CurrentVersion || set.Version < 0 {
+		return Set{}, fmt.Errorf("version %d: %w", set.Version, ErrUnsupportedVersion)
+	}
+	for set.Version < CurrentVersion {
+		upgrade, ok := upgrades[set.Version]
+		if !ok {
+			return Set{}, fmt.Errorf("no upgrade from version %d: %w", set.Version, ErrUnsupportedVersion)
+		}
+		fromVersion := set.Version
+		var err error
+		set, err = upgrade(set)
+		if err != nil {
+			return Set{}, fmt.Errorf("upgrade from version %d: %w", fromVersion, err)
+		}
+		if set.Version <= fromVersion {
+			return Set{}, fmt.Errorf("upgrade from version %d did not increase version: %w", fromVersion, ErrUnsupportedVersion)
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,123 @@
+package depset
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUpgradeFromVersion0(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION",
+			},
+		},
+	}
+	expectedSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION",
+			},
+		},
+		Version: CurrentVersion,
+	}
+
+	upgraded, err := Upgrade(inputSet)
+	if err != nil {
+		t.Errorf("Expected no error, got error: %v", err)
+	} else if !reflect.DeepEqual(upgraded, expectedSet) {
+		t.Errorf("Expected: `%+v`, got `%+v`", expectedSet, upgraded)
+	}
+}
+
+func TestUpgradeCurrentVersion(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION",
+			},
+		},
+		Version: CurrentVersion,
+	}
+
+	upgraded, err := Upgrade(inputSet)
+	if err != nil {
+		t.Errorf("Expected no error, got error: %v", err)
+	} else if !reflect.DeepEqual(upgraded, inputSet) {
+		t.Errorf("Expected: `%+v`, got `%+v`", inputSet, upgraded)
+	}
+}
+
+func TestUpgradeUnsupportedVersion(t *testing.T) {
+	for _, version := range []int{-1, CurrentVersion + 1} {
+		_, err := Upgrade(Set{Version: version})
+		if !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("Version %d: expected ErrUnsupportedVersion, got: %v", version, err)
+		}
+	}
+}
+
+func TestUpgradeMissingUpgrade(t *testing.T) {
+	upgrade := upgrades[0]
+	delete(upgrades, 0)
+	defer func() { upgrades[0] = upgrade }()
+
+	_, err := Upgrade(Set{})
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got: %v", err)
+	}
+}
+
+func TestApplyUpgradesSet(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION",
+			},
+		},
+	}
+	expectedSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"test-module": map[string]interface{}{
+				"version": "TEST_VERSION",
+			},
+		},
+		Version: CurrentVersion,
+	}
+	validateApply(inputSet, Delta{}, expectedSet, t)
+}
+
+func TestApplyUnsupportedVersion(t *testing.T) {
+	_, err := Set{Version: CurrentVersion + 1}.Apply(Delta{})
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got: %v", err)
+	}
+}
+
+// Version 0 and version 1 sets have the same shape, so upgrading must not change the ID.
+func TestHashVersion1MatchesVersion0(t *testing.T) {
+	inputSet := Set{
+		Modules: map[string]map[string]interface{}{
+			"first-module": map[string]interface{}{
+				"StringParam": "Some string!",
+				"IntParam":    123,
+				"FloatParam":  125.5,
+				"BoolParam":   true,
+			},
+			"another-one": map[string]interface{}{
+				"version": "TEST_VERSION",
+				"param":   "TEST_param",
+			},
+		},
+	}
+	expectedHash := inputSet.Hash()
+	inputSet.Version = 1
+
+	validateHash(inputSet, expectedHash, t)
+
+	inputSet.Version = 2
+	if inputSet.Hash() == expectedHash {
+		t.Errorf("Expected version 2 set to have a different ID from version 0 set")
+	}
+}
@@ -0,0 +1,602 @@
+package invariant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"humanitec.io/deploymentset-svc/pkg/jsonpointer"
+)
+
+// ErrInvalidExpression indicates that the syntax of an expression is invalid.
+var ErrInvalidExpression = errors.New("invalid expression")
+
+// ErrEvaluation indicates that an expression could not be evaluated, e.g. because it compares values of different
+// types.
+var ErrEvaluation = errors.New("evaluation failed")
+
+// Expression is a parsed invariant expression. See Parse for the syntax.
+type Expression struct {
+	source string
+	root   node
+}
+
+// scope is what an expression is evaluated against: the modules of a Set, keyed by name, and the name of the module
+// the expression is about.
+type scope struct {
+	modules map[string]interface{}
+	module  string
+}
+
+type node interface {
+	eval(s scope) (interface{}, error)
+}
+
+// Parse parses an invariant expression. Expressions are made of:
+//
+// - literals: numbers, strings in single or double quotes, true, false and null
+//
+// - the comparison operators ==, !=, <, <=, > and >=, the logical operators &&, || and !, and parentheses
+//
+// - the functions value(pointer), exists(pointer), startsWith(s, prefix), endsWith(s, suffix), contains(s, x),
+// matches(s, regexp) and len(x)
+//
+// Pointers are json-pointers into the spec of the module the expression is about, e.g. '/values/replicas', or relative
+// json-pointers starting from the module spec, e.g. '1/database' refers to the spec of the module called database and
+// '0#' to the name of the module. value returns null for pointers to values that do not exist.
+func Parse(expression string) (*Expression, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected `%s` at offset %d: %w", p.peek().text, p.peek().offset, ErrInvalidExpression)
+	}
+	return &Expression{source: expression, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Eval evaluates the expression for module, one of the modules of a Set. An error wrapping ErrEvaluation is returned if
+// the expression cannot be evaluated or does not evaluate to a boolean.
+func (e *Expression) Eval(modules map[string]interface{}, module string) (bool, error) {
+	value, err := e.root.eval(scope{modules: modules, module: module})
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected a boolean result, got %s: %w", typeName(value), ErrEvaluation)
+	}
+	return result, nil
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	value  interface{}
+	offset int
+}
+
+// operators is ordered so that longer operators are matched first.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", ","}
+
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(expression) {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			text, value, err := scanString(expression[i:])
+			if err != nil {
+				return nil, fmt.Errorf("string at offset %d: %v: %w", i, err, ErrInvalidExpression)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, value: value, offset: i})
+			i += len(text)
+		case isDigit(c) || (c == '-' && i+1 < len(expression) && isDigit(expression[i+1])):
+			j := i + 1
+			for j < len(expression) && (isDigit(expression[j]) || strings.IndexByte(".eE+-", expression[j]) >= 0) {
+				j++
+			}
+			value, err := strconv.ParseFloat(expression[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("number `%s` at offset %d: %w", expression[i:j], i, ErrInvalidExpression)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: expression[i:j], value: value, offset: i})
+			i = j
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(expression) && (isIdentStart(expression[j]) || isDigit(expression[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: expression[i:j], offset: i})
+			i = j
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(expression[i:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, offset: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected `%c` at offset %d: %w", c, i, ErrInvalidExpression)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, text: "end of expression", offset: len(expression)}), nil
+}
+
+// scanString returns the source text and value of the string literal at the start of s. Double quoted strings follow
+// the JSON syntax. In single quoted strings, only \' and \\ are escapes.
+func scanString(s string) (string, string, error) {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			text := s[:i+1]
+			if quote == '"' {
+				var value string
+				if err := json.Unmarshal([]byte(text), &value); err != nil {
+					return "", "", err
+				}
+				return text, value, nil
+			}
+			value := strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(text[1:i])
+			return text, value, nil
+		}
+	}
+	return "", "", errors.New("not terminated")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// Parser
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOperator(op string) bool {
+	t := p.peek()
+	return t.kind == tokenOperator && t.text == op
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOperator(op) {
+		return fmt.Errorf("expected `%s` at offset %d, got `%s`: %w", op, p.peek().offset, p.peek().text, ErrInvalidExpression)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("&&") {
+		p.next()
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.isOperator(op) {
+			p.next()
+			right, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return comparisonNode{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOperator("!") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber, tokenString:
+		return literalNode{value: t.value}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		}
+		return p.parseCall(t)
+	case tokenOperator:
+		if t.text == "(" {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected `%s` at offset %d: %w", t.text, t.offset, ErrInvalidExpression)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function `%s` at offset %d: %w", name.text, name.offset, ErrInvalidExpression)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []node
+	for !p.isOperator(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+
+	if len(args) != fn.arity {
+		return nil, fmt.Errorf("`%s` at offset %d takes %d arguments, got %d: %w", name.text, name.offset, fn.arity, len(args), ErrInvalidExpression)
+	}
+	if fn.pointerArg {
+		// Check literal pointers now rather than each time the expression is evaluated.
+		if literal, ok := args[0].(literalNode); ok {
+			pointer, ok := literal.value.(string)
+			if !ok {
+				return nil, fmt.Errorf("`%s` at offset %d takes a pointer string: %w", name.text, name.offset, ErrInvalidExpression)
+			}
+			if _, err := parsePointer(pointer); err != nil {
+				return nil, fmt.Errorf("`%s` at offset %d: %v: %w", name.text, name.offset, err, ErrInvalidExpression)
+			}
+		}
+	}
+	return callNode{name: name.text, fn: fn, args: args}, nil
+}
+
+// Evaluation
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n literalNode) eval(s scope) (interface{}, error) {
+	return n.value, nil
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n notNode) eval(s scope) (interface{}, error) {
+	value, err := evalBool(n.operand, s, "!")
+	if err != nil {
+		return nil, err
+	}
+	return !value, nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+// eval short circuits so that e.g. `!exists(p) || value(p) > 1` does not compare null with a number.
+func (n logicalNode) eval(s scope) (interface{}, error) {
+	left, err := evalBool(n.left, s, n.op)
+	if err != nil {
+		return nil, err
+	}
+	if (n.op == "||" && left) || (n.op == "&&" && !left) {
+		return left, nil
+	}
+	return evalBool(n.right, s, n.op)
+}
+
+func evalBool(n node, s scope, op string) (bool, error) {
+	value, err := n.eval(s)
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("`%s` expects a boolean, got %s: %w", op, typeName(value), ErrEvaluation)
+	}
+	return b, nil
+}
+
+type comparisonNode struct {
+	op          string
+	left, right node
+}
+
+func (n comparisonNode) eval(s scope) (interface{}, error) {
+	left, err := n.left.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return reflect.DeepEqual(left, right), nil
+	case "!=":
+		return !reflect.DeepEqual(left, right), nil
+	}
+
+	var cmp int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare number with %s using `%s`: %w", typeName(right), n.op, ErrEvaluation)
+		}
+		if l < r {
+			cmp = -1
+		} else if l > r {
+			cmp = 1
+		}
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string with %s using `%s`: %w", typeName(right), n.op, ErrEvaluation)
+		}
+		cmp = strings.Compare(l, r)
+	default:
+		return nil, fmt.Errorf("cannot compare %s using `%s`: %w", typeName(left), n.op, ErrEvaluation)
+	}
+
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+type function struct {
+	arity int
+	// pointerArg is true if the first argument is a pointer.
+	pointerArg bool
+	call       func(s scope, args []interface{}) (interface{}, error)
+}
+
+var functions = map[string]function{
+	"value": {arity: 1, pointerArg: true, call: func(s scope, args []interface{}) (interface{}, error) {
+		value, _, err := s.resolve(args[0])
+		return value, err
+	}},
+	"exists": {arity: 1, pointerArg: true, call: func(s scope, args []interface{}) (interface{}, error) {
+		_, exists, err := s.resolve(args[0])
+		return exists, err
+	}},
+	"startsWith": {arity: 2, call: stringFunction("startsWith", strings.HasPrefix)},
+	"endsWith":   {arity: 2, call: stringFunction("endsWith", strings.HasSuffix)},
+	"contains": {arity: 2, call: func(s scope, args []interface{}) (interface{}, error) {
+		if array, ok := args[0].([]interface{}); ok {
+			for _, element := range array {
+				if reflect.DeepEqual(element, args[1]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return stringFunction("contains", strings.Contains)(s, args)
+	}},
+	"matches": {arity: 2, call: func(s scope, args []interface{}) (interface{}, error) {
+		str, pattern, err := stringArgs("matches", args)
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("`matches`: %v: %w", err, ErrEvaluation)
+		}
+		return re.MatchString(str), nil
+	}},
+	"len": {arity: 1, call: func(s scope, args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case string:
+			return float64(len([]rune(v))), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		}
+		return nil, fmt.Errorf("`len` expects a string, array or object, got %s: %w", typeName(args[0]), ErrEvaluation)
+	}},
+}
+
+func stringArgs(name string, args []interface{}) (string, string, error) {
+	a, aOK := args[0].(string)
+	b, bOK := args[1].(string)
+	if !aOK || !bOK {
+		return "", "", fmt.Errorf("`%s` expects strings, got %s and %s: %w", name, typeName(args[0]), typeName(args[1]), ErrEvaluation)
+	}
+	return a, b, nil
+}
+
+func stringFunction(name string, fn func(a, b string) bool) func(s scope, args []interface{}) (interface{}, error) {
+	return func(s scope, args []interface{}) (interface{}, error) {
+		a, b, err := stringArgs(name, args)
+		if err != nil {
+			return nil, err
+		}
+		return fn(a, b), nil
+	}
+}
+
+type callNode struct {
+	name string
+	fn   function
+	args []node
+}
+
+func (n callNode) eval(s scope) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i := range n.args {
+		var err error
+		args[i], err = n.args[i].eval(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return n.fn.call(s, args)
+}
+
+// parsePointer parses an absolute or relative json-pointer as used in expressions.
+func parsePointer(pointer string) (jsonpointer.RelativePointer, error) {
+	if pointer == "" || pointer[0] == '/' {
+		parsed, err := jsonpointer.Parse(pointer)
+		if err != nil {
+			return jsonpointer.RelativePointer{}, err
+		}
+		return jsonpointer.RelativePointer{Up: 0, Pointer: parsed}, nil
+	}
+	return jsonpointer.ParseRelative(pointer)
+}
+
+// resolve returns the value pointer refers to and whether it exists.
+func (s scope) resolve(pointer interface{}) (interface{}, bool, error) {
+	str, ok := pointer.(string)
+	if !ok {
+		return nil, false, fmt.Errorf("expected a pointer string, got %s: %w", typeName(pointer), ErrEvaluation)
+	}
+	r, err := parsePointer(str)
+	if err != nil {
+		return nil, false, fmt.Errorf("%v: %w", err, ErrEvaluation)
+	}
+	value, err := r.Resolve(s.modules, jsonpointer.Pointer{s.module})
+	if err != nil {
+		if errors.Is(err, jsonpointer.ErrInvalidPointer) {
+			return nil, false, fmt.Errorf("%v: %w", err, ErrEvaluation)
+		}
+		// Anything else means that there is no value at the pointer.
+		return nil, false, nil
+	}
+	return value, true, nil
+}
+
+// typeName returns the JSON name of the type of value.
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
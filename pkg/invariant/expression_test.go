@@ -0,0 +1,104 @@
+package invariant
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func testModules() map[string]interface{} {
+	return map[string]interface{}{
+		"backend": map[string]interface{}{
+			"helmchart": "humanitec/base-module",
+			"values": map[string]interface{}{
+				"image":    "registry.humanitec.io/my-org/backend:1.2.0",
+				"replicas": 2.0,
+				"ports":    []interface{}{8080.0, 8443.0},
+			},
+		},
+		"frontend": map[string]interface{}{
+			"helmchart": "humanitec/base-module",
+			"values": map[string]interface{}{
+				"image": "registry.humanitec.io/my-org/frontend:latest",
+			},
+		},
+	}
+}
+
+func TestEval(t *testing.T) {
+	is := is.New(t)
+	tests := []struct {
+		expression string
+		module     string
+		expected   bool
+	}{
+		{`value('/values/replicas') >= 1`, "backend", true},
+		{`value('/values/replicas') > 2`, "backend", false},
+		{`value("/values/replicas") == 2`, "backend", true},
+		{`value('/values/replicas') == null`, "frontend", true},
+		{`!exists('/values/replicas') || value('/values/replicas') >= 1`, "frontend", true},
+		{`exists('/values/replicas') && value('/values/replicas') >= 1`, "frontend", false},
+		{`!endsWith(value('/values/image'), ':latest')`, "backend", true},
+		{`!endsWith(value('/values/image'), ':latest')`, "frontend", false},
+		{`startsWith(value('/values/image'), 'registry.humanitec.io/')`, "frontend", true},
+		{`matches(value('/values/image'), ':[0-9]+\\.[0-9]+\\.[0-9]+$')`, "backend", true},
+		{`contains(value('/values/ports'), 8443)`, "backend", true},
+		{`contains(value('/values/image'), 'frontend')`, "backend", false},
+		{`len(value('/values/ports')) == 2`, "backend", true},
+		{`exists('1/backend')`, "frontend", true},
+		{`exists('1/database')`, "frontend", false},
+		{`value('1/backend/values/replicas') >= 2`, "frontend", true},
+		{`value('0#') == 'frontend'`, "frontend", true},
+		{`value('/values/image/tag') == null`, "frontend", true},
+		{`(1 < 2) == true && !(2 <= 1) && 'a' < 'b' && -1 < 0`, "backend", true},
+		{`true || value('/values/replicas') > 'x'`, "backend", true},
+	}
+	for _, test := range tests {
+		expression, err := Parse(test.expression)
+		is.NoErr(err)
+		result, err := expression.Eval(testModules(), test.module)
+		is.NoErr(err)
+		if result != test.expected {
+			t.Errorf("%s for %s: expected %v, got %v", test.expression, test.module, test.expected, result)
+		}
+	}
+}
+
+func TestEval_Errors(t *testing.T) {
+	is := is.New(t)
+	for _, expression := range []string{
+		`value('/values/replicas') >= 1`,
+		`value('/values/replicas')`,
+		`!value('/values/image')`,
+		`endsWith(value('/values/replicas'), 'x')`,
+		`matches('a', '(')`,
+		`len(1) == 1`,
+		`1 < 'a'`,
+	} {
+		parsed, err := Parse(expression)
+		is.NoErr(err)
+		_, err = parsed.Eval(testModules(), "frontend")
+		is.True(errors.Is(err, ErrEvaluation)) // expression should fail to evaluate
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	is := is.New(t)
+	for _, expression := range []string{
+		``,
+		`value('/values/replicas') >=`,
+		`(true`,
+		`true)`,
+		`unknown(1)`,
+		`value('/values', 1)`,
+		`value('values')`,
+		`value(1)`,
+		`'not terminated`,
+		`1 # 2`,
+		`endsWith('a' 'b')`,
+	} {
+		_, err := Parse(expression)
+		is.True(errors.Is(err, ErrInvalidExpression)) // expression should be invalid
+	}
+}
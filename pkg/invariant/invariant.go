@@ -0,0 +1,109 @@
+// Package invariant checks declarative rules, such as "replicas >= 1", against the modules of a Deployment Set.
+package invariant
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+
+	"humanitec.io/deploymentset-svc/pkg/depset"
+)
+
+// ErrInvalid indicates that an invariant is not valid.
+var ErrInvalid = errors.New("invalid invariant")
+
+// Invariant is a rule that must hold for every module of a Deployment Set whose name matches Modules.
+//
+// Modules is a shell pattern as used by path.Match, e.g. "web-*". An empty pattern matches every module. Expression
+// is evaluated for each matching module and must be true. See Parse for the syntax of expressions.
+type Invariant struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Modules     string `json:"modules,omitempty"`
+	Expression  string `json:"expression"`
+}
+
+// Invariants is the list of invariants attached to an app.
+type Invariants []Invariant
+
+// Violation describes a module for which an invariant does not hold.
+type Violation struct {
+	Invariant string `json:"invariant"`
+	Module    string `json:"module"`
+	Message   string `json:"message"`
+}
+
+func (v Violation) Error() string {
+	return fmt.Sprintf("invariant `%s` module `%s`: %s", v.Invariant, v.Module, v.Message)
+}
+
+// matches returns true if the invariant applies to the named module.
+func (inv Invariant) matches(module string) bool {
+	if inv.Modules == "" {
+		return true
+	}
+	// The pattern is checked by Validate, so an error means that nothing matches.
+	matched, _ := path.Match(inv.Modules, module)
+	return matched
+}
+
+// Validate checks that every invariant has a unique name, a valid module pattern and an expression that parses. The
+// first problem found is returned wrapping ErrInvalid.
+func (invs Invariants) Validate() error {
+	names := make(map[string]bool)
+	for i, inv := range invs {
+		if inv.Name == "" {
+			return fmt.Errorf("invariant %d: name must not be empty: %w", i, ErrInvalid)
+		}
+		if names[inv.Name] {
+			return fmt.Errorf("invariant `%s`: name is not unique: %w", inv.Name, ErrInvalid)
+		}
+		names[inv.Name] = true
+		if _, err := path.Match(inv.Modules, ""); err != nil {
+			return fmt.Errorf("invariant `%s`: modules `%s`: %v: %w", inv.Name, inv.Modules, err, ErrInvalid)
+		}
+		if _, err := Parse(inv.Expression); err != nil {
+			return fmt.Errorf("invariant `%s`: %v: %w", inv.Name, err, ErrInvalid)
+		}
+	}
+	return nil
+}
+
+// Check evaluates the invariants against every matching module of the Set and returns a Violation for each module an
+// invariant does not hold for, including those the expression cannot be evaluated for. Invariants are checked in
+// order and modules in name order. Invariants that are not valid are reported as violated by every module.
+func (invs Invariants) Check(inputSet depset.Set) []Violation {
+	modules := make(map[string]interface{}, len(inputSet.Modules))
+	names := make([]string, 0, len(inputSet.Modules))
+	for name, spec := range inputSet.Modules {
+		modules[name] = spec
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var violations []Violation
+	for _, inv := range invs {
+		expression, parseErr := Parse(inv.Expression)
+		for _, name := range names {
+			if !inv.matches(name) {
+				continue
+			}
+			var message string
+			if parseErr != nil {
+				message = parseErr.Error()
+			} else if ok, err := expression.Eval(modules, name); err != nil {
+				message = fmt.Sprintf("`%s` could not be evaluated: %v", inv.Expression, err)
+			} else if !ok {
+				message = fmt.Sprintf("`%s` is false", inv.Expression)
+				if inv.Description != "" {
+					message = inv.Description
+				}
+			} else {
+				continue
+			}
+			violations = append(violations, Violation{Invariant: inv.Name, Module: name, Message: message})
+		}
+	}
+	return violations
+}
@@ -0,0 +1,53 @@
+package invariant
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+	"humanitec.io/deploymentset-svc/pkg/depset"
+)
+
+func TestCheck(t *testing.T) {
+	is := is.New(t)
+	set := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"backend":  testModules()["backend"].(map[string]interface{}),
+			"frontend": testModules()["frontend"].(map[string]interface{}),
+		},
+	}
+	invs := Invariants{
+		{Name: "replicas", Expression: `!exists('/values/replicas') || value('/values/replicas') >= 1`},
+		{Name: "no-latest", Description: "image tag must not be `latest`", Expression: `!endsWith(value('/values/image'), ':latest')`},
+		{Name: "frontend-needs-database", Modules: "front*", Expression: `exists('1/database')`},
+		{Name: "replicas-set", Modules: "frontend", Expression: `value('/values/replicas') >= 1`},
+	}
+	is.NoErr(invs.Validate())
+	is.Equal(invs.Check(set), []Violation{
+		{Invariant: "no-latest", Module: "frontend", Message: "image tag must not be `latest`"},
+		{Invariant: "frontend-needs-database", Module: "frontend", Message: "`exists('1/database')` is false"},
+		{
+			Invariant: "replicas-set",
+			Module:    "frontend",
+			Message:   "`value('/values/replicas') >= 1` could not be evaluated: cannot compare null using `>=`: evaluation failed",
+		},
+	})
+}
+
+func TestCheck_EmptySet(t *testing.T) {
+	is := is.New(t)
+	invs := Invariants{{Name: "anything", Expression: `false`}}
+	is.Equal(len(invs.Check(depset.Set{})), 0) // invariants only apply to modules
+}
+
+func TestValidate_Invalid(t *testing.T) {
+	is := is.New(t)
+	for _, invs := range []Invariants{
+		{{Expression: `true`}},
+		{{Name: "a", Expression: `true`}, {Name: "a", Expression: `true`}},
+		{{Name: "a", Modules: "[", Expression: `true`}},
+		{{Name: "a", Expression: `true &&`}},
+	} {
+		is.True(errors.Is(invs.Validate(), ErrInvalid)) // invariants should be invalid
+	}
+}
@@ -0,0 +1,193 @@
+// Package jcs implements the JSON Canonicalization Scheme (JCS) defined in RFC 8785
+// (https://tools.ietf.org/html/rfc8785).
+//
+// The canonical form of a JSON value has no whitespace, object properties sorted by their UTF-16 code units, strings
+// escaped in the same way as ECMAScript's JSON.stringify and numbers serialized as IEEE 754 doubles in the format used
+// by ECMAScript's Number.prototype.toString.
+package jcs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrInvalidNumber indicates that a number cannot be represented in canonical JSON. (E.g. NaN or Infinity.)
+var ErrInvalidNumber = errors.New("number not representable in canonical json")
+
+// ErrInvalidString indicates that a string or object key is not valid UTF-8.
+var ErrInvalidString = errors.New("string is not valid utf-8")
+
+// ErrUnsupportedType indicates that a value is not one of the types returned by json.Unmarshal.
+var ErrUnsupportedType = errors.New("unsupported type")
+
+// Marshal returns the canonical JSON encoding of v. v is first encoded with json.Marshal, so struct tags and the
+// json.Marshaler interface are respected.
+func Marshal(v interface{}) ([]byte, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	return Transform(buf)
+}
+
+// Transform returns the canonical form of the JSON document in data.
+func Transform(data []byte) ([]byte, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeValue(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeValue(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		if v {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case float64:
+		number, err := FormatNumber(v)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(number)
+	case string:
+		return writeString(buf, v)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeValue(buf, v[i]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sortKeys(keys)
+
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeString(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeValue(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("%T: %w", value, ErrUnsupportedType)
+	}
+	return nil
+}
+
+// sortKeys sorts object property names by their UTF-16 code units as required by RFC 8785 section 3.2.3.
+func sortKeys(keys []string) {
+	utf16Keys := make(map[string][]uint16, len(keys))
+	for _, key := range keys {
+		utf16Keys[key] = utf16.Encode([]rune(key))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := utf16Keys[keys[i]], utf16Keys[keys[j]]
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+}
+
+// writeString writes s as a JSON string literal using the same escaping as ECMAScript's JSON.stringify.
+func writeString(buf *bytes.Buffer, s string) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("%q: %w", s, ErrInvalidString)
+	}
+
+	const hex = "0123456789abcdef"
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[r>>4])
+				buf.WriteByte(hex[r&0xF])
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return nil
+}
+
+// FormatNumber formats f in the same way as ECMAScript's Number.prototype.toString as required by RFC 8785 section
+// 3.2.2.3.
+func FormatNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("%v: %w", f, ErrInvalidNumber)
+	}
+
+	// Both 0 and -0 are serialized as "0".
+	if f == 0 {
+		return "0", nil
+	}
+
+	// ECMAScript switches to exponential notation outside of the range [1e-6, 1e21). strconv produces the shortest
+	// representation that round trips, which is the same set of digits ECMAScript uses.
+	format := byte('f')
+	if abs := math.Abs(f); abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(f, format, -1, 64)
+
+	if format == 'e' {
+		// strconv always writes at least two exponent digits (e.g. "1e-07"), ECMAScript writes as few as possible.
+		n := len(s)
+		if n >= 4 && s[n-4] == 'e' && s[n-2] == '0' {
+			s = s[:n-2] + s[n-1:]
+		}
+	}
+	return s, nil
+}
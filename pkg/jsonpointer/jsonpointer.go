@@ -13,46 +13,166 @@ var ErrInvalidPointer = errors.New("invalid json-pointer syntax")
 // ErrDoesNotExist indicates that the pointer references a value that does not exist
 var ErrDoesNotExist = errors.New("value does not exist")
 
-func unescapesegment(segment string) string {
+// ErrIndexOutOfRange indicates that the pointer references an element beyond the end of an array. It wraps
+// ErrDoesNotExist.
+var ErrIndexOutOfRange = fmt.Errorf("array index out of range: %w", ErrDoesNotExist)
+
+// ErrInvalidIndex indicates that a segment referencing an element of an array is not a valid index. Indices must be
+// non-negative decimal integers without leading zeros, or "-" for the (nonexistent) element after the last one.
+var ErrInvalidIndex = errors.New("invalid array index")
+
+// ErrNotContainer indicates that the pointer continues below a value that is not an object or an array.
+var ErrNotContainer = errors.New("value is not an object or array")
+
+// Pointer is a parsed json-pointer (https://tools.ietf.org/html/rfc6901). Each element is an unescaped reference
+// token, i.e. a property name or an array index. The empty Pointer refers to the whole document.
+type Pointer []string
+
+// Escape escapes a property name or index so that it can be used as a reference token in a json-pointer string.
+func Escape(segment string) string {
+	// As per https://tools.ietf.org/html/rfc6901#section-3, "~" must be escaped first so that the "~" introduced by
+	// escaping "/" is not escaped again.
+	return strings.ReplaceAll(strings.ReplaceAll(segment, "~", "~0"), "/", "~1")
+}
+
+// Unescape converts a reference token from a json-pointer string back into the property name or index it refers to.
+func Unescape(segment string) string {
 	// As per https://tools.ietf.org/html/rfc6901#section-4, process ~1 first, then ~0
 	unescapedSeg := strings.ReplaceAll(segment, "~1", "/")
 	return strings.ReplaceAll(unescapedSeg, "~0", "~")
 }
 
-// ToPath converts a json-pointer to an slice of property names or indicies.
+// Parse checks the syntax of a json-pointer string and converts it to a Pointer.
+func Parse(pointer string) (Pointer, error) {
+	if pointer == "" {
+		return Pointer{}, nil
+	}
+	if strings.Index(pointer, "/") != 0 {
+		return nil, fmt.Errorf("non-empty pointer does not start with '/': %w", ErrInvalidPointer)
+	}
+	for i := 0; i < len(pointer); i++ {
+		if pointer[i] == '~' && (i+1 == len(pointer) || (pointer[i+1] != '0' && pointer[i+1] != '1')) {
+			return nil, fmt.Errorf("'~' at offset %d is not followed by '0' or '1': %w", i, ErrInvalidPointer)
+		}
+	}
+	return ToPath(pointer), nil
+}
+
+// String returns the json-pointer string for p with every reference token escaped.
+func (p Pointer) String() string {
+	var b strings.Builder
+	for _, segment := range p {
+		b.WriteByte('/')
+		b.WriteString(Escape(segment))
+	}
+	return b.String()
+}
+
+// Append returns a new Pointer referring to the property or index segment of the value p refers to. p is not updated.
+func (p Pointer) Append(segment string) Pointer {
+	appended := make(Pointer, len(p), len(p)+1)
+	copy(appended, p)
+	return append(appended, segment)
+}
+
+// Parent returns the Pointer to the object or array containing the value p refers to. The parent of the whole
+// document is the whole document.
+func (p Pointer) Parent() Pointer {
+	if len(p) == 0 {
+		return p
+	}
+	return p[:len(p)-1]
+}
+
+// HasPrefix returns true if prefix is the same as p or refers to an ancestor of the value p refers to.
+func (p Pointer) HasPrefix(prefix Pointer) bool {
+	if len(prefix) > len(p) {
+		return false
+	}
+	for i := range prefix {
+		if p[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks that pointer is a syntactically valid json-pointer as defined in
+// https://tools.ietf.org/html/rfc6901#section-3. The empty string is valid and refers to the whole document.
+func Validate(pointer string) error {
+	_, err := Parse(pointer)
+	return err
+}
+
+// ToPath converts a json-pointer to an slice of property names or indicies. The syntax of ptr is not checked, use
+// Parse for that.
 func ToPath(ptr string) []string {
+	if ptr == "" {
+		return []string{}
+	}
 	segments := strings.Split(ptr, "/")
 	for i := range segments {
-		segments[i] = unescapesegment(segments[i])
+		segments[i] = Unescape(segments[i])
 	}
 	return segments[1:]
 }
 
+// parseIndex converts segment into an index into an array of length elements. If allowEnd is true, "-" and length
+// itself are allowed and refer to the position after the last element.
+func parseIndex(segment string, length int, allowEnd bool) (int, error) {
+	if segment == "-" {
+		if allowEnd {
+			return length, nil
+		}
+		return 0, fmt.Errorf("`-` refers to the element after the last: %w", ErrIndexOutOfRange)
+	}
+	if segment == "" || (len(segment) > 1 && segment[0] == '0') {
+		return 0, fmt.Errorf("`%s`: %w", segment, ErrInvalidIndex)
+	}
+	for _, c := range segment {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("`%s`: %w", segment, ErrInvalidIndex)
+		}
+	}
+	index, err := strconv.Atoi(segment)
+	if err != nil || index > length || (index == length && !allowEnd) {
+		return 0, fmt.Errorf("index %s of array of length %d: %w", segment, length, ErrIndexOutOfRange)
+	}
+	return index, nil
+}
+
+// child returns the value referenced by segment in obj.
+func child(obj interface{}, segment string) (interface{}, error) {
+	switch container := obj.(type) {
+	case map[string]interface{}:
+		value, ok := container[segment]
+		if !ok {
+			return nil, fmt.Errorf("property `%s`: %w", segment, ErrDoesNotExist)
+		}
+		return value, nil
+	case []interface{}:
+		index, err := parseIndex(segment, len(container), false)
+		if err != nil {
+			return nil, err
+		}
+		return container[index], nil
+	}
+	return nil, fmt.Errorf("`%s` of %T: %w", segment, obj, ErrNotContainer)
+}
+
 // Extract takes the data structure returned by json.Unmarshal and returns the value pointed at by pointer.
 func Extract(obj interface{}, pointer string) (interface{}, error) {
-	if pointer == "" {
-		return obj, nil
-	}
-	if strings.Index(pointer, "/") != 0 {
-		return nil, fmt.Errorf("non-empty pointer does not start with '/': %w", ErrInvalidPointer)
+	path, err := Parse(pointer)
+	if err != nil {
+		return nil, err
 	}
 
-	segments := ToPath(pointer)
 	currentObj := obj
-	for _, segment := range segments {
-		if slice, ok := currentObj.([]interface{}); ok {
-			if index, err := strconv.Atoi(segment); err == nil {
-				currentObj = slice[index]
-			} else {
-				return nil, ErrDoesNotExist
-			}
-		} else if mapObj, ok := currentObj.(map[string]interface{}); ok {
-			currentObj, ok = mapObj[segment]
-			if !ok {
-				return nil, ErrDoesNotExist
-			}
+	for _, segment := range path {
+		currentObj, err = child(currentObj, segment)
+		if err != nil {
+			return nil, err
 		}
-
 	}
 	return currentObj, nil
 }
@@ -62,35 +182,164 @@ func ExtractParent(obj interface{}, pointer string) (interface{}, string, error)
 	if pointer == "" {
 		return nil, "", fmt.Errorf("can't get parent of top level object: %w", ErrDoesNotExist)
 	}
-	if strings.Index(pointer, "/") != 0 {
-		return nil, "", fmt.Errorf("non-empty pointer does not start with '/': %w", ErrInvalidPointer)
+	path, err := Parse(pointer)
+	if err != nil {
+		return nil, "", err
 	}
 
-	parentObj, err := Extract(obj, pointer[:strings.LastIndex(pointer, "/")])
+	parentObj, err := Extract(obj, path.Parent().String())
 	if err != nil {
 		return nil, "", err
 	}
-	segments := ToPath(pointer)
-	return parentObj, segments[len(segments)-1], nil
+	return parentObj, path[len(path)-1], nil
 }
 
-// ExtractParentOfParent takes the data structure returned by json.Unmarshal and returns the parent 2 levels up in the hiearchy and the key/index for the final value
-func ExtractParentOfParent(obj interface{}, pointer string) (interface{}, string, error) {
+// updateFunc updates the member key of container and returns the updated container. Arrays may have to be
+// reallocated, so the returned container is not necessarily the same as the one passed in.
+type updateFunc func(container interface{}, key string) (interface{}, error)
+
+// update calls fn on the parent of the value pointed at by pointer and stores the updated parent back into its own
+// parent, all the way up to obj. The updated obj is returned.
+func update(obj interface{}, pointer string, fn updateFunc) (interface{}, error) {
 	if pointer == "" {
-		return nil, "", fmt.Errorf("can't get parent of top level object: %w", ErrDoesNotExist)
+		return nil, fmt.Errorf("can't get parent of top level object: %w", ErrDoesNotExist)
 	}
-	if strings.Index(pointer, "/") != 0 {
-		return nil, "", fmt.Errorf("non-empty pointer does not start with '/': %w", ErrInvalidPointer)
+	path, err := Parse(pointer)
+	if err != nil {
+		return nil, err
 	}
+	return updateSegments(obj, path, fn)
+}
 
-	segments := ToPath(pointer)
-	if len(segments) < 2 {
-		return nil, "", fmt.Errorf("can't go beyond top level object: %w", ErrDoesNotExist)
+func updateSegments(obj interface{}, segments []string, fn updateFunc) (interface{}, error) {
+	if len(segments) == 1 {
+		return fn(obj, segments[0])
 	}
 
-	parentObj, _, err := ExtractParent(obj, pointer[:strings.LastIndex(pointer, "/")])
+	value, err := child(obj, segments[0])
 	if err != nil {
-		return nil, "", err
+		return nil, err
+	}
+	value, err = updateSegments(value, segments[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+
+	// child has already checked that obj is a container and that segments[0] refers to a member of it.
+	switch container := obj.(type) {
+	case map[string]interface{}:
+		container[segments[0]] = value
+	case []interface{}:
+		index, _ := parseIndex(segments[0], len(container), false)
+		container[index] = value
+	}
+	return obj, nil
+}
+
+// Set stores value at the location pointed at by pointer, creating the property if it does not exist or overwriting
+// it if it does. For arrays, an existing element is overwritten and an index of "-" or the length of the array
+// appends value. Unlike Insert, no elements are shifted.
+//
+// obj may be updated in place. The updated document is returned and must be used instead of obj, as arrays and the
+// whole document (for the empty pointer) may be replaced.
+func Set(obj interface{}, pointer string, value interface{}) (interface{}, error) {
+	if pointer == "" {
+		return value, nil
+	}
+	return update(obj, pointer, func(container interface{}, key string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[key] = value
+			return c, nil
+		case []interface{}:
+			index, err := parseIndex(key, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			if index == len(c) {
+				return append(c, value), nil
+			}
+			c[index] = value
+			return c, nil
+		}
+		return nil, fmt.Errorf("`%s` of %T: %w", key, container, ErrNotContainer)
+	})
+}
+
+// Insert adds value at the location pointed at by pointer as the JSON Patch "add" operation
+// (https://tools.ietf.org/html/rfc6902#section-4.1) does. Properties are created or overwritten. For arrays, value is
+// inserted before the element at the index, shifting it and all later elements up by one. An index of "-" or the
+// length of the array appends value.
+//
+// obj may be updated in place. The updated document is returned and must be used instead of obj.
+func Insert(obj interface{}, pointer string, value interface{}) (interface{}, error) {
+	if pointer == "" {
+		return value, nil
+	}
+	return update(obj, pointer, func(container interface{}, key string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[key] = value
+			return c, nil
+		case []interface{}:
+			index, err := parseIndex(key, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			inserted := make([]interface{}, 0, len(c)+1)
+			inserted = append(inserted, c[:index]...)
+			inserted = append(inserted, value)
+			return append(inserted, c[index:]...), nil
+		}
+		return nil, fmt.Errorf("`%s` of %T: %w", key, container, ErrNotContainer)
+	})
+}
+
+// Remove deletes the value pointed at by pointer, which must exist. Later elements of arrays are shifted down by one.
+// The whole document cannot be removed.
+//
+// obj may be updated in place. The updated document is returned and must be used instead of obj.
+func Remove(obj interface{}, pointer string) (interface{}, error) {
+	return update(obj, pointer, func(container interface{}, key string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if _, ok := c[key]; !ok {
+				return nil, fmt.Errorf("property `%s`: %w", key, ErrDoesNotExist)
+			}
+			delete(c, key)
+			return c, nil
+		case []interface{}:
+			index, err := parseIndex(key, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			removed := make([]interface{}, 0, len(c)-1)
+			removed = append(removed, c[:index]...)
+			return append(removed, c[index+1:]...), nil
+		}
+		return nil, fmt.Errorf("`%s` of %T: %w", key, container, ErrNotContainer)
+	})
+}
+
+// Replace overwrites the value pointed at by pointer, which must exist.
+//
+// obj may be updated in place. The updated document is returned and must be used instead of obj, as the whole
+// document is replaced for the empty pointer.
+func Replace(obj interface{}, pointer string, value interface{}) (interface{}, error) {
+	if pointer == "" {
+		return value, nil
 	}
-	return parentObj, segments[len(segments)-2], nil
+	return update(obj, pointer, func(container interface{}, key string) (interface{}, error) {
+		if _, err := child(container, key); err != nil {
+			return nil, err
+		}
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[key] = value
+		case []interface{}:
+			index, _ := parseIndex(key, len(c), false)
+			c[index] = value
+		}
+		return container, nil
+	})
 }
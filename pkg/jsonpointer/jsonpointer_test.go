@@ -16,6 +16,16 @@ func TestToPointer(t *testing.T) {
 	is.Equal(expectedPath, actualPath)
 }
 
+func TestValidate(t *testing.T) {
+	is := is.New(t)
+	for _, pointer := range []string{"", "/", "/foo/0", "/~0tilda/with~1a slash", "/~01"} {
+		is.NoErr(Validate(pointer)) // Pointer should be valid
+	}
+	for _, pointer := range []string{"foo", "/foo~", "/foo~2", "/~a"} {
+		is.True(errors.Is(Validate(pointer), ErrInvalidPointer)) // Pointer should be invalid
+	}
+}
+
 func TestExtract(t *testing.T) {
 	is := is.New(t)
 	// From RFC: https://tools.ietf.org/html/rfc6901#section-5
@@ -94,3 +104,171 @@ func TestExtractParent(t *testing.T) {
 	doTest("/array/-", `["foo","bar","baz"]`, `-`)
 	doTest("/object/foo", `{"foo":0}`, `foo`)
 }
+
+func unmarshal(is *is.I, data string) interface{} {
+	var obj interface{}
+	is.NoErr(json.Unmarshal([]byte(data), &obj))
+	return obj
+}
+
+func marshal(is *is.I, obj interface{}) string {
+	data, err := json.Marshal(obj)
+	is.NoErr(err)
+	return string(data)
+}
+
+func TestExtract_Errors(t *testing.T) {
+	is := is.New(t)
+	obj := unmarshal(is, `{"list": ["a", "b"], "scalar": "value", "null": null}`)
+
+	testCases := []struct {
+		pointer  string
+		expected error
+	}{
+		{"/missing", ErrDoesNotExist},
+		{"/list/2", ErrIndexOutOfRange},
+		{"/list/99", ErrDoesNotExist},
+		{"/list/-", ErrIndexOutOfRange},
+		{"/list/-1", ErrInvalidIndex},
+		{"/list/01", ErrInvalidIndex},
+		{"/list/one", ErrInvalidIndex},
+		{"/list/99999999999999999999", ErrIndexOutOfRange},
+		{"/scalar/0", ErrNotContainer},
+		{"/null/key", ErrNotContainer},
+		{"/list/0/key", ErrNotContainer},
+		{"/bad~2escape", ErrInvalidPointer},
+	}
+	for _, testCase := range testCases {
+		_, err := Extract(obj, testCase.pointer)
+		if !errors.Is(err, testCase.expected) {
+			t.Errorf("%s: Expected error `%v`, got `%v`", testCase.pointer, testCase.expected, err)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	is := is.New(t)
+	obj := unmarshal(is, `{"object": {"a": 1}, "list": ["a", "b"]}`)
+
+	obj, err := Set(obj, "/object/a", 2)
+	is.NoErr(err)
+	obj, err = Set(obj, "/object/b", 3)
+	is.NoErr(err)
+	obj, err = Set(obj, "/list/0", "A")
+	is.NoErr(err)
+	obj, err = Set(obj, "/list/2", "c")
+	is.NoErr(err)
+	obj, err = Set(obj, "/list/-", "d")
+	is.NoErr(err)
+	is.Equal(marshal(is, obj), `{"list":["A","b","c","d"],"object":{"a":2,"b":3}}`)
+
+	_, err = Set(obj, "/list/5", "f")
+	is.True(errors.Is(err, ErrIndexOutOfRange)) // Can only append to the end of an array
+	_, err = Set(obj, "/missing/key", "value")
+	is.True(errors.Is(err, ErrDoesNotExist)) // Parent must exist
+
+	root, err := Set(obj, "", "replaced")
+	is.NoErr(err)
+	is.Equal(root, "replaced")
+}
+
+func TestInsert(t *testing.T) {
+	is := is.New(t)
+	obj := unmarshal(is, `{"nested": [{"list": ["a", "c"]}]}`)
+
+	obj, err := Insert(obj, "/nested/0/list/1", "b")
+	is.NoErr(err)
+	obj, err = Insert(obj, "/nested/0/list/3", "d")
+	is.NoErr(err)
+	obj, err = Insert(obj, "/nested/0/list/-", "e")
+	is.NoErr(err)
+	obj, err = Insert(obj, "/nested/0/list/0", "_")
+	is.NoErr(err)
+	obj, err = Insert(obj, "/nested/0/key", "value")
+	is.NoErr(err)
+	is.Equal(marshal(is, obj), `{"nested":[{"key":"value","list":["_","a","b","c","d","e"]}]}`)
+
+	_, err = Insert(obj, "/nested/0/list/7", "x")
+	is.True(errors.Is(err, ErrIndexOutOfRange)) // Index must be at most the length of the array
+	_, err = Insert(obj, "/nested/0/key/0", "x")
+	is.True(errors.Is(err, ErrNotContainer)) // Cannot insert into a string
+}
+
+func TestRemove(t *testing.T) {
+	is := is.New(t)
+	obj := unmarshal(is, `{"object": {"a": 1, "b": 2}, "list": ["a", "b", "c"]}`)
+
+	obj, err := Remove(obj, "/object/a")
+	is.NoErr(err)
+	obj, err = Remove(obj, "/list/1")
+	is.NoErr(err)
+	is.Equal(marshal(is, obj), `{"list":["a","c"],"object":{"b":2}}`)
+
+	_, err = Remove(obj, "/object/a")
+	is.True(errors.Is(err, ErrDoesNotExist)) // Value must exist
+	_, err = Remove(obj, "/list/2")
+	is.True(errors.Is(err, ErrIndexOutOfRange)) // Element must exist
+	_, err = Remove(obj, "/list/-")
+	is.True(errors.Is(err, ErrIndexOutOfRange)) // "-" never refers to an existing element
+	_, err = Remove(obj, "")
+	is.True(errors.Is(err, ErrDoesNotExist)) // The whole document cannot be removed
+}
+
+func TestReplace(t *testing.T) {
+	is := is.New(t)
+	obj := unmarshal(is, `{"object": {"a": 1}, "list": ["a", "b"]}`)
+
+	obj, err := Replace(obj, "/object/a", 2)
+	is.NoErr(err)
+	obj, err = Replace(obj, "/list/1", "B")
+	is.NoErr(err)
+	is.Equal(marshal(is, obj), `{"list":["a","B"],"object":{"a":2}}`)
+
+	_, err = Replace(obj, "/object/b", 3)
+	is.True(errors.Is(err, ErrDoesNotExist)) // Value must exist
+	_, err = Replace(obj, "/list/2", "c")
+	is.True(errors.Is(err, ErrIndexOutOfRange)) // Element must exist
+}
+
+func TestPointer(t *testing.T) {
+	is := is.New(t)
+
+	p, err := Parse("/values/a~1b/m~0n/0")
+	is.NoErr(err)
+	is.Equal(p, Pointer{"values", "a/b", "m~n", "0"})
+	is.Equal(p.String(), "/values/a~1b/m~0n/0")
+	is.Equal(p.Parent(), Pointer{"values", "a/b", "m~n"})
+	is.True(p.HasPrefix(Pointer{"values", "a/b"}))
+	is.True(p.HasPrefix(p))
+	is.True(p.HasPrefix(Pointer{}))
+	is.True(!p.HasPrefix(Pointer{"values", "a"})) // Segments are compared whole
+
+	root, err := Parse("")
+	is.NoErr(err)
+	is.Equal(len(root), 0)
+	is.Equal(root.String(), "")
+	is.Equal(len(root.Parent()), 0)
+	is.Equal(root.Append("").String(), "/")
+
+	_, err = Parse("values")
+	is.True(errors.Is(err, ErrInvalidPointer))
+}
+
+func TestPointer_AppendDoesNotAlias(t *testing.T) {
+	is := is.New(t)
+	base := Pointer{"values"}.Append("env")
+	a := base.Append("0")
+	b := base.Append("1")
+	is.Equal(a.String(), "/values/env/0")
+	is.Equal(b.String(), "/values/env/1")
+	is.Equal(base.String(), "/values/env")
+}
+
+func TestEscape(t *testing.T) {
+	is := is.New(t)
+	for _, segment := range []string{"", "plain", "a/b", "m~n", "~1", "~0/~", "/~/"} {
+		is.Equal(Unescape(Escape(segment)), segment) // Escaping should round trip
+	}
+	is.Equal(Escape("~1"), "~01")
+	is.Equal(Unescape("~01"), "~1")
+}
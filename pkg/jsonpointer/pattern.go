@@ -0,0 +1,146 @@
+package jsonpointer
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Pattern is a json-pointer in which the segment "*" matches any single property name or array index and the segment
+// "**" matches any number of segments, including none. E.g. "/*/values/image/tag" matches the tag of the image of
+// every module and "/**/value" matches every property called "value" at any depth.
+//
+// As json-pointers have no way to escape "*", a pattern cannot match only a property literally called "*" or "**".
+type Pattern []string
+
+const (
+	// wildcardSegment matches any single segment.
+	wildcardSegment = "*"
+	// globSegment matches zero or more segments.
+	globSegment = "**"
+)
+
+// Result is a value found by FindAll along with the Pointer to where it was found.
+type Result struct {
+	Pointer Pointer
+	Value   interface{}
+}
+
+// ParsePattern checks the syntax of a pattern and converts it to a Pattern. Patterns have the same syntax as
+// json-pointers.
+func ParsePattern(pattern string) (Pattern, error) {
+	p, err := Parse(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return Pattern(p), nil
+}
+
+// Match returns true if the json-pointer pointer matches pattern. An error is returned if either is not valid.
+func Match(pattern, pointer string) (bool, error) {
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		return false, err
+	}
+	ptr, err := Parse(pointer)
+	if err != nil {
+		return false, err
+	}
+	return p.Match(ptr), nil
+}
+
+// Match returns true if pointer matches the pattern.
+func (p Pattern) Match(pointer Pointer) bool {
+	if len(p) == 0 {
+		return len(pointer) == 0
+	}
+	if p[0] == globSegment {
+		// Either "**" matches no more segments, or it matches the first segment and possibly more.
+		return p[1:].Match(pointer) || (len(pointer) > 0 && p.Match(pointer[1:]))
+	}
+	if len(pointer) == 0 {
+		return false
+	}
+	return (p[0] == wildcardSegment || p[0] == pointer[0]) && p[1:].Match(pointer[1:])
+}
+
+// FindAll returns every value in the data structure returned by json.Unmarshal that is pointed at by a json-pointer
+// matching pattern. An error is returned if pattern is not valid.
+func FindAll(obj interface{}, pattern string) ([]Result, error) {
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return p.FindAll(obj), nil
+}
+
+// FindAll returns every value in obj that is pointed at by a json-pointer matching the pattern. Properties are visited
+// in key order and arrays in index order, so the results are always in the same order. Each value is only returned
+// once, even if the pattern matches it in more than one way. The values are not copied.
+func (p Pattern) FindAll(obj interface{}) []Result {
+	var results []Result
+	seen := make(map[string]bool)
+	p.find(obj, Pointer{}, func(pointer Pointer, value interface{}) {
+		key := pointer.String()
+		if !seen[key] {
+			seen[key] = true
+			results = append(results, Result{Pointer: pointer, Value: value})
+		}
+	})
+	return results
+}
+
+// find calls found for every value under obj, which is at pointer, that matches the pattern.
+func (p Pattern) find(obj interface{}, pointer Pointer, found func(Pointer, interface{})) {
+	if len(p) == 0 {
+		found(pointer, obj)
+		return
+	}
+
+	switch p[0] {
+	case globSegment:
+		p[1:].find(obj, pointer, found)
+		forEachChild(obj, func(segment string, value interface{}) {
+			p.find(value, pointer.Append(segment), found)
+		})
+	case wildcardSegment:
+		forEachChild(obj, func(segment string, value interface{}) {
+			p[1:].find(value, pointer.Append(segment), found)
+		})
+	default:
+		if value, err := child(obj, p[0]); err == nil {
+			p[1:].find(value, pointer.Append(p[0]), found)
+		}
+	}
+}
+
+// forEachChild calls fn for every property of obj in key order if it is an object or every element in index order
+// if it is an array.
+func forEachChild(obj interface{}, fn func(segment string, value interface{})) {
+	switch container := obj.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(container))
+		for key := range container {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fn(key, container[key])
+		}
+	case []interface{}:
+		for i, value := range container {
+			fn(strconv.Itoa(i), value)
+		}
+	}
+}
+
+// Overlaps returns true if the pattern matches pointer, an ancestor of pointer or a pointer below pointer, i.e. if
+// changing the value pointer refers to could change a value the pattern matches.
+func (p Pattern) Overlaps(pointer Pointer) bool {
+	if len(p) == 0 || len(pointer) == 0 {
+		return true
+	}
+	if p[0] == globSegment {
+		return p[1:].Overlaps(pointer) || p.Overlaps(pointer[1:])
+	}
+	return (p[0] == wildcardSegment || p[0] == pointer[0]) && p[1:].Overlaps(pointer[1:])
+}
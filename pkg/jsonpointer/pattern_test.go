@@ -0,0 +1,138 @@
+package jsonpointer
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestMatch(t *testing.T) {
+	is := is.New(t)
+	tests := []struct {
+		pattern string
+		pointer string
+		match   bool
+	}{
+		{"", "", true},
+		{"", "/a", false},
+		{"/a/b", "/a/b", true},
+		{"/a/b", "/a/c", false},
+		{"/*", "/a", true},
+		{"/*", "", false},
+		{"/*", "/a/b", false},
+		{"/*/values/image/tag", "/module-one/values/image/tag", true},
+		{"/*/values/image/tag", "/module-one/values/image", false},
+		{"/values/env/*/value", "/values/env/0/value", true},
+		{"/**", "", true},
+		{"/**", "/a/b/c", true},
+		{"/**/value", "/value", true},
+		{"/**/value", "/values/env/0/value", true},
+		{"/**/value", "/values/env/0/name", false},
+		{"/a/**/b", "/a/b", true},
+		{"/a/**/b", "/a/x/y/b", true},
+		{"/a/**/b", "/a/x/y/c", false},
+		{"/a/**/*/c", "/a/c", false},
+		{"/a/**/*/c", "/a/b/c", true},
+		{"/a~1b/*", "/a~1b/c", true},
+	}
+	for _, test := range tests {
+		match, err := Match(test.pattern, test.pointer)
+		is.NoErr(err)
+		if match != test.match {
+			t.Errorf("Match(%q, %q): expected %v, got %v", test.pattern, test.pointer, test.match, match)
+		}
+	}
+}
+
+func TestMatch_Invalid(t *testing.T) {
+	is := is.New(t)
+	_, err := Match("*", "/a")
+	is.True(errors.Is(err, ErrInvalidPointer)) // pattern is invalid
+	_, err = Match("/*", "a")
+	is.True(errors.Is(err, ErrInvalidPointer)) // pointer is invalid
+}
+
+func TestFindAll(t *testing.T) {
+	is := is.New(t)
+	var obj interface{}
+	is.NoErr(json.Unmarshal([]byte(`{
+  "module-one": {"values": {"image": {"tag": "1.0.0"}, "env": [{"name": "A", "value": "1"}, {"name": "B", "value": "2"}]}},
+  "module-two": {"values": {"image": {"tag": "2.0.0"}}},
+  "module-three": {"values": {"image": "redis"}}
+}`), &obj))
+
+	results, err := FindAll(obj, "/*/values/image/tag")
+	is.NoErr(err)
+	is.Equal(results, []Result{
+		{Pointer: Pointer{"module-one", "values", "image", "tag"}, Value: "1.0.0"},
+		{Pointer: Pointer{"module-two", "values", "image", "tag"}, Value: "2.0.0"},
+	})
+
+	results, err = FindAll(obj, "/module-one/values/env/*/value")
+	is.NoErr(err)
+	is.Equal(results, []Result{
+		{Pointer: Pointer{"module-one", "values", "env", "0", "value"}, Value: "1"},
+		{Pointer: Pointer{"module-one", "values", "env", "1", "value"}, Value: "2"},
+	})
+
+	results, err = FindAll(obj, "/**/tag")
+	is.NoErr(err)
+	is.Equal(len(results), 2)
+	is.Equal(results[0].Pointer.String(), "/module-one/values/image/tag")
+	is.Equal(results[1].Pointer.String(), "/module-two/values/image/tag")
+
+	results, err = FindAll(obj, "/module-four/**")
+	is.NoErr(err)
+	is.Equal(len(results), 0) // nothing under a missing property
+}
+
+func TestFindAll_EachValueOnce(t *testing.T) {
+	is := is.New(t)
+	obj := map[string]interface{}{"a": map[string]interface{}{"b": "c"}}
+	results, err := FindAll(obj, "/**/**")
+	is.NoErr(err)
+	is.Equal(len(results), 3)
+	is.Equal(results[0].Pointer.String(), "")
+	is.Equal(results[1].Pointer.String(), "/a")
+	is.Equal(results[2].Pointer.String(), "/a/b")
+}
+
+func TestFindAll_Invalid(t *testing.T) {
+	is := is.New(t)
+	_, err := FindAll(map[string]interface{}{}, "/~2")
+	is.True(errors.Is(err, ErrInvalidPointer))
+}
+
+func TestPattern_Overlaps(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		pointer  string
+		overlaps bool
+	}{
+		{"/*/helmchart", "/module-one/helmchart", true},
+		{"/*/helmchart", "/module-one", true},
+		{"/*/helmchart", "", true},
+		{"/*/helmchart", "/module-one/values", false},
+		{"/*/values/resources/**", "/module-one/values/resources/cpu", true},
+		{"/*/values/resources/**", "/module-one/values", true},
+		{"/*/values/resources/**", "/module-one/values/image", false},
+		{"/*/values/resources", "/module-one/values/resources/cpu/limit", true},
+		{"/**/secret", "/module-one/values/env", true},
+		{"/module-one/**", "/module-two/values", false},
+	}
+	for _, test := range tests {
+		pattern, err := ParsePattern(test.pattern)
+		if err != nil {
+			t.Fatalf("Expected no error, got error: %v", err)
+		}
+		pointer, err := Parse(test.pointer)
+		if err != nil {
+			t.Fatalf("Expected no error, got error: %v", err)
+		}
+		if overlaps := pattern.Overlaps(pointer); overlaps != test.overlaps {
+			t.Errorf("%q.Overlaps(%q): expected %v, got %v", test.pattern, test.pointer, test.overlaps, overlaps)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package jsonpointer
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// RelativePointer is a parsed Relative JSON Pointer
+// (https://tools.ietf.org/html/draft-handrews-relative-json-pointer-01). It refers to a value relative to another
+// value in the same document: Up is the number of levels to move up from that value, and then either Pointer is
+// followed down from there or, if Name is true, the property name or array index of the value reached is used.
+type RelativePointer struct {
+	Up      int
+	Pointer Pointer
+	Name    bool
+}
+
+// ParseRelative checks the syntax of a relative json-pointer string such as "0/foo" or "1#" and converts it to a
+// RelativePointer.
+func ParseRelative(relative string) (RelativePointer, error) {
+	digits := 0
+	for digits < len(relative) && relative[digits] >= '0' && relative[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 {
+		return RelativePointer{}, fmt.Errorf("relative pointer does not start with a non-negative integer: %w", ErrInvalidPointer)
+	}
+	if digits > 1 && relative[0] == '0' {
+		return RelativePointer{}, fmt.Errorf("relative pointer prefix has a leading zero: %w", ErrInvalidPointer)
+	}
+	up, err := strconv.Atoi(relative[:digits])
+	if err != nil {
+		return RelativePointer{}, fmt.Errorf("relative pointer prefix `%s` is too large: %w", relative[:digits], ErrInvalidPointer)
+	}
+
+	rest := relative[digits:]
+	if rest == "#" {
+		return RelativePointer{Up: up, Name: true}, nil
+	}
+	pointer, err := Parse(rest)
+	if err != nil {
+		return RelativePointer{}, err
+	}
+	return RelativePointer{Up: up, Pointer: pointer}, nil
+}
+
+// String returns the relative json-pointer string for r.
+func (r RelativePointer) String() string {
+	if r.Name {
+		return strconv.Itoa(r.Up) + "#"
+	}
+	return strconv.Itoa(r.Up) + r.Pointer.String()
+}
+
+// Absolute returns the json-pointer that r refers to when starting from the value at base. An error wrapping
+// ErrDoesNotExist is returned if r moves up beyond the whole document. As it refers to a name rather than a value, a
+// RelativePointer ending in "#" has no absolute form and ErrInvalidPointer is returned.
+func (r RelativePointer) Absolute(base Pointer) (Pointer, error) {
+	if r.Name {
+		return nil, fmt.Errorf("`%s` refers to a name rather than a value: %w", r, ErrInvalidPointer)
+	}
+	ancestor, err := r.ancestor(base)
+	if err != nil {
+		return nil, err
+	}
+	absolute := make(Pointer, 0, len(ancestor)+len(r.Pointer))
+	absolute = append(absolute, ancestor...)
+	return append(absolute, r.Pointer...), nil
+}
+
+// Resolve takes the data structure returned by json.Unmarshal and returns the value r refers to when starting from the
+// value at base. For a RelativePointer ending in "#", the property name is returned as a string or the array index is
+// returned as a float64, as json.Unmarshal returns for numbers.
+//
+// Errors are the same as returned by Extract. Moving up beyond the whole document, or asking for the name of the whole
+// document, returns an error wrapping ErrDoesNotExist.
+func (r RelativePointer) Resolve(obj interface{}, base Pointer) (interface{}, error) {
+	// The starting value must exist even if the result is found without it.
+	if _, err := Extract(obj, base.String()); err != nil {
+		return nil, err
+	}
+	if !r.Name {
+		absolute, err := r.Absolute(base)
+		if err != nil {
+			return nil, err
+		}
+		return Extract(obj, absolute.String())
+	}
+
+	ancestor, err := r.ancestor(base)
+	if err != nil {
+		return nil, err
+	}
+	if len(ancestor) == 0 {
+		return nil, fmt.Errorf("the whole document has no name: %w", ErrDoesNotExist)
+	}
+	parent, err := Extract(obj, ancestor.Parent().String())
+	if err != nil {
+		return nil, err
+	}
+	key := ancestor[len(ancestor)-1]
+	if container, ok := parent.([]interface{}); ok {
+		index, err := parseIndex(key, len(container), false)
+		if err != nil {
+			return nil, err
+		}
+		return float64(index), nil
+	}
+	return key, nil
+}
+
+// ancestor returns the pointer r.Up levels above base.
+func (r RelativePointer) ancestor(base Pointer) (Pointer, error) {
+	if r.Up > len(base) {
+		return nil, fmt.Errorf("cannot move up %d levels from `%s`: %w", r.Up, base, ErrDoesNotExist)
+	}
+	return base[:len(base)-r.Up], nil
+}
+
+// ResolveRelative takes the data structure returned by json.Unmarshal and returns the value that the relative
+// json-pointer relative refers to when starting from the value pointed at by the json-pointer base.
+// See RelativePointer.Resolve.
+func ResolveRelative(obj interface{}, base, relative string) (interface{}, error) {
+	basePointer, err := Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	r, err := ParseRelative(relative)
+	if err != nil {
+		return nil, err
+	}
+	return r.Resolve(obj, basePointer)
+}
@@ -0,0 +1,95 @@
+package jsonpointer
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestResolveRelative(t *testing.T) {
+	is := is.New(t)
+	// From the draft: https://tools.ietf.org/html/draft-handrews-relative-json-pointer-01#section-5.1
+	var obj interface{}
+	is.NoErr(json.Unmarshal([]byte(`{
+  "foo": ["bar", "baz"],
+  "highly": {
+    "nested": {
+      "objects": true
+    }
+  }
+}`), &obj))
+
+	tests := []struct {
+		base     string
+		relative string
+		expected interface{}
+	}{
+		{"/foo/1", "0", "baz"},
+		{"/foo/1", "1/0", "bar"},
+		{"/foo/1", "2/highly/nested/objects", true},
+		{"/foo/1", "0#", 1.0},
+		{"/foo/1", "1#", "foo"},
+		{"/highly/nested", "0/objects", true},
+		{"/highly/nested", "1/nested/objects", true},
+		{"/highly/nested", "2/foo/0", "bar"},
+		{"/highly/nested", "0#", "nested"},
+		{"/highly/nested", "1#", "highly"},
+		{"", "0", obj},
+	}
+	for _, test := range tests {
+		value, err := ResolveRelative(obj, test.base, test.relative)
+		is.NoErr(err)
+		is.Equal(value, test.expected)
+	}
+}
+
+func TestResolveRelative_Errors(t *testing.T) {
+	obj := map[string]interface{}{"foo": []interface{}{"bar"}}
+	tests := []struct {
+		base     string
+		relative string
+		err      error
+	}{
+		{"/foo/0", "", ErrInvalidPointer},
+		{"/foo/0", "/foo", ErrInvalidPointer},
+		{"/foo/0", "01", ErrInvalidPointer},
+		{"/foo/0", "0foo", ErrInvalidPointer},
+		{"/foo/0", "0#/foo", ErrInvalidPointer},
+		{"/foo/0", "0/~2", ErrInvalidPointer},
+		{"foo", "0", ErrInvalidPointer},
+		{"/foo/0", "3", ErrDoesNotExist},
+		{"/foo/0", "2#", ErrDoesNotExist},
+		{"/foo/1", "0", ErrIndexOutOfRange},
+		{"/bar", "1/foo", ErrDoesNotExist},
+		{"/foo/0", "1/bar", ErrInvalidIndex},
+		{"/foo/0", "1/1", ErrIndexOutOfRange},
+		{"/foo/0", "0/bar", ErrNotContainer},
+	}
+	for _, test := range tests {
+		_, err := ResolveRelative(obj, test.base, test.relative)
+		if !errors.Is(err, test.err) {
+			t.Errorf("ResolveRelative(%q, %q): expected %v, got %v", test.base, test.relative, test.err, err)
+		}
+	}
+}
+
+func TestRelativePointer(t *testing.T) {
+	is := is.New(t)
+	r, err := ParseRelative("1/a~1b")
+	is.NoErr(err)
+	is.Equal(r, RelativePointer{Up: 1, Pointer: Pointer{"a/b"}})
+	is.Equal(r.String(), "1/a~1b")
+
+	absolute, err := r.Absolute(Pointer{"values", "env"})
+	is.NoErr(err)
+	is.Equal(absolute.String(), "/values/a~1b")
+
+	r, err = ParseRelative("10#")
+	is.NoErr(err)
+	is.Equal(r, RelativePointer{Up: 10, Name: true})
+	is.Equal(r.String(), "10#")
+	_, err = r.Absolute(Pointer{})
+	is.True(errors.Is(err, ErrInvalidPointer)) // names have no absolute pointer
+}
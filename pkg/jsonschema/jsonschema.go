@@ -0,0 +1,506 @@
+// Package jsonschema validates values derrived from JSON objects against a JSON Schema
+// (https://json-schema.org/draft/2019-09/json-schema-validation.html).
+//
+// Only a subset of the specification is supported:
+//
+// - Any: type, enum, const, allOf, anyOf, oneOf, not
+//
+// - Objects: properties, required, additionalProperties, minProperties, maxProperties
+//
+// - Arrays: items (single schema only), minItems, maxItems, uniqueItems
+//
+// - Strings: minLength, maxLength, pattern (Go regular expression syntax)
+//
+// - Numbers: minimum, maximum, exclusiveMinimum, exclusiveMaximum (as numbers), multipleOf
+//
+// Annotation keywords such as title, description and format are ignored. Schemas using keywords that change how values
+// are validated but are not supported (e.g. $ref) are rejected by Parse rather than silently ignored.
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"humanitec.io/deploymentset-svc/pkg/jsonpointer"
+)
+
+// ErrInvalidSchema indicates that a schema could not be parsed.
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// ErrNotSupported indicates that a schema uses a keyword that is not supported.
+var ErrNotSupported = errors.New("keyword not supported")
+
+// unsupportedKeywords change how values are validated but are not implemented.
+var unsupportedKeywords = []string{
+	"$ref", "$recursiveRef", "patternProperties", "propertyNames", "dependencies", "dependentRequired",
+	"dependentSchemas", "if", "then", "else", "contains", "minContains", "maxContains", "additionalItems",
+	"unevaluatedItems", "unevaluatedProperties",
+}
+
+// Schema is a parsed JSON Schema.
+type Schema struct {
+	// alwaysValid and neverValid are set for the boolean schemas true and false.
+	alwaysValid bool
+	neverValid  bool
+
+	types []string
+	enum  []interface{}
+	konst *interface{}
+
+	allOf []*Schema
+	anyOf []*Schema
+	oneOf []*Schema
+	not   *Schema
+
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	minProperties        *int
+	maxProperties        *int
+
+	items       *Schema
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+}
+
+// Error describes a value that does not match a schema. Path is the json-pointer of the value.
+type Error struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("path `%s`: %s", e.Path, e.Message)
+}
+
+// Parse parses a JSON Schema document.
+func Parse(data []byte) (*Schema, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", ErrInvalidSchema)
+	}
+	return compile(raw, "")
+}
+
+func compile(raw interface{}, path string) (*Schema, error) {
+	switch v := raw.(type) {
+	case bool:
+		return &Schema{alwaysValid: v, neverValid: !v}, nil
+	case map[string]interface{}:
+		return compileObject(v, path)
+	}
+	return nil, fmt.Errorf("schema at `%s` must be an object or boolean: %w", path, ErrInvalidSchema)
+}
+
+func compileObject(raw map[string]interface{}, path string) (*Schema, error) {
+	for _, keyword := range unsupportedKeywords {
+		if _, ok := raw[keyword]; ok {
+			return nil, fmt.Errorf("`%s` at `%s`: %w", keyword, path, ErrNotSupported)
+		}
+	}
+
+	s := &Schema{}
+	var err error
+
+	if t, ok := raw["type"]; ok {
+		if s.types, err = stringOrStrings(t); err != nil {
+			return nil, fmt.Errorf("type at `%s`: %w", path, err)
+		}
+	}
+	if e, ok := raw["enum"]; ok {
+		enum, ok := e.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("enum at `%s` must be an array: %w", path, ErrInvalidSchema)
+		}
+		s.enum = enum
+	}
+	if c, ok := raw["const"]; ok {
+		s.konst = &c
+	}
+
+	for keyword, target := range map[string]*[]*Schema{"allOf": &s.allOf, "anyOf": &s.anyOf, "oneOf": &s.oneOf} {
+		if list, ok := raw[keyword]; ok {
+			if *target, err = compileList(list, path+"/"+keyword); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for keyword, target := range map[string]**Schema{"not": &s.not, "additionalProperties": &s.additionalProperties, "items": &s.items} {
+		if sub, ok := raw[keyword]; ok {
+			if _, isArray := sub.([]interface{}); isArray && keyword == "items" {
+				return nil, fmt.Errorf("array form of items at `%s`: %w", path, ErrNotSupported)
+			}
+			if *target, err = compile(sub, path+"/"+keyword); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if p, ok := raw["properties"]; ok {
+		props, ok := p.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("properties at `%s` must be an object: %w", path, ErrInvalidSchema)
+		}
+		s.properties = make(map[string]*Schema, len(props))
+		for name, sub := range props {
+			if s.properties[name], err = compile(sub, path+"/properties/"+jsonpointer.Escape(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if r, ok := raw["required"]; ok {
+		if s.required, err = stringOrStrings(r); err != nil {
+			return nil, fmt.Errorf("required at `%s`: %w", path, err)
+		}
+	}
+	if u, ok := raw["uniqueItems"]; ok {
+		if s.uniqueItems, ok = u.(bool); !ok {
+			return nil, fmt.Errorf("uniqueItems at `%s` must be a boolean: %w", path, ErrInvalidSchema)
+		}
+	}
+	if p, ok := raw["pattern"]; ok {
+		pattern, ok := p.(string)
+		if !ok {
+			return nil, fmt.Errorf("pattern at `%s` must be a string: %w", path, ErrInvalidSchema)
+		}
+		if s.pattern, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("pattern at `%s` (%v): %w", path, err, ErrInvalidSchema)
+		}
+	}
+
+	for keyword, target := range map[string]**int{
+		"minProperties": &s.minProperties, "maxProperties": &s.maxProperties,
+		"minItems": &s.minItems, "maxItems": &s.maxItems,
+		"minLength": &s.minLength, "maxLength": &s.maxLength,
+	} {
+		if n, ok := raw[keyword]; ok {
+			f, ok := n.(float64)
+			if !ok || f < 0 || f != math.Trunc(f) {
+				return nil, fmt.Errorf("%s at `%s` must be a non-negative integer: %w", keyword, path, ErrInvalidSchema)
+			}
+			i := int(f)
+			*target = &i
+		}
+	}
+	for keyword, target := range map[string]**float64{
+		"minimum": &s.minimum, "maximum": &s.maximum,
+		"exclusiveMinimum": &s.exclusiveMinimum, "exclusiveMaximum": &s.exclusiveMaximum,
+		"multipleOf": &s.multipleOf,
+	} {
+		if n, ok := raw[keyword]; ok {
+			f, ok := n.(float64)
+			if !ok {
+				return nil, fmt.Errorf("%s at `%s` must be a number: %w", keyword, path, ErrInvalidSchema)
+			}
+			*target = &f
+		}
+	}
+	if s.multipleOf != nil && *s.multipleOf <= 0 {
+		return nil, fmt.Errorf("multipleOf at `%s` must be greater than 0: %w", path, ErrInvalidSchema)
+	}
+
+	return s, nil
+}
+
+func compileList(raw interface{}, path string) ([]*Schema, error) {
+	list, ok := raw.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("`%s` must be a non-empty array: %w", path, ErrInvalidSchema)
+	}
+	schemas := make([]*Schema, len(list))
+	for i := range list {
+		var err error
+		if schemas[i], err = compile(list[i], fmt.Sprintf("%s/%d", path, i)); err != nil {
+			return nil, err
+		}
+	}
+	return schemas, nil
+}
+
+func stringOrStrings(raw interface{}) ([]string, error) {
+	switch v := raw.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		out := make([]string, len(v))
+		for i := range v {
+			str, ok := v[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("must be a string or array of strings: %w", ErrInvalidSchema)
+			}
+			out[i] = str
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("must be a string or array of strings: %w", ErrInvalidSchema)
+}
+
+// Validate checks value against the schema. value is expected to be the sort of structure returned by json.Unmarshal.
+// All problems are returned, ordered by path. An empty list means the value is valid.
+func (s *Schema) Validate(value interface{}) []Error {
+	errs := s.validate(value, "")
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	return errs
+}
+
+func (s *Schema) validate(value interface{}, path string) []Error {
+	if s.alwaysValid {
+		return nil
+	}
+	if s.neverValid {
+		return []Error{{Path: path, Message: "no value is allowed"}}
+	}
+
+	value = normalizeNumber(value)
+	if len(s.types) > 0 && !s.matchesType(value) {
+		return []Error{{Path: path, Message: fmt.Sprintf("expected %s, got %s", strings.Join(s.types, " or "), typeOf(value))}}
+	}
+
+	var errs []Error
+	if s.enum != nil {
+		found := false
+		for _, allowed := range s.enum {
+			if reflect.DeepEqual(normalize(allowed), normalize(value)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, Error{Path: path, Message: "value is not one of the allowed values"})
+		}
+	}
+	if s.konst != nil && !reflect.DeepEqual(normalize(*s.konst), normalize(value)) {
+		errs = append(errs, Error{Path: path, Message: "value is not the allowed value"})
+	}
+
+	errs = append(errs, s.validateCombinations(value, path)...)
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		errs = append(errs, s.validateObject(v, path)...)
+	case []interface{}:
+		errs = append(errs, s.validateArray(v, path)...)
+	case string:
+		errs = append(errs, s.validateString(v, path)...)
+	case float64:
+		errs = append(errs, s.validateNumber(v, path)...)
+	}
+	return errs
+}
+
+func (s *Schema) validateCombinations(value interface{}, path string) []Error {
+	var errs []Error
+	for _, sub := range s.allOf {
+		errs = append(errs, sub.validate(value, path)...)
+	}
+	if s.anyOf != nil {
+		matched := false
+		for _, sub := range s.anyOf {
+			if len(sub.validate(value, path)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			errs = append(errs, Error{Path: path, Message: "value does not match any of the allowed schemas"})
+		}
+	}
+	if s.oneOf != nil {
+		matches := 0
+		for _, sub := range s.oneOf {
+			if len(sub.validate(value, path)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			errs = append(errs, Error{Path: path, Message: fmt.Sprintf("value must match exactly one schema, matched %d", matches)})
+		}
+	}
+	if s.not != nil && len(s.not.validate(value, path)) == 0 {
+		errs = append(errs, Error{Path: path, Message: "value matches a schema it must not match"})
+	}
+	return errs
+}
+
+func (s *Schema) validateObject(obj map[string]interface{}, path string) []Error {
+	var errs []Error
+	for _, name := range s.required {
+		if _, ok := obj[name]; !ok {
+			errs = append(errs, Error{Path: path, Message: fmt.Sprintf("missing required property `%s`", name)})
+		}
+	}
+	if s.minProperties != nil && len(obj) < *s.minProperties {
+		errs = append(errs, Error{Path: path, Message: fmt.Sprintf("must have at least %d properties", *s.minProperties)})
+	}
+	if s.maxProperties != nil && len(obj) > *s.maxProperties {
+		errs = append(errs, Error{Path: path, Message: fmt.Sprintf("must have at most %d properties", *s.maxProperties)})
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + "/" + jsonpointer.Escape(key)
+		if sub, ok := s.properties[key]; ok {
+			errs = append(errs, sub.validate(obj[key], childPath)...)
+		} else if s.additionalProperties != nil {
+			if s.additionalProperties.neverValid {
+				errs = append(errs, Error{Path: childPath, Message: "property is not allowed"})
+			} else {
+				errs = append(errs, s.additionalProperties.validate(obj[key], childPath)...)
+			}
+		}
+	}
+	return errs
+}
+
+func (s *Schema) validateArray(arr []interface{}, path string) []Error {
+	var errs []Error
+	if s.minItems != nil && len(arr) < *s.minItems {
+		errs = append(errs, Error{Path: path, Message: fmt.Sprintf("must have at least %d items", *s.minItems)})
+	}
+	if s.maxItems != nil && len(arr) > *s.maxItems {
+		errs = append(errs, Error{Path: path, Message: fmt.Sprintf("must have at most %d items", *s.maxItems)})
+	}
+	if s.uniqueItems {
+		for i := range arr {
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(normalize(arr[i]), normalize(arr[j])) {
+					errs = append(errs, Error{Path: fmt.Sprintf("%s/%d", path, i), Message: fmt.Sprintf("item is a duplicate of item %d", j)})
+					break
+				}
+			}
+		}
+	}
+	if s.items != nil {
+		for i := range arr {
+			errs = append(errs, s.items.validate(arr[i], fmt.Sprintf("%s/%d", path, i))...)
+		}
+	}
+	return errs
+}
+
+func (s *Schema) validateString(str string, path string) []Error {
+	var errs []Error
+	length := utf8.RuneCountInString(str)
+	if s.minLength != nil && length < *s.minLength {
+		errs = append(errs, Error{Path: path, Message: fmt.Sprintf("must be at least %d characters", *s.minLength)})
+	}
+	if s.maxLength != nil && length > *s.maxLength {
+		errs = append(errs, Error{Path: path, Message: fmt.Sprintf("must be at most %d characters", *s.maxLength)})
+	}
+	if s.pattern != nil && !s.pattern.MatchString(str) {
+		errs = append(errs, Error{Path: path, Message: fmt.Sprintf("must match pattern `%s`", s.pattern.String())})
+	}
+	return errs
+}
+
+func (s *Schema) validateNumber(f float64, path string) []Error {
+	var errs []Error
+	if s.minimum != nil && f < *s.minimum {
+		errs = append(errs, Error{Path: path, Message: fmt.Sprintf("must be at least %v", *s.minimum)})
+	}
+	if s.maximum != nil && f > *s.maximum {
+		errs = append(errs, Error{Path: path, Message: fmt.Sprintf("must be at most %v", *s.maximum)})
+	}
+	if s.exclusiveMinimum != nil && f <= *s.exclusiveMinimum {
+		errs = append(errs, Error{Path: path, Message: fmt.Sprintf("must be greater than %v", *s.exclusiveMinimum)})
+	}
+	if s.exclusiveMaximum != nil && f >= *s.exclusiveMaximum {
+		errs = append(errs, Error{Path: path, Message: fmt.Sprintf("must be less than %v", *s.exclusiveMaximum)})
+	}
+	if s.multipleOf != nil {
+		quotient := f / *s.multipleOf
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			errs = append(errs, Error{Path: path, Message: fmt.Sprintf("must be a multiple of %v", *s.multipleOf)})
+		}
+	}
+	return errs
+}
+
+func (s *Schema) matchesType(value interface{}) bool {
+	actual := typeOf(value)
+	for _, t := range s.types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON Schema type name for a value. Numbers with no fractional part are "integer".
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// normalizeNumber converts Go numeric types to float64. Other values are returned unchanged.
+func normalizeNumber(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+	return value
+}
+
+// normalize converts Go numeric types to float64 so that values built in Go compare equal to values decoded from JSON.
+// Arrays and objects are normalized recursively.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = normalize(v[i])
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k := range v {
+			out[k] = normalize(v[k])
+		}
+		return out
+	}
+	return normalizeNumber(value)
+}
@@ -0,0 +1,139 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func mustParse(is *is.I, schema string) *Schema {
+	s, err := Parse([]byte(schema))
+	is.NoErr(err)
+	return s
+}
+
+func mustUnmarshal(is *is.I, value string) interface{} {
+	var v interface{}
+	is.NoErr(json.Unmarshal([]byte(value), &v))
+	return v
+}
+
+const chartSchema = `{
+  "type": "object",
+  "required": ["image"],
+  "additionalProperties": false,
+  "properties": {
+    "image": {"type": "string", "pattern": "^registry\\.humanitec\\.io/"},
+    "replicas": {"type": "integer", "minimum": 1, "maximum": 10},
+    "pullPolicy": {"enum": ["Always", "IfNotPresent"]},
+    "configmap": {"type": "object", "additionalProperties": {"type": "string", "maxLength": 5}},
+    "ports": {"type": "array", "items": {"type": "integer"}, "uniqueItems": true, "maxItems": 3},
+    "a/b": {"type": "boolean"}
+  }
+}`
+
+func TestValidate(t *testing.T) {
+	is := is.New(t)
+	s := mustParse(is, chartSchema)
+
+	value := mustUnmarshal(is, `{
+    "image": "registry.humanitec.io/my-org/module-one:1.0.0",
+    "replicas": 3,
+    "pullPolicy": "Always",
+    "configmap": {"A": "short"},
+    "ports": [80, 443],
+    "a/b": true
+  }`)
+
+	is.Equal(len(s.Validate(value)), 0) // Value should be valid
+}
+
+func TestValidate_Errors(t *testing.T) {
+	is := is.New(t)
+	s := mustParse(is, chartSchema)
+
+	value := mustUnmarshal(is, `{
+    "image": "docker.io/my-org/module-one:1.0.0",
+    "replicas": 1.5,
+    "pullPolicy": "Never",
+    "configmap": {"A": "too long", "B": 5},
+    "ports": [80, 80, 443, 8080],
+    "a/b": "yes",
+    "unknown": true
+  }`)
+
+	expected := []Error{
+		{Path: "/a~1b", Message: "expected boolean, got string"},
+		{Path: "/configmap/A", Message: "must be at most 5 characters"},
+		{Path: "/configmap/B", Message: "expected string, got integer"},
+		{Path: "/image", Message: "must match pattern `^registry\\.humanitec\\.io/`"},
+		{Path: "/ports", Message: "must have at most 3 items"},
+		{Path: "/ports/1", Message: "item is a duplicate of item 0"},
+		{Path: "/pullPolicy", Message: "value is not one of the allowed values"},
+		{Path: "/replicas", Message: "expected integer, got number"},
+		{Path: "/unknown", Message: "property is not allowed"},
+	}
+	is.Equal(s.Validate(value), expected)
+}
+
+func TestValidate_Required(t *testing.T) {
+	is := is.New(t)
+	s := mustParse(is, chartSchema)
+
+	is.Equal(s.Validate(map[string]interface{}{}), []Error{{Path: "", Message: "missing required property `image`"}})
+}
+
+func TestValidate_GoNumbers(t *testing.T) {
+	is := is.New(t)
+	s := mustParse(is, `{"type": "integer", "enum": [1, 2], "exclusiveMaximum": 2}`)
+
+	is.Equal(len(s.Validate(1)), 0)        // int should be treated like a JSON number
+	is.Equal(len(s.Validate(int64(2))), 1) // 2 is not less than 2
+	is.Equal(len(s.Validate("1")), 1)      // a string is not an integer
+}
+
+func TestValidate_Combinations(t *testing.T) {
+	is := is.New(t)
+	s := mustParse(is, `{
+    "allOf": [{"minLength": 2}],
+    "anyOf": [{"pattern": "^a"}, {"pattern": "^b"}],
+    "oneOf": [{"pattern": "a$"}, {"pattern": "^ab"}],
+    "not": {"const": "aa"}
+  }`)
+
+	is.Equal(len(s.Validate("ba")), 0)
+	is.Equal(s.Validate("aa"), []Error{{Path: "", Message: "value matches a schema it must not match"}})
+	is.Equal(s.Validate("aba"), []Error{{Path: "", Message: "value must match exactly one schema, matched 2"}})
+	is.Equal(s.Validate("c"), []Error{
+		{Path: "", Message: "must be at least 2 characters"},
+		{Path: "", Message: "value does not match any of the allowed schemas"},
+		{Path: "", Message: "value must match exactly one schema, matched 0"},
+	})
+}
+
+func TestValidate_BooleanSchemas(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(len(mustParse(is, `true`).Validate("anything")), 0)
+	is.Equal(len(mustParse(is, `false`).Validate("anything")), 1)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	is := is.New(t)
+
+	for _, schema := range []string{`5`, `{"type": 5}`, `{"minLength": -1}`, `{"pattern": "("}`, `{"properties": []}`, `{"anyOf": []}`} {
+		_, err := Parse([]byte(schema))
+		is.True(errors.Is(err, ErrInvalidSchema)) // Schema should be invalid
+	}
+}
+
+func TestParse_NotSupported(t *testing.T) {
+	is := is.New(t)
+
+	for _, schema := range []string{`{"$ref": "#/definitions/a"}`, `{"properties": {"a": {"if": true}}}`, `{"items": [true]}`} {
+		_, err := Parse([]byte(schema))
+		is.True(errors.Is(err, ErrNotSupported)) // Schema should not be supported
+	}
+}
@@ -0,0 +1,214 @@
+// Package policy restricts which users may change which parts of a Deployment Set.
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"humanitec.io/deploymentset-svc/pkg/depset"
+	"humanitec.io/deploymentset-svc/pkg/jsonpointer"
+)
+
+// ErrInvalidPolicy indicates that a policy cannot be parsed or refers to something that does not exist.
+var ErrInvalidPolicy = errors.New("invalid policy")
+
+// Policy is a set of rules restricting who may write to parts of a Deployment Set.
+//
+// Teams maps team names to the users in the team so that rules can allow whole teams.
+type Policy struct {
+	Teams map[string][]string `json:"teams,omitempty"`
+	Rules []Rule              `json:"rules"`
+}
+
+// Rule restricts writes to the values matched by Path to the listed users and the members of the listed teams. A rule
+// that lists nobody forbids all writes.
+//
+// Path is a jsonpointer.Pattern whose first segment matches the module name and the rest matches the path within the
+// module spec, e.g. "/*/values/resources/**" or "/*/helmchart". A change writes to a value if it changes the value, a
+// value below it or a value above it. Adding a module writes to every value in the added spec and removing or
+// replacing a module writes to every value in the existing spec, so only the values a module actually has are checked.
+type Rule struct {
+	Description string   `json:"description,omitempty"`
+	Path        string   `json:"path"`
+	Users       []string `json:"users,omitempty"`
+	Teams       []string `json:"teams,omitempty"`
+
+	pattern jsonpointer.Pattern
+}
+
+// Violation describes a change made by a Delta that the user is not allowed to make.
+type Violation struct {
+	Module  string `json:"module"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (v Violation) Error() string {
+	if v.Path == "" {
+		return fmt.Sprintf("module `%s`: %s", v.Module, v.Message)
+	}
+	return fmt.Sprintf("module `%s` path `%s`: %s", v.Module, v.Path, v.Message)
+}
+
+// Parse parses a Policy from its JSON representation and checks that every rule is valid. Unknown properties are
+// rejected so that misspelt rules are not silently ignored.
+func Parse(data []byte) (*Policy, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var p Policy
+	if err := decoder.Decode(&p); err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrInvalidPolicy)
+	}
+
+	for i := range p.Rules {
+		pattern, err := jsonpointer.ParsePattern(p.Rules[i].Path)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: path `%s`: %v: %w", i, p.Rules[i].Path, err, ErrInvalidPolicy)
+		}
+		if len(pattern) == 0 {
+			return nil, fmt.Errorf("rule %d: path must not be empty: %w", i, ErrInvalidPolicy)
+		}
+		p.Rules[i].pattern = pattern
+		for _, team := range p.Rules[i].Teams {
+			if _, ok := p.Teams[team]; !ok {
+				return nil, fmt.Errorf("rule %d: team `%s` is not defined: %w", i, team, ErrInvalidPolicy)
+			}
+		}
+	}
+	return &p, nil
+}
+
+// allows returns true if user may write to the values matched by rule.
+func (p *Policy) allows(rule Rule, user string) bool {
+	for _, allowed := range rule.Users {
+		if allowed == user {
+			return true
+		}
+	}
+	for _, team := range rule.Teams {
+		for _, member := range p.Teams[team] {
+			if member == user {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// write is a single write made by a Delta to a module.
+type write struct {
+	module  string
+	path    string
+	pointer jsonpointer.Pointer
+}
+
+// writes lists every write made by delta to set in module name order. "test" updates do not write anything. Paths
+// that are not valid json-pointers are treated as writing the whole module.
+func writes(set depset.Set, delta depset.Delta) []write {
+	var ws []write
+	addWrite := func(module, path string) {
+		pointer, err := jsonpointer.Parse(path)
+		if err != nil {
+			pointer = jsonpointer.Pointer{}
+		}
+		ws = append(ws, write{
+			module:  module,
+			path:    path,
+			pointer: append(jsonpointer.Pointer{module}, pointer...),
+		})
+	}
+	addSpecWrites := func(module string, specs ...map[string]interface{}) {
+		seen := make(map[string]bool)
+		for _, spec := range specs {
+			for _, path := range leaves(spec) {
+				if !seen[path] {
+					seen[path] = true
+					addWrite(module, path)
+				}
+			}
+		}
+	}
+
+	for name, spec := range delta.Modules.Add {
+		if spec != nil {
+			addSpecWrites(name, set.Modules[name], spec)
+		}
+	}
+	for _, name := range delta.Modules.Remove {
+		addSpecWrites(name, set.Modules[name])
+	}
+	for name, actions := range delta.Modules.Update {
+		for _, action := range actions {
+			switch action.Operation {
+			case "test":
+			case "move":
+				addWrite(name, action.From)
+				addWrite(name, action.Path)
+			default:
+				addWrite(name, action.Path)
+			}
+		}
+	}
+
+	// Each module has at most one add and one list of updates, so a stable sort gives the same order every time.
+	sort.SliceStable(ws, func(i, j int) bool {
+		return ws[i].module < ws[j].module
+	})
+	return ws
+}
+
+// leaves returns the paths of the values in spec that are not objects or arrays, or that are empty objects or arrays,
+// in key and index order. An empty or nil spec has no leaves.
+func leaves(spec map[string]interface{}) []string {
+	if len(spec) == 0 {
+		return nil
+	}
+	results, _ := jsonpointer.FindAll(map[string]interface{}(spec), "/**")
+	var paths []string
+	for _, result := range results {
+		switch value := result.Value.(type) {
+		case map[string]interface{}:
+			if len(value) > 0 {
+				continue
+			}
+		case []interface{}:
+			if len(value) > 0 {
+				continue
+			}
+		}
+		paths = append(paths, result.Pointer.String())
+	}
+	return paths
+}
+
+// Check returns a Violation for each write made by applying delta to set that user is not allowed to make. set is only
+// used to find the values written by removing or replacing modules; if it is not known yet, pass an empty Set and
+// check again once it is. Writes are checked in module name order and each is reported once, for the first rule it
+// breaks. A nil Policy allows everything.
+func (p *Policy) Check(user string, set depset.Set, delta depset.Delta) []Violation {
+	if p == nil {
+		return nil
+	}
+	var violations []Violation
+	for _, w := range writes(set, delta) {
+		for _, rule := range p.Rules {
+			if !rule.pattern.Overlaps(w.pointer) || p.allows(rule, user) {
+				continue
+			}
+			message := fmt.Sprintf("user `%s` may not change values matching `%s`", user, rule.Path)
+			if rule.Description != "" {
+				message += ": " + rule.Description
+			}
+			violations = append(violations, Violation{
+				Module:  w.module,
+				Path:    w.path,
+				Message: message,
+			})
+			break
+		}
+	}
+	return violations
+}
@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+	"humanitec.io/deploymentset-svc/pkg/depset"
+)
+
+const testPolicy = `{
+  "teams": {
+    "platform": ["alice", "bob"]
+  },
+  "rules": [
+    {
+      "description": "resources are managed by the platform team",
+      "path": "/*/values/resources/**",
+      "teams": ["platform"]
+    },
+    {
+      "path": "/*/helmchart"
+    },
+    {
+      "path": "/database/**",
+      "users": ["carol"]
+    }
+  ]
+}`
+
+func parseTestPolicy(t *testing.T) *Policy {
+	p, err := Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Expected no error, got error: %v", err)
+	}
+	return p
+}
+
+func TestCheck_Allowed(t *testing.T) {
+	is := is.New(t)
+	p := parseTestPolicy(t)
+	delta := depset.Delta{
+		Modules: depset.ModuleDeltas{
+			Update: map[string][]depset.UpdateAction{
+				"module-one": {
+					{Operation: "replace", Path: "/values/resources/cpu", Value: "500m"},
+					{Operation: "test", Path: "/helmchart", Value: "humanitec/base-module"},
+				},
+			},
+		},
+	}
+	is.Equal(len(p.Check("alice", depset.Set{}, delta)), 0) // alice is in the platform team
+	is.Equal(len(p.Check("dave", depset.Set{}, depset.Delta{
+		Modules: depset.ModuleDeltas{
+			Update: map[string][]depset.UpdateAction{
+				"module-one": {{Operation: "replace", Path: "/values/image", Value: "nginx"}},
+			},
+		},
+	})), 0) // no rule covers /values/image
+	is.Equal(len(p.Check("carol", depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"module-two": {"values": map[string]interface{}{"image": "nginx"}},
+		},
+	}, depset.Delta{
+		Modules: depset.ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"database": {"values": map[string]interface{}{"image": "postgres"}},
+			},
+			Remove: []string{"module-two"},
+		},
+	})), 0) // only the values in added and removed modules are checked
+}
+
+func TestCheck_Violations(t *testing.T) {
+	is := is.New(t)
+	p := parseTestPolicy(t)
+	delta := depset.Delta{
+		Modules: depset.ModuleDeltas{
+			Add: map[string]map[string]interface{}{
+				"cache": {
+					"helmchart": "humanitec/redis",
+					"values":    map[string]interface{}{"resources": map[string]interface{}{"memory": "1Gi"}},
+				},
+			},
+			Update: map[string][]depset.UpdateAction{
+				"module-one": {
+					{Operation: "replace", Path: "/values/resources/cpu", Value: "500m"},
+					{Operation: "replace", Path: "/values/image", Value: "nginx"},
+					{Operation: "replace", Path: "/helmchart", Value: "humanitec/other"},
+					{Operation: "remove", Path: "/values"},
+				},
+			},
+		},
+	}
+	is.Equal(p.Check("carol", depset.Set{}, delta), []Violation{
+		{Module: "cache", Path: "/helmchart", Message: "user `carol` may not change values matching `/*/helmchart`"},
+		{
+			Module:  "cache",
+			Path:    "/values/resources/memory",
+			Message: "user `carol` may not change values matching `/*/values/resources/**`: resources are managed by the platform team",
+		},
+		{
+			Module:  "module-one",
+			Path:    "/values/resources/cpu",
+			Message: "user `carol` may not change values matching `/*/values/resources/**`: resources are managed by the platform team",
+		},
+		{Module: "module-one", Path: "/helmchart", Message: "user `carol` may not change values matching `/*/helmchart`"},
+		{
+			Module:  "module-one",
+			Path:    "/values",
+			Message: "user `carol` may not change values matching `/*/values/resources/**`: resources are managed by the platform team",
+		},
+	})
+}
+
+func TestCheck_Move(t *testing.T) {
+	is := is.New(t)
+	p := parseTestPolicy(t)
+	delta := depset.Delta{
+		Modules: depset.ModuleDeltas{
+			Remove: []string{"database"},
+			Update: map[string][]depset.UpdateAction{
+				"module-one": {{Operation: "move", From: "/values/resources", Path: "/values/old-resources"}},
+			},
+		},
+	}
+	set := depset.Set{
+		Modules: map[string]map[string]interface{}{
+			"database": {"values": map[string]interface{}{"image": "postgres"}},
+		},
+	}
+	violations := p.Check("dave", set, delta)
+	is.Equal(len(violations), 2)
+	is.Equal(violations[0].Path, "/values/image")     // removing a module writes to every value in it
+	is.Equal(violations[1].Path, "/values/resources") // moving removes the source
+}
+
+func TestCheck_NilPolicy(t *testing.T) {
+	is := is.New(t)
+	var p *Policy
+	is.Equal(len(p.Check("anyone", depset.Set{}, depset.Delta{Modules: depset.ModuleDeltas{Remove: []string{"module-one"}}})), 0)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	is := is.New(t)
+	for _, policy := range []string{
+		`not json`,
+		`{"rules": [{"path": "values"}]}`,
+		`{"rules": [{"path": ""}]}`,
+		`{"rules": [{"path": "/*/values", "teams": ["platform"]}]}`,
+		`{"rules": [{"path": "/*/values", "user": ["alice"]}]}`,
+	} {
+		_, err := Parse([]byte(policy))
+		is.True(errors.Is(err, ErrInvalidPolicy)) // policy should be invalid
+	}
+}